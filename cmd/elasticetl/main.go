@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -11,21 +12,27 @@ import (
 	"time"
 
 	"elasticetl/pkg/config"
+	"elasticetl/pkg/load"
+	"elasticetl/pkg/logging"
 	"elasticetl/pkg/metrics"
 	"elasticetl/pkg/pipeline"
 )
 
 const (
-	defaultConfigPath = "configs/config.json"
-	defaultLogLevel   = "info"
+	defaultConfigPath      = "configs/config.json"
+	defaultLogLevel        = "info"
+	defaultShutdownTimeout = 30 * time.Second
 )
 
 func main() {
 	// Parse command line flags
 	var (
-		configPath = flag.String("config", defaultConfigPath, "Path to configuration file")
-		logLevel   = flag.String("log-level", defaultLogLevel, "Log level (debug, info, warn, error)")
-		version    = flag.Bool("version", false, "Show version information")
+		configPath          = flag.String("config", defaultConfigPath, "Path to configuration file")
+		logLevel            = flag.String("log-level", defaultLogLevel, "Log level (debug, info, warn, error)")
+		version             = flag.Bool("version", false, "Show version information")
+		shutdownTimeoutFlag = flag.Duration("shutdown-timeout", 0, "Time allowed to stop pipelines and the metrics server (overrides global.shutdown_timeout; default 30s)")
+		loadFrom            = flag.String("load-from", "", "Load transformed results from a json debug file through a pipeline's streams, then exit")
+		pipelineName        = flag.String("pipeline", "", "Pipeline whose load configuration -load-from uses (required with several pipelines)")
 	)
 	flag.Parse()
 
@@ -36,9 +43,7 @@ func main() {
 	}
 
 	// Setup logging
-	setupLogging(*logLevel)
-
-	log.Printf("Starting ElasticETL with config: %s", *configPath)
+	consoleOutput := setupLogging(*logLevel)
 
 	// Load configuration
 	configLoader, err := config.NewLoader(*configPath)
@@ -49,13 +54,32 @@ func main() {
 
 	initialConfig := configLoader.GetConfig()
 
+	// Route logs to the configured output (stdout or rotating file)
+	if err := logging.Apply(initialConfig.Global.Logging, logConsole(initialConfig, consoleOutput)); err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	defer logging.Close()
+
+	log.Printf("Starting ElasticETL with config: %s", *configPath)
+
+	// Replay captured transformed data through the load stage only
+	if *loadFrom != "" {
+		if err := runLoadFrom(*loadFrom, *pipelineName, initialConfig); err != nil {
+			log.Fatalf("Load from %s failed: %v", *loadFrom, err)
+		}
+		return
+	}
+
 	// Initialize metrics collector
 	metricsCollector := metrics.NewCollector(initialConfig.Global.Metrics)
-	defer metricsCollector.Close()
+	defer func() {
+		metricsCollector.Shutdown(shutdownTimeout(*shutdownTimeoutFlag, configLoader.GetConfig().Global))
+	}()
 
 	// Initialize pipeline manager
 	pipelineManager := pipeline.NewManager(metricsCollector)
 	defer pipelineManager.Close()
+	pipelineManager.SetStartupPolicy(initialConfig.Global.StartupConcurrency, initialConfig.Global.StartupWindow)
 
 	// Create initial pipelines
 	for _, pipelineCfg := range initialConfig.Pipelines {
@@ -68,54 +92,101 @@ func main() {
 	configLoader.OnConfigChange(func(newConfig *config.Config) {
 		log.Println("Configuration changed, updating pipelines...")
 
+		// Update logging output
+		if err := logging.Apply(newConfig.Global.Logging, logConsole(newConfig, consoleOutput)); err != nil {
+			log.Printf("Failed to update logging config: %v", err)
+		}
+
 		// Update metrics collector
 		if err := metricsCollector.UpdateConfig(newConfig.Global.Metrics); err != nil {
 			log.Printf("Failed to update metrics config: %v", err)
 		}
 
 		// Update pipelines
+		pipelineManager.SetStartupPolicy(newConfig.Global.StartupConcurrency, newConfig.Global.StartupWindow)
 		if err := pipelineManager.UpdatePipelines(newConfig.Pipelines); err != nil {
 			log.Printf("Failed to update pipelines: %v", err)
+			metricsCollector.RecordConfigReloadFailure(err)
 		} else {
 			log.Println("Pipelines updated successfully")
 			metricsCollector.RecordConfigReload()
 		}
 	})
 
+	// Serve captured raw responses on the metrics server's debug endpoint
+	metricsCollector.SetLastResponseProvider(func(name string, index int) (*metrics.LastResponse, bool) {
+		response, ok := pipelineManager.GetLastResponse(name, index)
+		if !ok {
+			return nil, false
+		}
+		return &metrics.LastResponse{
+			StatusCode: response.StatusCode,
+			Body:       response.Body,
+			Truncated:  response.Truncated,
+			CapturedAt: response.CapturedAt,
+		}, true
+	})
+
+	// List configured pipelines on the metrics server
+	metricsCollector.SetPipelineListProvider(pipelineManager.ListPipelines)
+
+	// Count reloads rejected by the config loader
+	configLoader.OnReloadError(func(err error) {
+		metricsCollector.RecordConfigReloadFailure(err)
+	})
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Start all enabled pipelines
-	if err := pipelineManager.StartAllPipelines(ctx); err != nil {
-		log.Printf("Warning: Failed to start some pipelines: %v", err)
-	}
-
-	// Setup signal handling for graceful shutdown
+	// Setup signal handling for graceful shutdown before starting, so a signal during a
+	// staggered start still drains
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
-	log.Println("ElasticETL started successfully")
-	log.Printf("Metrics available at http://localhost:%d%s",
-		initialConfig.Global.Metrics.Port,
-		initialConfig.Global.Metrics.Path)
+	// Start all enabled pipelines; a staggered start stops early once ctx is cancelled
+	startDone := make(chan error, 1)
+	go func() {
+		startDone <- pipelineManager.StartAllPipelines(ctx)
+	}()
+
+	started := false
+	select {
+	case err := <-startDone:
+		started = true
+		if err != nil {
+			log.Printf("Warning: Failed to start some pipelines: %v", err)
+		}
+
+		log.Println("ElasticETL started successfully")
+		log.Printf("Metrics available at http://localhost:%d%s",
+			initialConfig.Global.Metrics.Port,
+			initialConfig.Global.Metrics.Path)
 
-	// Print pipeline status
-	printPipelineStatus(pipelineManager)
+		// Print pipeline status
+		printPipelineStatus(pipelineManager)
 
-	// Wait for shutdown signal
-	<-sigChan
-	log.Println("Shutdown signal received, stopping ElasticETL...")
+		// Wait for shutdown signal
+		<-sigChan
+		log.Println("Shutdown signal received, stopping ElasticETL...")
+	case <-sigChan:
+		log.Println("Shutdown signal received during startup, stopping ElasticETL...")
+	}
 
 	// Cancel context to stop all operations
 	cancel()
 
 	// Stop all pipelines with timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	timeout := shutdownTimeout(*shutdownTimeoutFlag, configLoader.GetConfig().Global)
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), timeout)
 	defer shutdownCancel()
 
 	done := make(chan error, 1)
 	go func() {
+		// Let an interrupted startup return so no pipeline starts after the stop
+		if !started {
+			<-startDone
+		}
 		done <- pipelineManager.StopAllPipelines()
 	}()
 
@@ -127,28 +198,100 @@ func main() {
 			log.Println("All pipelines stopped successfully")
 		}
 	case <-shutdownCtx.Done():
-		log.Println("Shutdown timeout reached, forcing exit")
+		log.Printf("Shutdown timeout (%s) reached, forcing exit", timeout)
+	}
+
+	// Summarize what ran before the collector is closed
+	report := metricsCollector.ShutdownReport()
+	log.Print(report)
+	if reportFile := configLoader.GetConfig().Global.Metrics.ShutdownReportFile; reportFile != "" {
+		if err := metrics.WriteShutdownReport(reportFile, report); err != nil {
+			log.Printf("Failed to write shutdown report: %v", err)
+		}
 	}
 
 	log.Println("ElasticETL stopped")
 }
 
-// setupLogging configures logging based on the specified level
-func setupLogging(level string) {
+// runLoadFrom loads the transformed results captured in path using the load configuration of
+// the named pipeline, or of the only pipeline when no name is given
+func runLoadFrom(path, name string, cfg *config.Config) error {
+	var pipelineCfg *config.PipelineConfig
+	for i := range cfg.Pipelines {
+		if cfg.Pipelines[i].Name == name || (name == "" && len(cfg.Pipelines) == 1) {
+			pipelineCfg = &cfg.Pipelines[i]
+			break
+		}
+	}
+	if pipelineCfg == nil {
+		if name == "" {
+			return fmt.Errorf("-pipeline is required when several pipelines are configured")
+		}
+		return fmt.Errorf("pipeline %s not found", name)
+	}
+
+	results, err := load.ReadTransformedResults(path)
+	if err != nil {
+		return err
+	}
+
+	loader, err := load.NewLoader(pipelineCfg.Load, logging.ForPipeline(pipelineCfg.Name))
+	if err != nil {
+		return fmt.Errorf("failed to create loader: %w", err)
+	}
+	defer loader.Close()
+
+	if err := loader.Load(context.Background(), results); err != nil {
+		return err
+	}
+
+	log.Printf("Loaded %d results from %s through pipeline %s", len(results), path, pipelineCfg.Name)
+	return nil
+}
+
+// shutdownTimeout returns the -shutdown-timeout flag if set, else global.shutdown_timeout,
+// else the 30s default
+func shutdownTimeout(flagValue time.Duration, global config.GlobalConfig) time.Duration {
+	if flagValue > 0 {
+		return flagValue
+	}
+	if global.ShutdownTimeout > 0 {
+		return global.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// setupLogging configures logging based on the specified level and returns the console output used
+func setupLogging(level string) io.Writer {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	var output io.Writer
 	switch level {
 	case "debug":
-		log.SetOutput(os.Stdout)
+		output = os.Stdout
 	case "info":
-		log.SetOutput(os.Stdout)
+		output = os.Stdout
 	case "warn":
-		log.SetOutput(os.Stderr)
+		output = os.Stderr
 	case "error":
-		log.SetOutput(os.Stderr)
+		output = os.Stderr
 	default:
-		log.SetOutput(os.Stdout)
+		output = os.Stdout
+	}
+
+	log.SetOutput(output)
+	return output
+}
+
+// logConsole returns the console writer for logs: stderr when an enabled pipeline streams data
+// to stdout, so log lines are not mixed into it, otherwise output
+func logConsole(cfg *config.Config, output io.Writer) io.Writer {
+	for _, pipelineCfg := range cfg.Pipelines {
+		if pipelineCfg.Enabled && load.WritesStdout(pipelineCfg.Load) {
+			return os.Stderr
+		}
 	}
+	return output
 }
 
 // printPipelineStatus prints the current status of all pipelines
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/load"
+	"elasticetl/pkg/transform"
+)
+
+func TestShutdownTimeout(t *testing.T) {
+	global := config.GlobalConfig{ShutdownTimeout: 10 * time.Second}
+
+	if got := shutdownTimeout(2*time.Second, global); got != 2*time.Second {
+		t.Errorf("flag and config set: timeout %v, want the flag's 2s", got)
+	}
+	if got := shutdownTimeout(0, global); got != 10*time.Second {
+		t.Errorf("config set: timeout %v, want global.shutdown_timeout 10s", got)
+	}
+	if got := shutdownTimeout(0, config.GlobalConfig{}); got != defaultShutdownTimeout {
+		t.Errorf("neither set: timeout %v, want the %v default", got, defaultShutdownTimeout)
+	}
+}
+
+func TestRunLoadFrom(t *testing.T) {
+	var mutex sync.Mutex
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		received++
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	// Capture a batch with a json debug stream, as a running pipeline would
+	dir := t.TempDir()
+	recorder, err := load.NewDebugStream(map[string]interface{}{"path": filepath.Join(dir, "capture")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Load(context.Background(), []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "http://es:9200", Timestamp: time.Now()},
+		TransformedData: map[string]interface{}{"docs_count": 42.0},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "capture_load_*.json"))
+	if len(files) != 1 {
+		t.Fatalf("recorded files %v, want one", files)
+	}
+	path := files[0]
+
+	cfg := &config.Config{Pipelines: []config.PipelineConfig{{
+		Name: "replay",
+		Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "gem", Config: map[string]interface{}{"endpoint": server.URL}}}},
+	}}}
+
+	if err := runLoadFrom(path, "", cfg); err != nil {
+		t.Fatal(err)
+	}
+	mutex.Lock()
+	if received != 1 {
+		t.Errorf("stream received %d requests, want 1", received)
+	}
+	mutex.Unlock()
+
+	if err := runLoadFrom(path, "missing", cfg); err == nil {
+		t.Error("expected an error for an unknown pipeline")
+	}
+}
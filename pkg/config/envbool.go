@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvBool is a boolean that may also be given as a string referencing environment variables,
+// e.g. "${DEBUG_ENABLED}", resolved when the config is loaded
+type EnvBool bool
+
+// ParseEnvBool expands ${VAR} references in value and parses the result as a boolean. A value
+// that expands to empty (such as an unset variable) is false
+func ParseEnvBool(value string) (bool, error) {
+	expanded := strings.TrimSpace(os.ExpandEnv(value))
+	if expanded == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(expanded)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean %q (from %q)", expanded, value)
+	}
+	return parsed, nil
+}
+
+// UnmarshalYAML accepts a boolean or a string with environment variable references
+func (b *EnvBool) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value bool
+	if err := unmarshal(&value); err == nil {
+		*b = EnvBool(value)
+		return nil
+	}
+
+	var text string
+	if err := unmarshal(&text); err != nil {
+		return fmt.Errorf("expected a boolean or a string: %w", err)
+	}
+	parsed, err := ParseEnvBool(text)
+	if err != nil {
+		return err
+	}
+	*b = EnvBool(parsed)
+	return nil
+}
+
+// UnmarshalJSON accepts a boolean or a string with environment variable references
+func (b *EnvBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	if err := json.Unmarshal(data, &value); err == nil {
+		*b = EnvBool(value)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("expected a boolean or a string: %w", err)
+	}
+	parsed, err := ParseEnvBool(text)
+	if err != nil {
+		return err
+	}
+	*b = EnvBool(parsed)
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseEnvBool(t *testing.T) {
+	t.Setenv("ENVBOOL_TEST_ON", "true")
+
+	for value, want := range map[string]bool{"true": true, "0": false, "${ENVBOOL_TEST_ON}": true, "${ENVBOOL_TEST_UNSET}": false} {
+		got, err := ParseEnvBool(value)
+		if err != nil || got != want {
+			t.Errorf("ParseEnvBool(%q) = %v, %v; want %v", value, got, err, want)
+		}
+	}
+	if _, err := ParseEnvBool("sometimes"); err == nil {
+		t.Error("expected an error for an unparsable boolean")
+	}
+}
+
+func TestEnvBoolUnmarshal(t *testing.T) {
+	t.Setenv("DEBUG_ENABLED", "true")
+
+	var debug struct {
+		Enabled EnvBool `json:"enabled" yaml:"enabled"`
+	}
+	if err := yaml.Unmarshal([]byte(`enabled: ${DEBUG_ENABLED}`), &debug); err != nil {
+		t.Fatal(err)
+	}
+	if !debug.Enabled {
+		t.Error("yaml enabled from ${DEBUG_ENABLED}=true is false")
+	}
+
+	t.Setenv("DEBUG_ENABLED", "false")
+	if err := json.Unmarshal([]byte(`{"enabled":"${DEBUG_ENABLED}"}`), &debug); err != nil {
+		t.Fatal(err)
+	}
+	if debug.Enabled {
+		t.Error("json enabled from ${DEBUG_ENABLED}=false is true")
+	}
+
+	if err := json.Unmarshal([]byte(`{"enabled":true}`), &debug); err != nil || !debug.Enabled {
+		t.Errorf("plain boolean: enabled = %v, %v", debug.Enabled, err)
+	}
+	if err := json.Unmarshal([]byte(`{"enabled":1}`), &debug); err == nil {
+		t.Error("expected an error for a number")
+	}
+}
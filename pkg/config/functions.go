@@ -0,0 +1,51 @@
+package config
+
+import (
+	"sort"
+	"sync"
+)
+
+// conversionFunctions holds the conversion function names accepted by validateConfig
+var (
+	conversionFunctionsMutex sync.RWMutex
+	conversionFunctions      = map[string]bool{
+		"convert_type":    true,
+		"convert_to_kb":   true,
+		"convert_to_mb":   true,
+		"convert_to_gb":   true,
+		"ratio":           true,
+		"parse_json":      true,
+		"to_bool_numeric": true,
+		"sanitize_string": true,
+		"mask":            true,
+		"delta":           true,
+		"rate":            true,
+	}
+)
+
+// RegisterConversionFunction adds a conversion function name to the set accepted at config load
+func RegisterConversionFunction(name string) {
+	conversionFunctionsMutex.Lock()
+	defer conversionFunctionsMutex.Unlock()
+	conversionFunctions[name] = true
+}
+
+// IsConversionFunction reports whether name is a registered conversion function
+func IsConversionFunction(name string) bool {
+	conversionFunctionsMutex.RLock()
+	defer conversionFunctionsMutex.RUnlock()
+	return conversionFunctions[name]
+}
+
+// ConversionFunctionNames returns the registered conversion function names in sorted order
+func ConversionFunctionNames() []string {
+	conversionFunctionsMutex.RLock()
+	defer conversionFunctionsMutex.RUnlock()
+
+	names := make([]string, 0, len(conversionFunctions))
+	for name := range conversionFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// IndexList is the set of indices searched by one endpoint. It accepts either a
+// comma-separated string ("a,b,c") or a list of names in YAML and JSON
+type IndexList []string
+
+// ParseIndexList splits a comma-separated index string, dropping surrounding spaces and empty names
+func ParseIndexList(value string) IndexList {
+	var indices IndexList
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			indices = append(indices, name)
+		}
+	}
+	return indices
+}
+
+// String joins the indices into the comma-separated form used in search paths
+func (l IndexList) String() string {
+	return strings.Join(l, ",")
+}
+
+// UnmarshalYAML accepts a comma-separated string or a list of index names
+func (l *IndexList) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var names []string
+	if err := unmarshal(&names); err == nil {
+		*l = ParseIndexList(strings.Join(names, ","))
+		return nil
+	}
+
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return fmt.Errorf("indices entry must be a string or a list of strings: %w", err)
+	}
+	*l = ParseIndexList(value)
+	return nil
+}
+
+// UnmarshalJSON accepts a comma-separated string or a list of index names
+func (l *IndexList) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		*l = ParseIndexList(strings.Join(names, ","))
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("indices entry must be a string or a list of strings: %w", err)
+	}
+	*l = ParseIndexList(value)
+	return nil
+}
+
+// illegalIndexChars are characters Elasticsearch rejects in index names. Wildcards (*)
+// and exclusions (-) are allowed since they are valid in search targets
+const illegalIndexChars = `\/?"<>| #,`
+
+// ValidateIndexName checks that name can be placed in a search path
+func ValidateIndexName(name string) error {
+	if name == "" || name == "." || name == ".." {
+		return fmt.Errorf("invalid index name %q", name)
+	}
+	if i := strings.IndexAny(name, illegalIndexChars); i >= 0 {
+		return fmt.Errorf("invalid index name %q: illegal character %q", name, name[i])
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestIndexListUnmarshal(t *testing.T) {
+	want := IndexList{"a", "b", "c"}
+
+	var fromJSON, fromYAML IndexList
+	if err := json.Unmarshal([]byte(`"a, b,,c"`), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(`a, b,,c`), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, want) || !reflect.DeepEqual(fromYAML, want) {
+		t.Errorf("comma-separated string: json %v, yaml %v; want %v", fromJSON, fromYAML, want)
+	}
+
+	// List entries may themselves be comma-separated
+	fromJSON, fromYAML = nil, nil
+	if err := json.Unmarshal([]byte(`["a,b","c"]`), &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(`["a,b", c]`), &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fromJSON, want) || !reflect.DeepEqual(fromYAML, want) {
+		t.Errorf("list: json %v, yaml %v; want %v", fromJSON, fromYAML, want)
+	}
+
+	if got := want.String(); got != "a,b,c" {
+		t.Errorf("String() = %q, want a,b,c", got)
+	}
+}
+
+func TestValidateIndexName(t *testing.T) {
+	for _, name := range []string{"metrics-*", "logs-2024.01", "-excluded", ".ds-logs"} {
+		if err := ValidateIndexName(name); err != nil {
+			t.Errorf("ValidateIndexName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "..", "a/b", "a b", "a?b", `a"b`, "a#b"} {
+		if err := ValidateIndexName(name); err == nil {
+			t.Errorf("ValidateIndexName(%q) accepted an illegal name", name)
+		}
+	}
+}
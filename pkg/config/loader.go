@@ -4,8 +4,13 @@ import (
 	"elasticetl/pkg/utils"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +25,8 @@ type Loader struct {
 	mutex      sync.RWMutex
 	watcher    *fsnotify.Watcher
 	callbacks  []func(*Config)
+	onError    []func(error)
+	queryFiles []string // Query files read by the current config
 }
 
 // NewLoader creates a new configuration loader
@@ -46,6 +53,11 @@ func NewLoader(configPath string) (*Loader, error) {
 	if err := watcher.Add(configPath); err != nil {
 		return nil, fmt.Errorf("failed to watch config file: %w", err)
 	}
+	for _, path := range loader.queryFiles {
+		if err := watcher.Add(path); err != nil {
+			return nil, fmt.Errorf("failed to watch query file: %w", err)
+		}
+	}
 
 	// Start watching for changes
 	go loader.watchForChanges()
@@ -67,6 +79,13 @@ func (l *Loader) OnConfigChange(callback func(*Config)) {
 	l.callbacks = append(l.callbacks, callback)
 }
 
+// OnReloadError registers a callback for configuration reloads that fail to load or validate
+func (l *Loader) OnReloadError(callback func(error)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.onError = append(l.onError, callback)
+}
+
 // Close stops the configuration loader
 func (l *Loader) Close() error {
 	if l.watcher != nil {
@@ -98,24 +117,138 @@ func (l *Loader) loadConfig() error {
 		return fmt.Errorf("unsupported config file format: %s", ext)
 	}
 
+	applyDefaults(&config)
+
 	// Validate configuration
 	if err := l.validateConfig(&config); err != nil {
 		return fmt.Errorf("config validation failed: %w", err)
 	}
 
+	queryFiles, err := l.readQueryFiles(&config)
+	if err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
+	}
+
 	l.mutex.Lock()
 	l.config = &config
+	l.queryFiles = queryFiles
 	l.mutex.Unlock()
 
+	// Query files changing also reloads the config. Files are added again after each reload
+	// since editors often replace the file, which drops it from the watch list
+	if l.watcher != nil {
+		for _, path := range queryFiles {
+			if err := l.watcher.Add(path); err != nil {
+				log.Printf("Failed to watch query file %s: %v", path, err)
+			}
+		}
+	}
+
 	return nil
 }
 
+// readQueryFiles loads each pipeline's query_file into its elasticsearch query, and "@path"
+// query overrides into the override, so macros are substituted as for inline queries. Relative
+// paths are resolved against the config file's directory. It returns the paths read
+func (l *Loader) readQueryFiles(config *Config) ([]string, error) {
+	var paths []string
+	for i := range config.Pipelines {
+		extract := &config.Pipelines[i].Extract
+		if extract.QueryFile != "" {
+			path := l.queryFilePath(extract.QueryFile)
+			query, err := readQueryFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %s: query_file: %w", config.Pipelines[i].Name, err)
+			}
+			extract.ElasticsearchQuery = query
+			paths = append(paths, path)
+		}
+
+		for cluster, override := range extract.QueryOverrides {
+			file, isFile := strings.CutPrefix(override, "@")
+			if !isFile {
+				continue
+			}
+			path := l.queryFilePath(file)
+			query, err := readQueryFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline %s: query_overrides %s: %w", config.Pipelines[i].Name, cluster, err)
+			}
+			extract.QueryOverrides[cluster] = query
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// readQueryFile reads a query from path, rejecting empty files
+func readQueryFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	query := strings.TrimSpace(string(data))
+	if query == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return query, nil
+}
+
+// queryFilePath resolves a query_file path relative to the config file's directory
+func (l *Loader) queryFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(l.configPath), path)
+}
+
+// applyDefaults fills pipeline settings that fall back to global configuration
+func applyDefaults(config *Config) {
+	maxConnections := config.Global.ResourceLimits.MaxConnections
+	for i := range config.Pipelines {
+		pipeline := &config.Pipelines[i]
+		if pipeline.Extract.MaxConnsPerHost == 0 {
+			pipeline.Extract.MaxConnsPerHost = maxConnections
+		}
+		if pipeline.Load.MaxConnsPerHost == 0 {
+			pipeline.Load.MaxConnsPerHost = maxConnections
+		}
+	}
+}
+
 // validateConfig validates the configuration
 func (l *Loader) validateConfig(config *Config) error {
 	if len(config.Pipelines) == 0 {
 		return fmt.Errorf("at least one pipeline must be configured")
 	}
 
+	// Validate logging output
+	switch config.Global.Logging.Output {
+	case "", "stdout":
+	case "file":
+		if config.Global.Logging.File == "" {
+			return fmt.Errorf("logging: file is required when output is file")
+		}
+	default:
+		return fmt.Errorf("logging: unsupported output %s", config.Global.Logging.Output)
+	}
+
+	if config.Global.StartupConcurrency < 0 || config.Global.StartupWindow < 0 {
+		return fmt.Errorf("startup_concurrency and startup_window must not be negative")
+	}
+
+	if config.Global.ShutdownTimeout < 0 {
+		return fmt.Errorf("shutdown_timeout must not be negative")
+	}
+
+	if config.Global.Metrics.HeartbeatInterval < 0 {
+		return fmt.Errorf("metrics: heartbeat_interval must not be negative")
+	}
+
+	// File paths written by earlier pipelines' streams, keyed by stream type and path, to reject
+	// two streams racing on one file
+	filePaths := make(map[string]string)
+
 	for i, pipeline := range config.Pipelines {
 		if pipeline.Name == "" {
 			return fmt.Errorf("pipeline %d: name is required", i)
@@ -125,6 +258,103 @@ func (l *Loader) validateConfig(config *Config) error {
 			return fmt.Errorf("pipeline %s: interval must be positive", pipeline.Name)
 		}
 
+		if pipeline.InitialDelay < 0 {
+			return fmt.Errorf("pipeline %s: initial_delay must not be negative", pipeline.Name)
+		}
+
+		if pipeline.LoadQueueSize < 0 {
+			return fmt.Errorf("pipeline %s: load_queue_size must not be negative", pipeline.Name)
+		}
+
+		switch strings.ToUpper(pipeline.Extract.Method) {
+		case "", http.MethodPost, http.MethodGet:
+		default:
+			return fmt.Errorf("pipeline %s: invalid extract method %q (must be POST or GET)", pipeline.Name, pipeline.Extract.Method)
+		}
+
+		if scroll := pipeline.Extract.Scroll; scroll != "" && !scrollKeepAlivePattern.MatchString(scroll) {
+			return fmt.Errorf("pipeline %s: invalid scroll keep-alive %q (e.g. 30s, 2m)", pipeline.Name, scroll)
+		}
+
+		for j, timeout := range pipeline.Extract.Timeouts {
+			if timeout < 0 {
+				return fmt.Errorf("pipeline %s: timeouts %d must not be negative", pipeline.Name, j)
+			}
+		}
+
+		if pipeline.Extract.MaxConcurrency < 0 {
+			return fmt.Errorf("pipeline %s: max_concurrency must not be negative", pipeline.Name)
+		}
+
+		if pipeline.Extract.MetadataMaxBytes < -1 {
+			return fmt.Errorf("pipeline %s: metadata_max_bytes must be -1 (unlimited), 0 (default) or positive", pipeline.Name)
+		}
+
+		if (pipeline.Extract.TLS.CertFile == "") != (pipeline.Extract.TLS.KeyFile == "") {
+			return fmt.Errorf("pipeline %s: tls cert_file and key_file must be set together", pipeline.Name)
+		}
+
+		if auth := pipeline.Extract.Auth; auth != nil {
+			switch auth.Type {
+			case AuthAPIKey:
+				if auth.APIKey == "" {
+					return fmt.Errorf("pipeline %s: auth type apikey requires api_key", pipeline.Name)
+				}
+			case AuthBearer:
+				if auth.Token == "" && len(auth.TokenCommand) == 0 {
+					return fmt.Errorf("pipeline %s: auth type bearer requires token or token_command", pipeline.Name)
+				}
+			case AuthBasic:
+				if auth.Username == "" {
+					return fmt.Errorf("pipeline %s: auth type basic requires username", pipeline.Name)
+				}
+			default:
+				return fmt.Errorf("pipeline %s: invalid auth type %q (must be %s, %s or %s)", pipeline.Name, auth.Type, AuthAPIKey, AuthBearer, AuthBasic)
+			}
+			if auth.TokenTTL < 0 {
+				return fmt.Errorf("pipeline %s: auth token_ttl must not be negative", pipeline.Name)
+			}
+		}
+
+		for j, path := range pipeline.Extract.JSONPaths {
+			if path.Path == "" {
+				return fmt.Errorf("pipeline %s: json_paths %d: path is required", pipeline.Name, j)
+			}
+			if path.MetricName != "" && !metricPrefixPattern.MatchString(path.MetricName) {
+				return fmt.Errorf("pipeline %s: json_paths %d: invalid metric_name %q", pipeline.Name, j, path.MetricName)
+			}
+		}
+
+		if pipeline.Extract.SearchAfter && pipeline.Extract.Scroll != "" {
+			return fmt.Errorf("pipeline %s: search_after and scroll cannot both be set", pipeline.Name)
+		}
+
+		if pipeline.Extract.MaxRetryDuration < 0 {
+			return fmt.Errorf("pipeline %s: max_retry_duration must not be negative", pipeline.Name)
+		}
+
+		if pipeline.Extract.MaxRetryAfter < 0 {
+			return fmt.Errorf("pipeline %s: max_retry_after must not be negative", pipeline.Name)
+		}
+
+		if pipeline.Load.StreamTimeout < 0 {
+			return fmt.Errorf("pipeline %s: stream_timeout must not be negative", pipeline.Name)
+		}
+
+		if pipeline.Load.MaxSeriesPerRun < 0 {
+			return fmt.Errorf("pipeline %s: max_series_per_run must not be negative", pipeline.Name)
+		}
+
+		if pipeline.Extract.MaxConnsPerHost < 0 || pipeline.Load.MaxConnsPerHost < 0 {
+			return fmt.Errorf("pipeline %s: max_conns_per_host must not be negative", pipeline.Name)
+		}
+
+		for name := range pipeline.MetricLabels {
+			if !labelNamePattern.MatchString(name) || name == "pipeline" {
+				return fmt.Errorf("pipeline %s: invalid metric_labels name %q", pipeline.Name, name)
+			}
+		}
+
 		if len(pipeline.Extract.URLs) == 0 {
 			return fmt.Errorf("pipeline %s: at least one URL is required", pipeline.Name)
 		}
@@ -133,14 +363,93 @@ func (l *Loader) validateConfig(config *Config) error {
 			return fmt.Errorf("pipeline %s: at least one cluster name is required", pipeline.Name)
 		}
 
-		if pipeline.Extract.ElasticsearchQuery == "" {
-			return fmt.Errorf("pipeline %s: elasticsearch query is required", pipeline.Name)
+		if pipeline.Extract.ElasticsearchQuery == "" && pipeline.Extract.QueryFile == "" {
+			return fmt.Errorf("pipeline %s: elasticsearch query or query_file is required", pipeline.Name)
+		}
+		if pipeline.Extract.ElasticsearchQuery != "" && pipeline.Extract.QueryFile != "" {
+			return fmt.Errorf("pipeline %s: set only one of elasticsearch_query and query_file", pipeline.Name)
+		}
+		for cluster, override := range pipeline.Extract.QueryOverrides {
+			if !slices.Contains(pipeline.Extract.ClusterNames, cluster) {
+				return fmt.Errorf("pipeline %s: query_overrides: unknown cluster %q", pipeline.Name, cluster)
+			}
+			if strings.TrimSpace(strings.TrimPrefix(override, "@")) == "" {
+				return fmt.Errorf("pipeline %s: query_overrides: empty query for cluster %s", pipeline.Name, cluster)
+			}
 		}
 
 		if len(pipeline.Load.Streams) == 0 {
 			return fmt.Errorf("pipeline %s: at least one load stream is required", pipeline.Name)
 		}
 
+		if pipeline.Load.MetricPrefix != "" {
+			if err := ValidateMetricPrefix(pipeline.Load.MetricPrefix); err != nil {
+				return fmt.Errorf("pipeline %s: %w", pipeline.Name, err)
+			}
+		}
+
+		// Validate stream inputs
+		if !validInput(pipeline.Load.Input) {
+			return fmt.Errorf("pipeline %s: invalid load input %q", pipeline.Name, pipeline.Load.Input)
+		}
+		for j, stream := range pipeline.Load.Streams {
+			if !validInput(stream.Input) {
+				return fmt.Errorf("pipeline %s: stream %d: invalid input %q", pipeline.Name, j, stream.Input)
+			}
+		}
+
+		if pipeline.StreamResults && len(pipeline.Transform.DedupeBy) > 0 {
+			return fmt.Errorf("pipeline %s: dedupe_by spans a batch and cannot be combined with stream_results", pipeline.Name)
+		}
+
+		switch pipeline.Load.FailurePolicy {
+		case "", FailurePolicyAll, FailurePolicyAny, FailurePolicyBestEffort:
+		default:
+			return fmt.Errorf("pipeline %s: invalid failure_policy %q (must be all, any or best_effort)", pipeline.Name, pipeline.Load.FailurePolicy)
+		}
+
+		// Reject streams sharing an output target. Disabled pipelines and streams write nothing
+		endpoints := make(map[string]int)
+		for j, stream := range pipeline.Load.Streams {
+			if !pipeline.Enabled || streamDisabled(stream) {
+				continue
+			}
+
+			if fileStreamTypes[stream.Type] {
+				key := fileStreamKey(stream)
+				if key == "" {
+					continue
+				}
+				if owner, exists := filePaths[key]; exists {
+					return fmt.Errorf("pipeline %s: stream %d: %s path %s is already written by %s", pipeline.Name, j, stream.Type, stream.Config["path"], owner)
+				}
+				filePaths[key] = fmt.Sprintf("pipeline %s stream %d", pipeline.Name, j)
+				continue
+			}
+
+			endpoint, _ := stream.Config["endpoint"].(string)
+			if endpoint == "" {
+				continue
+			}
+			key := stream.Type + " " + endpoint
+			if first, exists := endpoints[key]; exists {
+				return fmt.Errorf("pipeline %s: streams %d and %d both send to %s endpoint %s", pipeline.Name, first, j, stream.Type, endpoint)
+			}
+			endpoints[key] = j
+		}
+
+		// Validate metric timestamp columns and sources
+		for _, metric := range pipeline.Load.Metrics {
+			if metric.Timestamp < NoTimestampColumn {
+				return fmt.Errorf("pipeline %s: metric %s: timestamp must be a column index, or -1 or omitted for none", pipeline.Name, metric.Name)
+			}
+			switch metric.TimestampSource {
+			case "", TimestampSourceColumn, TimestampSourceNow:
+			default:
+				return fmt.Errorf("pipeline %s: metric %s: invalid timestamp_source %q (must be %s or %s)", pipeline.Name, metric.Name, metric.TimestampSource, TimestampSourceColumn, TimestampSourceNow)
+			}
+		}
+
 		// Validate conversion functions
 		for j, conv := range pipeline.Transform.ConversionFunctions {
 			if conv.Field == "" {
@@ -149,6 +458,125 @@ func (l *Loader) validateConfig(config *Config) error {
 			if conv.Function == "" {
 				return fmt.Errorf("pipeline %s: conversion function %d: function is required", pipeline.Name, j)
 			}
+			if !IsConversionFunction(conv.Function) {
+				return fmt.Errorf("pipeline %s: conversion function %d: unknown function %q (valid: %s)", pipeline.Name, j, conv.Function, strings.Join(ConversionFunctionNames(), ", "))
+			}
+			if conv.Function == "parse_json" {
+				switch conv.OnError {
+				case "", ParseErrorFail, ParseErrorKeep, ParseErrorDrop:
+				default:
+					return fmt.Errorf("pipeline %s: conversion function %d: invalid on_error %q", pipeline.Name, j, conv.OnError)
+				}
+			}
+			if conv.Function == "mask" {
+				switch conv.MaskMode {
+				case "", MaskSHA256, MaskRedact, MaskTruncate:
+				default:
+					return fmt.Errorf("pipeline %s: conversion function %d: invalid mask_mode %q", pipeline.Name, j, conv.MaskMode)
+				}
+				if conv.MaskLength < 0 {
+					return fmt.Errorf("pipeline %s: conversion function %d: mask_length must not be negative", pipeline.Name, j)
+				}
+			}
+			if conv.Function == "delta" || conv.Function == "rate" {
+				if pipeline.Transform.Stateless {
+					return fmt.Errorf("pipeline %s: conversion function %d: %s requires a stateful transform", pipeline.Name, j, conv.Function)
+				}
+				switch conv.CounterReset {
+				case "", CounterResetZero, CounterResetSkip, CounterResetNone:
+				default:
+					return fmt.Errorf("pipeline %s: conversion function %d: invalid counter_reset %q", pipeline.Name, j, conv.CounterReset)
+				}
+			}
+			if conv.Function == "ratio" {
+				if conv.Numerator == "" || conv.Denominator == "" {
+					return fmt.Errorf("pipeline %s: conversion function %d: ratio requires numerator and denominator", pipeline.Name, j)
+				}
+				switch conv.ZeroDenominator {
+				case "", ZeroDenominatorSkip, ZeroDenominatorZero, ZeroDenominatorNaN:
+				default:
+					return fmt.Errorf("pipeline %s: conversion function %d: invalid zero_denominator %q", pipeline.Name, j, conv.ZeroDenominator)
+				}
+				continue
+			}
+			if !conv.Literal {
+				if _, err := regexp.Compile(conv.Field); err != nil {
+					return fmt.Errorf("pipeline %s: conversion function %d: invalid field pattern %q: %w", pipeline.Name, j, conv.Field, err)
+				}
+			}
+		}
+
+		switch pipeline.Extract.ArrayIndexFormat {
+		case "", ArrayIndexFormatBracket, ArrayIndexFormatDot:
+		default:
+			return fmt.Errorf("pipeline %s: invalid array_index_format %q (must be %s or %s)", pipeline.Name, pipeline.Extract.ArrayIndexFormat, ArrayIndexFormatBracket, ArrayIndexFormatDot)
+		}
+
+		// Validate filter patterns
+		for j, filter := range pipeline.Extract.Filters {
+			if filter.Literal {
+				continue
+			}
+			if _, err := regexp.Compile(filter.Pattern); err != nil {
+				return fmt.Errorf("pipeline %s: filter %d: invalid pattern %q: %w", pipeline.Name, j, filter.Pattern, err)
+			}
+		}
+
+		switch pipeline.Transform.DedupeKeep {
+		case "", DedupeKeepFirst, DedupeKeepLast:
+		default:
+			return fmt.Errorf("pipeline %s: invalid dedupe_keep %q (must be %s or %s)", pipeline.Name, pipeline.Transform.DedupeKeep, DedupeKeepFirst, DedupeKeepLast)
+		}
+
+		if sample := pipeline.Transform.SampleRows; sample != nil {
+			if (sample.Count > 0) == (sample.Fraction > 0) {
+				return fmt.Errorf("pipeline %s: sample_rows requires exactly one of count or fraction", pipeline.Name)
+			}
+			if sample.Count < 0 || sample.Fraction < 0 || sample.Fraction > 1 {
+				return fmt.Errorf("pipeline %s: sample_rows count must be positive and fraction within (0, 1]", pipeline.Name)
+			}
+		}
+
+		// Validate lookups
+		for j, lookup := range pipeline.Transform.Lookups {
+			if lookup.File == "" || lookup.KeyField == "" {
+				return fmt.Errorf("pipeline %s: lookup %d: file and key_field are required", pipeline.Name, j)
+			}
+		}
+
+		// Validate pivot configuration
+		if pivot := pipeline.Transform.Pivot; pivot != nil {
+			if pivot.NameColumn == "" || pivot.ValueColumn == "" {
+				return fmt.Errorf("pipeline %s: pivot requires name_column and value_column", pipeline.Name)
+			}
+			if pivot.NameColumn == pivot.ValueColumn {
+				return fmt.Errorf("pipeline %s: pivot name_column and value_column must differ", pipeline.Name)
+			}
+			if pipeline.Transform.OutputFormat != "csv" {
+				return fmt.Errorf("pipeline %s: pivot requires output_format csv", pipeline.Name)
+			}
+		}
+
+		// Validate CSV column formats
+		for column, format := range pipeline.Transform.ColumnFormats {
+			if format != ColumnFormatInteger && format != ColumnFormatShortest && !floatFormatPattern.MatchString(format) {
+				return fmt.Errorf("pipeline %s: column %s: invalid format %q (must be integer, shortest or a float verb such as %%.3f)", pipeline.Name, column, format)
+			}
+		}
+
+		// Validate summarize configuration
+		if summarize := pipeline.Transform.Summarize; summarize != nil {
+			if len(summarize.KeyColumns) == 0 || summarize.ValueColumn == "" {
+				return fmt.Errorf("pipeline %s: summarize requires key_columns and value_column", pipeline.Name)
+			}
+			switch summarize.Statistic {
+			case SummarizeMin, SummarizeMax, SummarizeAvg, SummarizeSum, SummarizeFirst, SummarizeLast:
+			default:
+				return fmt.Errorf("pipeline %s: invalid summarize statistic %q (must be min, max, avg, sum, first or last)", pipeline.Name, summarize.Statistic)
+			}
+			if pipeline.Transform.OutputFormat != "csv" {
+				return fmt.Errorf("pipeline %s: summarize requires output_format csv", pipeline.Name)
+			}
 		}
 
 		// Validate time expressions
@@ -158,6 +586,14 @@ func (l *Loader) validateConfig(config *Config) error {
 		if err := utils.ValidateTimeExpression(pipeline.Extract.EndTime); err != nil {
 			return fmt.Errorf("pipeline %s: invalid end_time: %w", pipeline.Name, err)
 		}
+		if !utils.ValidTimeUnit(pipeline.Extract.TimeUnit) {
+			return fmt.Errorf("pipeline %s: invalid time_unit %q (must be s, ms or ns)", pipeline.Name, pipeline.Extract.TimeUnit)
+		}
+		if pipeline.Extract.Timezone != "" {
+			if _, err := time.LoadLocation(pipeline.Extract.Timezone); err != nil {
+				return fmt.Errorf("pipeline %s: invalid timezone %q: %w", pipeline.Name, pipeline.Extract.Timezone, err)
+			}
+		}
 
 		// Validate array lengths match
 		minLen := len(pipeline.Extract.URLs)
@@ -184,8 +620,84 @@ func (l *Loader) validateConfig(config *Config) error {
 				return fmt.Errorf("pipeline %s: cluster_name %d is empty", pipeline.Name, j)
 			}
 		}
+
+		if len(pipeline.Extract.Indices) > len(pipeline.Extract.URLs) {
+			return fmt.Errorf("pipeline %s: %d indices configured for %d URLs", pipeline.Name, len(pipeline.Extract.Indices), len(pipeline.Extract.URLs))
+		}
+		for j, indices := range pipeline.Extract.Indices {
+			for _, name := range indices {
+				if err := ValidateIndexName(name); err != nil {
+					return fmt.Errorf("pipeline %s: indices %d: %w", pipeline.Name, j, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fileStreamTypes are the stream types writing timestamped files named after their path
+var fileStreamTypes = map[string]bool{"csv": true, "debug": true, "avro": true}
+
+// fileStreamKey identifies the files a file stream writes by its type and absolute path, or
+// returns "" when it has no path. Types name their files differently, so only streams of the
+// same type can collide. Debug paths are expanded like the debug stream does
+func fileStreamKey(stream StreamConfig) string {
+	path, _ := stream.Config["path"].(string)
+	if stream.Type == "debug" {
+		path = os.Expand(path, func(name string) string {
+			if value := os.Getenv(name); value != "" {
+				return value
+			}
+			return "${" + name + "}"
+		})
+	}
+	if path == "" {
+		return ""
+	}
+
+	key := filepath.Clean(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+	return stream.Type + " " + key
+}
+
+// streamDisabled reports whether a stream's enabled key turns it off. Invalid values are
+// reported when the stream is created, so they count as enabled here
+func streamDisabled(stream StreamConfig) bool {
+	switch v := stream.Config["enabled"].(type) {
+	case bool:
+		return !v
+	case string:
+		enabled, err := ParseEnvBool(v)
+		return err == nil && !enabled
 	}
+	return false
+}
+
+// validInput reports whether input is empty or a known load input
+func validInput(input string) bool {
+	return input == "" || input == InputCSVData || input == InputTransformedData
+}
+
+// floatFormatPattern matches printf formats with a single float verb, e.g. %.3f or %8.2e
+var floatFormatPattern = regexp.MustCompile(`^[^%]*%[-+ 0#]*[0-9]*(\.[0-9]+)?[fFeEgG][^%]*$`)
 
+// scrollKeepAlivePattern matches Elasticsearch time units accepted for scroll keep-alives
+var scrollKeepAlivePattern = regexp.MustCompile(`^[0-9]+(d|h|m|s|ms)$`)
+
+// labelNamePattern matches valid Prometheus label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// metricPrefixPattern matches prefixes that keep metric names valid
+var metricPrefixPattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// ValidateMetricPrefix checks that prefix produces legal Prometheus metric names
+func ValidateMetricPrefix(prefix string) error {
+	if !metricPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid metric_prefix %q: must match %s", prefix, metricPrefixPattern.String())
+	}
 	return nil
 }
 
@@ -203,7 +715,16 @@ func (l *Loader) watchForChanges() {
 				time.Sleep(100 * time.Millisecond)
 
 				if err := l.loadConfig(); err != nil {
-					fmt.Printf("Failed to reload config: %v\n", err)
+					log.Printf("Failed to reload config: %v", err)
+
+					l.mutex.RLock()
+					errorCallbacks := make([]func(error), len(l.onError))
+					copy(errorCallbacks, l.onError)
+					l.mutex.RUnlock()
+
+					for _, callback := range errorCallbacks {
+						go callback(err)
+					}
 					continue
 				}
 
@@ -218,14 +739,14 @@ func (l *Loader) watchForChanges() {
 					go callback(config)
 				}
 
-				fmt.Println("Configuration reloaded successfully")
+				log.Println("Configuration reloaded successfully")
 			}
 
 		case err, ok := <-l.watcher.Errors:
 			if !ok {
 				return
 			}
-			fmt.Printf("Config watcher error: %v\n", err)
+			log.Printf("Config watcher error: %v", err)
 		}
 	}
 }
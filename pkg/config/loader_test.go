@@ -0,0 +1,289 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamPipeline returns an enabled pipeline loading into streams
+func streamPipeline(name string, streams ...StreamConfig) PipelineConfig {
+	return PipelineConfig{
+		Name:     name,
+		Enabled:  true,
+		Interval: time.Minute,
+		Extract: ExtractConfig{
+			URLs:               []string{"http://es:9200"},
+			ClusterNames:       []string{"es"},
+			ElasticsearchQuery: `{"query":{"match_all":{}}}`,
+		},
+		Load: LoadConfig{Streams: streams},
+	}
+}
+
+func TestValidateConfigDuplicateDestinations(t *testing.T) {
+	stream := func(streamType, path string, extra ...interface{}) StreamConfig {
+		cfg := map[string]interface{}{"path": path}
+		for i := 0; i+1 < len(extra); i += 2 {
+			cfg[extra[i].(string)] = extra[i+1]
+		}
+		return StreamConfig{Type: streamType, Config: cfg}
+	}
+
+	tests := []struct {
+		name      string
+		pipelines []PipelineConfig
+		wantErr   string
+	}{
+		{"distinct csv paths", []PipelineConfig{streamPipeline("a", stream("csv", "/out/a"), stream("csv", "/out/b"))}, ""},
+		{"csv within a pipeline", []PipelineConfig{streamPipeline("a", stream("csv", "/out/a"), stream("csv", "/out/./a"))}, "csv path"},
+		{"debug across pipelines", []PipelineConfig{streamPipeline("a", stream("debug", "/out/d")), streamPipeline("b", stream("debug", "/out/d"))}, "debug path"},
+		{"avro", []PipelineConfig{streamPipeline("a", stream("avro", "/out/x"), stream("avro", "/out/x"))}, "avro path"},
+		{"different types share a path", []PipelineConfig{streamPipeline("a", stream("csv", "/out/x"), stream("avro", "/out/x"))}, ""},
+		{"disabled stream", []PipelineConfig{streamPipeline("a", stream("csv", "/out/x"), stream("csv", "/out/x", "enabled", false))}, ""},
+		{"disabled by env string", []PipelineConfig{streamPipeline("a", stream("debug", "/out/x", "enabled", "${UNSET_DEBUG_FLAG}"), stream("debug", "/out/x"))}, ""},
+		{"disabled pipeline", []PipelineConfig{streamPipeline("a", stream("csv", "/out/x")), func() PipelineConfig {
+			p := streamPipeline("b", stream("csv", "/out/x"))
+			p.Enabled = false
+			return p
+		}()}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Loader{}).validateConfig(&Config{Pipelines: tt.pipelines})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigRegexPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  FilterConfig
+		conv    ConversionFunctionConfig
+		wantErr string
+	}{
+		{"valid patterns", FilterConfig{Type: "include", Pattern: `^hits\.`}, ConversionFunctionConfig{Field: `bytes$`, Function: "convert_to_kb", FromUnit: "bytes"}, ""},
+		{"malformed filter", FilterConfig{Type: "include", Pattern: `hits[`}, ConversionFunctionConfig{Field: "x", Function: "convert_to_kb", FromUnit: "bytes"}, `filter 0: invalid pattern "hits["`},
+		{"malformed field", FilterConfig{Type: "include", Pattern: "x"}, ConversionFunctionConfig{Field: `(bytes`, Function: "convert_to_kb", FromUnit: "bytes"}, `invalid field pattern "(bytes"`},
+		{"literal filter", FilterConfig{Type: "include", Pattern: `hits[`, Literal: true}, ConversionFunctionConfig{Field: "x", Function: "convert_to_kb", FromUnit: "bytes"}, ""},
+		{"literal field", FilterConfig{Type: "include", Pattern: "x"}, ConversionFunctionConfig{Field: `(bytes`, Literal: true, Function: "convert_to_kb", FromUnit: "bytes"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := streamPipeline("p", StreamConfig{Type: "stdout", Config: map[string]interface{}{}})
+			pipeline.Extract.Filters = []FilterConfig{tt.filter}
+			pipeline.Transform.ConversionFunctions = []ConversionFunctionConfig{tt.conv}
+			err := (&Loader{}).validateConfig(&Config{Pipelines: []PipelineConfig{pipeline}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one mentioning %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigConversionFunctionNames(t *testing.T) {
+	validate := func(function string) error {
+		pipeline := streamPipeline("p", StreamConfig{Type: "stdout", Config: map[string]interface{}{}})
+		pipeline.Transform.ConversionFunctions = []ConversionFunctionConfig{{Field: "bytes", Function: function, FromUnit: "bytes"}}
+		return (&Loader{}).validateConfig(&Config{Pipelines: []PipelineConfig{pipeline}})
+	}
+
+	if err := validate("convert_to_mb"); err != nil {
+		t.Fatalf("known function rejected: %v", err)
+	}
+
+	err := validate("convert_to_mg")
+	if err == nil {
+		t.Fatal("expected an error for an unknown function")
+	}
+	for _, want := range []string{`unknown function "convert_to_mg"`, "convert_to_mb", "convert_type"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	RegisterConversionFunction("test_plugin_function")
+	if err := validate("test_plugin_function"); err != nil {
+		t.Fatalf("registered function rejected: %v", err)
+	}
+}
+
+func TestLoaderReloadCallbacks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig := func(interval string) {
+		t.Helper()
+		content := `pipelines:
+  - name: "p"
+    enabled: true
+    interval: "` + interval + `"
+    extract:
+      elasticsearch_query: '{"query":{"match_all":{}}}'
+      urls: ["http://es:9200"]
+      cluster_names: ["es"]
+    load:
+      streams:
+        - type: "stdout"
+          config: {}
+`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeConfig("60s")
+	loader, err := NewLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	changes := make(chan *Config, 1)
+	failures := make(chan error, 1)
+	loader.OnConfigChange(func(cfg *Config) { changes <- cfg })
+	loader.OnReloadError(func(err error) { failures <- err })
+
+	writeConfig("30s")
+	select {
+	case cfg := <-changes:
+		if cfg.Pipelines[0].Interval != 30*time.Second {
+			t.Errorf("reloaded interval = %v, want 30s", cfg.Pipelines[0].Interval)
+		}
+	case err := <-failures:
+		t.Fatalf("valid reload failed: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback for a valid reload")
+	}
+
+	writeConfig("-1s")
+	select {
+	case cfg := <-changes:
+		t.Fatalf("invalid reload applied: %+v", cfg.Pipelines[0])
+	case <-failures:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no callback for an invalid reload")
+	}
+	if got := loader.GetConfig().Pipelines[0].Interval; got != 30*time.Second {
+		t.Errorf("interval after failed reload = %v, want the last valid 30s", got)
+	}
+}
+
+func TestLoaderQueryFile(t *testing.T) {
+	dir := t.TempDir()
+	query := `{"query":{"term":{"cluster":"__CLUSTER__"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "query.json"), []byte(query+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig := func(extract string) string {
+		t.Helper()
+		path := filepath.Join(dir, "config.yaml")
+		content := `pipelines:
+  - name: "p"
+    enabled: true
+    interval: "60s"
+    extract:
+` + extract + `
+      urls: ["http://es:9200"]
+      cluster_names: ["es"]
+    load:
+      streams:
+        - type: "stdout"
+          config: {}
+`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// A relative query_file is read from the config file's directory, macros left for the extractor
+	loader, err := NewLoader(writeConfig(`      query_file: "query.json"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	if got := loader.GetConfig().Pipelines[0].Extract.ElasticsearchQuery; got != query {
+		t.Errorf("query = %s, want the file contents %s", got, query)
+	}
+
+	for name, extract := range map[string]string{
+		"both set":     "      query_file: \"query.json\"\n      elasticsearch_query: '{}'",
+		"neither set":  "      timeout: \"10s\"",
+		"missing file": `      query_file: "absent.json"`,
+	} {
+		if loader, err := NewLoader(writeConfig(extract)); err == nil {
+			loader.Close()
+			t.Errorf("%s: loaded, want an error", name)
+		}
+	}
+}
+
+func TestLoaderQueryOverrides(t *testing.T) {
+	dir := t.TempDir()
+	override := `{"query":{"term":{"legacy_cluster":"__CLUSTER__"}}}`
+	if err := os.WriteFile(filepath.Join(dir, "legacy.json"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig := func(overrides string) string {
+		t.Helper()
+		path := filepath.Join(dir, "config.yaml")
+		content := `pipelines:
+  - name: "p"
+    enabled: true
+    interval: "60s"
+    extract:
+      elasticsearch_query: '{"query":{"match_all":{}}}'
+      urls: ["http://es1:9200", "http://es2:9200"]
+      cluster_names: ["current", "legacy"]
+      query_overrides:
+` + overrides + `
+    load:
+      streams:
+        - type: "stdout"
+          config: {}
+`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// An "@path" override is read relative to the config file like query_file
+	loader, err := NewLoader(writeConfig(`        legacy: "@legacy.json"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	if got := loader.GetConfig().Pipelines[0].Extract.QueryOverrides["legacy"]; got != override {
+		t.Errorf("legacy override = %s, want the file contents %s", got, override)
+	}
+
+	for name, overrides := range map[string]string{
+		"unknown cluster": `        other: '{}'`,
+		"empty query":     `        legacy: "@"`,
+		"missing file":    `        legacy: "@absent.json"`,
+	} {
+		if loader, err := NewLoader(writeConfig(overrides)); err == nil {
+			loader.Close()
+			t.Errorf("%s: loaded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import "encoding/json"
+
+// NoTimestampColumn is the PrometheusMetricConfig.Timestamp of a metric without a timestamp
+// column; it is the default when timestamp is omitted, so an unset column is not read as column 0
+const NoTimestampColumn = -1
+
+// prometheusMetricFields has the fields of PrometheusMetricConfig without its unmarshalers
+type prometheusMetricFields PrometheusMetricConfig
+
+// UnmarshalYAML decodes a metric, defaulting an omitted timestamp to NoTimestampColumn
+func (m *PrometheusMetricConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	fields := prometheusMetricFields{Timestamp: NoTimestampColumn}
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	*m = PrometheusMetricConfig(fields)
+	return nil
+}
+
+// UnmarshalJSON decodes a metric, defaulting an omitted timestamp to NoTimestampColumn
+func (m *PrometheusMetricConfig) UnmarshalJSON(data []byte) error {
+	fields := prometheusMetricFields{Timestamp: NoTimestampColumn}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*m = PrometheusMetricConfig(fields)
+	return nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestPrometheusMetricConfigTimestampDefault(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		want   int
+	}{
+		{"json omitted", "json", `{"name":"m","value":2}`, NoTimestampColumn},
+		{"json column 0", "json", `{"name":"m","value":2,"timestamp":0}`, 0},
+		{"json column 3", "json", `{"name":"m","value":2,"timestamp":3}`, 3},
+		{"yaml omitted", "yaml", "name: m\nvalue: 2\n", NoTimestampColumn},
+		{"yaml column 0", "yaml", "name: m\nvalue: 2\ntimestamp: 0\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var metric PrometheusMetricConfig
+			var err error
+			if tt.format == "json" {
+				err = json.Unmarshal([]byte(tt.data), &metric)
+			} else {
+				err = yaml.Unmarshal([]byte(tt.data), &metric)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metric.Timestamp != tt.want {
+				t.Errorf("Timestamp = %d, want %d", metric.Timestamp, tt.want)
+			}
+			if metric.Name != "m" || metric.Value != 2 {
+				t.Errorf("other fields not decoded: %+v", metric)
+			}
+		})
+	}
+}
@@ -18,30 +18,86 @@ type PipelineConfig struct {
 	Extract   ExtractConfig   `json:"extract" yaml:"extract"`
 	Transform TransformConfig `json:"transform" yaml:"transform"`
 	Load      LoadConfig      `json:"load" yaml:"load"`
+	// StreamResults transforms and loads each endpoint's result as soon as it is extracted,
+	// holding at most extract.max_concurrency results (one when unset) instead of buffering all
+	// of them. Each result is transformed on its own, so the CSV column set, pivot and summarize
+	// cover one endpoint, and previous_results_sets keeps one set per endpoint result.
+	// dedupe_by, which spans a batch, is rejected
+	StreamResults bool `json:"stream_results,omitempty" yaml:"stream_results,omitempty"`
+	// LoadQueueSize bounds the number of transformed batches waiting for a background loader;
+	// 0 loads synchronously within each run
+	LoadQueueSize int `json:"load_queue_size" yaml:"load_queue_size"`
+	// MetricLabels are constant labels (e.g. team, owner) added to this pipeline's series on the
+	// metrics server's Prometheus endpoint
+	MetricLabels map[string]string `json:"metric_labels,omitempty" yaml:"metric_labels,omitempty"`
+	// InitialDelay postpones the first execution after start, e.g. to let dependencies warm up
+	InitialDelay time.Duration `json:"initial_delay,omitempty" yaml:"initial_delay,omitempty"`
 }
 
 // ExtractConfig contains extraction configuration
 type ExtractConfig struct {
-	ElasticsearchQuery string         `json:"elasticsearch_query" yaml:"elasticsearch_query"`
-	URLs               []string       `json:"urls" yaml:"urls"`
-	ClusterNames       []string       `json:"cluster_names" yaml:"cluster_names"`
-	AuthHeaders        []string       `json:"auth_headers,omitempty" yaml:"auth_headers,omitempty"`
-	AdditionalHeaders  [][]string     `json:"additional_headers,omitempty" yaml:"additional_headers,omitempty"`
-	JSONPath           string         `json:"json_path" yaml:"json_path"`                 // Single JSON path to extract
-	Filters            []FilterConfig `json:"filters,omitempty" yaml:"filters,omitempty"` // Multiple filters for flattened keys
-	Interval           time.Duration  `json:"interval" yaml:"interval"`
-	Timeout            time.Duration  `json:"timeout" yaml:"timeout"`
-	MaxRetries         int            `json:"max_retries" yaml:"max_retries"`
-	StartTime          string         `json:"start_time,omitempty" yaml:"start_time,omitempty"`
-	EndTime            string         `json:"end_time,omitempty" yaml:"end_time,omitempty"`
-	InsecureTLS        bool           `json:"insecure_tls,omitempty" yaml:"insecure_tls,omitempty"`
-	Debug              DebugConfig    `json:"debug,omitempty" yaml:"debug,omitempty"`
+	ElasticsearchQuery   string            `json:"elasticsearch_query" yaml:"elasticsearch_query"`
+	QueryFile            string            `json:"query_file,omitempty" yaml:"query_file,omitempty"`           // File holding the query instead of elasticsearch_query, relative to the config file; re-read on reload
+	QueryOverrides       map[string]string `json:"query_overrides,omitempty" yaml:"query_overrides,omitempty"` // Query per cluster name replacing the default; "@path" reads it from a file like query_file
+	URLs                 []string          `json:"urls" yaml:"urls"`
+	ClusterNames         []string          `json:"cluster_names" yaml:"cluster_names"`
+	Indices              []IndexList       `json:"indices,omitempty" yaml:"indices,omitempty"` // Per-endpoint index/alias or comma-separated indices; when set the request targets url/index/_search
+	AuthHeaders          []string          `json:"auth_headers,omitempty" yaml:"auth_headers,omitempty"`
+	Auth                 *AuthConfig       `json:"auth,omitempty" yaml:"auth,omitempty"`                 // Structured auth for endpoints without an auth header
+	TokenFile            string            `json:"token_file,omitempty" yaml:"token_file,omitempty"`     // File holding a rotating token, re-read when it changes; used for endpoints without an auth header
+	TokenScheme          string            `json:"token_scheme,omitempty" yaml:"token_scheme,omitempty"` // Authorization scheme for token_file (default: Bearer, e.g. ApiKey)
+	AdditionalHeaders    [][]string        `json:"additional_headers,omitempty" yaml:"additional_headers,omitempty"`
+	Scroll               string            `json:"scroll,omitempty" yaml:"scroll,omitempty"`                         // Scroll keep-alive (e.g. 2m); when set all result pages are fetched and their hits merged
+	SearchAfter          bool              `json:"search_after,omitempty" yaml:"search_after,omitempty"`             // Page with search_after using the query's sort until a page returns fewer than size hits
+	Method               string            `json:"method,omitempty" yaml:"method,omitempty"`                         // POST (default) sends the query as the body; GET sends it in the source query parameter
+	AcceptGzip           bool              `json:"accept_gzip,omitempty" yaml:"accept_gzip,omitempty"`               // Request gzip or deflate compressed responses
+	QueryParams          map[string]string `json:"query_params,omitempty" yaml:"query_params,omitempty"`             // Added to the search URL query string; values support ${VAR}
+	CaptureHeaders       []string          `json:"capture_headers,omitempty" yaml:"capture_headers,omitempty"`       // Response headers copied into result metadata and surfaced as labels
+	ArrayIndexFormat     string            `json:"array_index_format,omitempty" yaml:"array_index_format,omitempty"` // bracket (key[0], default) or dot (key.0, with numeric object keys quoted: key."95.0")
+	FlattenExclude       []string          `json:"flatten_exclude,omitempty" yaml:"flatten_exclude,omitempty"`       // Paths (array indices omitted) kept as one compact JSON string instead of flattened
+	JSONPath             string            `json:"json_path" yaml:"json_path"`                                       // Single JSON path to extract
+	JSONPaths            []JSONPathConfig  `json:"json_paths,omitempty" yaml:"json_paths,omitempty"`                 // Further paths merged into the data after json_path; later paths win on key collisions
+	Filters              []FilterConfig    `json:"filters,omitempty" yaml:"filters,omitempty"`                       // Multiple filters for flattened keys
+	Interval             time.Duration     `json:"interval" yaml:"interval"`
+	Timeout              time.Duration     `json:"timeout" yaml:"timeout"`
+	Timeouts             []time.Duration   `json:"timeouts,omitempty" yaml:"timeouts,omitempty"` // Per-endpoint request timeouts aligned with urls; 0 or missing entries use timeout
+	MaxRetries           int               `json:"max_retries" yaml:"max_retries"`
+	MaxRetryDuration     time.Duration     `json:"max_retry_duration,omitempty" yaml:"max_retry_duration,omitempty"`           // Stop retrying once the next attempt would start after this much time (0 unlimited)
+	RetryableStatusCodes []int             `json:"retryable_status_codes,omitempty" yaml:"retryable_status_codes,omitempty"`   // Statuses to retry (default: 429 and 5xx)
+	SuccessStatusCodes   []int             `json:"success_status_codes,omitempty" yaml:"success_status_codes,omitempty"`       // Error statuses treated as an empty result (e.g. 404)
+	MaxRetryAfter        time.Duration     `json:"max_retry_after,omitempty" yaml:"max_retry_after,omitempty"`                 // Cap on Retry-After delays from 429/503 responses (default 60s)
+	UseJSONNumber        bool              `json:"use_json_number,omitempty" yaml:"use_json_number,omitempty"`                 // Decode numbers as json.Number to keep 64-bit integers exact
+	LastResponseMaxBytes int               `json:"last_response_max_bytes,omitempty" yaml:"last_response_max_bytes,omitempty"` // Size kept of the last raw response per endpoint (default 64KiB, -1 disables)
+	MetadataMaxBytes     int               `json:"metadata_max_bytes,omitempty" yaml:"metadata_max_bytes,omitempty"`           // Size kept of each string metadata field such as the query (default 16KiB, -1 unlimited)
+	MaxConnsPerHost      int               `json:"max_conns_per_host,omitempty" yaml:"max_conns_per_host,omitempty"`           // Concurrent connections per Elasticsearch host (default resource_limits.max_connections, 0 unlimited)
+	MaxConcurrency       int               `json:"max_concurrency,omitempty" yaml:"max_concurrency,omitempty"`                 // Endpoints queried at once (0 unlimited)
+	StartTime            string            `json:"start_time,omitempty" yaml:"start_time,omitempty"`
+	EndTime              string            `json:"end_time,omitempty" yaml:"end_time,omitempty"`
+	TimeUnit             string            `json:"time_unit,omitempty" yaml:"time_unit,omitempty"` // Unit __STARTTIME__ and __ENDTIME__ render in: s, ms (default) or ns
+	Timezone             string            `json:"timezone,omitempty" yaml:"timezone,omitempty"`   // IANA zone for start_time and end_time, e.g. America/New_York (default UTC)
+	InsecureTLS          bool              `json:"insecure_tls,omitempty" yaml:"insecure_tls,omitempty"`
+	TLS                  TLSConfig         `json:"tls,omitempty" yaml:"tls,omitempty"`                       // Client certificate and CA for Elasticsearch (mutual TLS)
+	EmitUpMetric         bool              `json:"emit_up_metric,omitempty" yaml:"emit_up_metric,omitempty"` // Push elasticetl_up per endpoint even when extraction fails
+	Debug                DebugConfig       `json:"debug,omitempty" yaml:"debug,omitempty"`
 }
 
+// Keep policies for DedupeBy
+const (
+	DedupeKeepFirst = "first"
+	DedupeKeepLast  = "last"
+)
+
+// Array index formats used when flattening JSON arrays
+const (
+	ArrayIndexFormatBracket = "bracket"
+	ArrayIndexFormatDot     = "dot"
+)
+
 // FilterConfig defines filtering rules for flattened JSON keys
 type FilterConfig struct {
-	Type    string `json:"type" yaml:"type"`       // "include" or "exclude"
-	Pattern string `json:"pattern" yaml:"pattern"` // Pattern to match against flattened keys
+	Type    string `json:"type" yaml:"type"`                           // "include" or "exclude"
+	Pattern string `json:"pattern" yaml:"pattern"`                     // Pattern to match against flattened keys
+	Literal bool   `json:"literal,omitempty" yaml:"literal,omitempty"` // Match Pattern as an exact key instead of a regex
 }
 
 // TransformConfig contains transformation configuration
@@ -49,37 +105,180 @@ type TransformConfig struct {
 	Stateless              bool                       `json:"stateless" yaml:"stateless"`
 	SubstituteZerosForNull bool                       `json:"substitute_zeros_for_null" yaml:"substitute_zeros_for_null"`
 	PreviousResultsSets    int                        `json:"previous_results_sets" yaml:"previous_results_sets"`
+	DefaultFields          map[string]interface{}     `json:"default_fields,omitempty" yaml:"default_fields,omitempty"` // Values injected for flattened fields absent from a record
 	ConversionFunctions    []ConversionFunctionConfig `json:"conversion_functions" yaml:"conversion_functions"`
-	OutputFormat           string                     `json:"output_format,omitempty" yaml:"output_format,omitempty"` // csv, json (default: json)
+	OutputFormat           string                     `json:"output_format,omitempty" yaml:"output_format,omitempty"`   // csv, json (default: json)
+	ArrayIndexFormat       string                     `json:"-" yaml:"-"`                                               // Copied from ExtractConfig.ArrayIndexFormat by the pipeline
+	GenerateCSV            bool                       `json:"-" yaml:"-"`                                               // Set by the pipeline when a stream consumes CSV data
+	SampleRows             *SampleRowsConfig          `json:"sample_rows,omitempty" yaml:"sample_rows,omitempty"`       // Deterministically sample CSV rows per result
+	DedupeBy               []string                   `json:"dedupe_by,omitempty" yaml:"dedupe_by,omitempty"`           // Fields (or CSV columns) identifying duplicate records across a batch
+	DedupeKeep             string                     `json:"dedupe_keep,omitempty" yaml:"dedupe_keep,omitempty"`       // first (default) or last
+	Lookups                []LookupConfig             `json:"lookups,omitempty" yaml:"lookups,omitempty"`               // Enrich records from local lookup tables
+	Pivot                  *PivotConfig               `json:"pivot,omitempty" yaml:"pivot,omitempty"`                   // Reshape long CSV rows into wide form
+	Summarize              *SummarizeConfig           `json:"summarize,omitempty" yaml:"summarize,omitempty"`           // Reduce CSV rows per series to one summary row
+	ColumnFormats          map[string]string          `json:"column_formats,omitempty" yaml:"column_formats,omitempty"` // CSV column -> number format: integer, shortest (default) or a printf float verb such as %.3f
+	MissingValue           string                     `json:"missing_value,omitempty" yaml:"missing_value,omitempty"`   // CSV cell for columns a record lacks, e.g. \N, so they differ from empty strings (default: empty)
+	CacheSchema            bool                       `json:"cache_schema,omitempty" yaml:"cache_schema,omitempty"`     // Reuse CSV columns of known flattened keys across runs
+}
+
+// Number formats for TransformConfig.ColumnFormats besides printf float verbs
+const (
+	ColumnFormatInteger  = "integer"  // Rounded to a whole number
+	ColumnFormatShortest = "shortest" // Shortest fixed-point form that round-trips
+)
+
+// SampleRowsConfig caps CSV rows per result with a deterministic sample; set Count or Fraction
+type SampleRowsConfig struct {
+	Count    int     `json:"count,omitempty" yaml:"count,omitempty"`       // Maximum rows kept per result
+	Fraction float64 `json:"fraction,omitempty" yaml:"fraction,omitempty"` // Fraction of rows kept per result, in (0, 1]
+	Seed     int64   `json:"seed,omitempty" yaml:"seed,omitempty"`         // Seed for row selection
+}
+
+// LookupConfig defines enrichment of records from a CSV or JSON lookup file
+type LookupConfig struct {
+	File      string                 `json:"file" yaml:"file"`                                 // .csv (header row) or .json (object of key -> fields)
+	KeyField  string                 `json:"key_field" yaml:"key_field"`                       // Flattened record field whose value is looked up; inside arrays such as aggregation buckets, the path without indices (e.g. aggregations.by_node.buckets.key)
+	KeyColumn string                 `json:"key_column,omitempty" yaml:"key_column,omitempty"` // CSV key column (default: first column)
+	Prefix    string                 `json:"prefix,omitempty" yaml:"prefix,omitempty"`         // Prepended to merged field names
+	Default   map[string]interface{} `json:"default,omitempty" yaml:"default,omitempty"`       // Fields merged when the key is missing
+}
+
+// PivotConfig defines a long-to-wide reshape of CSV rows
+type PivotConfig struct {
+	NameColumn  string `json:"name_column" yaml:"name_column"`   // Column whose distinct values become new columns
+	ValueColumn string `json:"value_column" yaml:"value_column"` // Column holding the values placed in the new columns
 }
 
+// SummarizeConfig reduces the CSV rows of each series (e.g. date_histogram buckets) to one row
+type SummarizeConfig struct {
+	KeyColumns  []string `json:"key_columns" yaml:"key_columns"`   // Columns identifying a series
+	ValueColumn string   `json:"value_column" yaml:"value_column"` // Numeric column reduced to the statistic
+	Statistic   string   `json:"statistic" yaml:"statistic"`       // min, max, avg, sum, first or last
+}
+
+// Statistics for SummarizeConfig
+const (
+	SummarizeMin   = "min"
+	SummarizeMax   = "max"
+	SummarizeAvg   = "avg"
+	SummarizeSum   = "sum"
+	SummarizeFirst = "first"
+	SummarizeLast  = "last"
+)
+
 // ConversionFunctionConfig defines field conversion functions
 type ConversionFunctionConfig struct {
-	Field    string `json:"field" yaml:"field"`       // Flattened field path
-	Function string `json:"function" yaml:"function"` // convert_type, convert_to_kb, convert_to_mb, convert_to_gb
-	FromType string `json:"from_type,omitempty" yaml:"from_type,omitempty"`
-	ToType   string `json:"to_type,omitempty" yaml:"to_type,omitempty"`
-	FromUnit string `json:"from_unit,omitempty" yaml:"from_unit,omitempty"`
-	ToUnit   string `json:"to_unit,omitempty" yaml:"to_unit,omitempty"`
+	Field    string   `json:"field" yaml:"field"`                           // Flattened field path (regex unless Literal is set); output field for ratio
+	Function string   `json:"function" yaml:"function"`                     // convert_type, convert_to_kb, convert_to_mb, convert_to_gb, ratio, parse_json, to_bool_numeric, sanitize_string, mask, delta, rate
+	Literal  bool     `json:"literal,omitempty" yaml:"literal,omitempty"`   // Match Field as an exact key instead of a regex
+	Clusters []string `json:"clusters,omitempty" yaml:"clusters,omitempty"` // Apply only to results from these clusters (default: all)
+	Sources  []string `json:"sources,omitempty" yaml:"sources,omitempty"`   // Apply only to results from these source URLs (default: all)
+	Queries  []string `json:"queries,omitempty" yaml:"queries,omitempty"`   // Apply only to results of these queries: query_overrides keys, or DefaultQueryName (default: all)
+	FromType string   `json:"from_type,omitempty" yaml:"from_type,omitempty"`
+	ToType   string   `json:"to_type,omitempty" yaml:"to_type,omitempty"`
+	FromUnit string   `json:"from_unit,omitempty" yaml:"from_unit,omitempty"`
+	ToUnit   string   `json:"to_unit,omitempty" yaml:"to_unit,omitempty"`
+
+	// Ratio settings: Field = Numerator / Denominator (exact flattened keys)
+	Numerator       string `json:"numerator,omitempty" yaml:"numerator,omitempty"`
+	Denominator     string `json:"denominator,omitempty" yaml:"denominator,omitempty"`
+	ZeroDenominator string `json:"zero_denominator,omitempty" yaml:"zero_denominator,omitempty"` // skip (default), zero, nan
+
+	// parse_json settings: matched string fields are replaced by their flattened JSON content
+	Prefix  string `json:"prefix,omitempty" yaml:"prefix,omitempty"`     // Key prefix for the parsed fields (default: the source field)
+	OnError string `json:"on_error,omitempty" yaml:"on_error,omitempty"` // fail (default), keep, drop
+
+	// to_bool_numeric settings: values in the truthy set become 1, anything else 0
+	TruthyValues []string `json:"truthy_values,omitempty" yaml:"truthy_values,omitempty"` // Case-insensitive (default: true, yes, y, on, enabled, and non-zero numbers)
+
+	// delta/rate settings: matched counters become their change (per second for rate) since the previous run
+	CounterReset string `json:"counter_reset,omitempty" yaml:"counter_reset,omitempty"` // zero (default), skip, none
+
+	// mask settings: matched string fields are hashed, redacted or truncated
+	MaskMode   string `json:"mask_mode,omitempty" yaml:"mask_mode,omitempty"`     // sha256 (default), redact, truncate
+	MaskSalt   string `json:"mask_salt,omitempty" yaml:"mask_salt,omitempty"`     // Prepended to the value before hashing
+	MaskLength int    `json:"mask_length,omitempty" yaml:"mask_length,omitempty"` // Characters kept by truncate (default: 4)
 }
 
+// Error policies for the parse_json conversion function
+const (
+	ParseErrorFail = "fail" // Fail the transform
+	ParseErrorKeep = "keep" // Leave the field unchanged
+	ParseErrorDrop = "drop" // Remove the field
+)
+
+// Counter reset policies for the delta and rate conversion functions
+const (
+	CounterResetZero = "zero" // Treat the previous value as zero, so the change is the current value
+	CounterResetSkip = "skip" // Leave the field unset for the run
+	CounterResetNone = "none" // Keep the negative change
+)
+
+// Modes for the mask conversion function
+const (
+	MaskSHA256   = "sha256"   // Replace the value with the hex SHA-256 of salt + value
+	MaskRedact   = "redact"   // Replace the value with a fixed placeholder
+	MaskTruncate = "truncate" // Keep only the first mask_length characters
+)
+
+// DefaultQueryName is the query name of results queried with elasticsearch_query or query_file
+// rather than a query_overrides entry, whose results are named after the override's key
+const DefaultQueryName = "default"
+
+// Zero denominator policies for the ratio conversion function
+const (
+	ZeroDenominatorSkip = "skip" // Leave the output field unset
+	ZeroDenominatorZero = "zero" // Write 0
+	ZeroDenominatorNaN  = "nan"  // Leave the output field unset, as NaN is not a valid sample value
+)
+
 // LoadConfig contains load configuration
 type LoadConfig struct {
-	Input        string                   `json:"input" yaml:"input"`                         // Input data type: "csv_data", "transformed_data"
+	Input        string                   `json:"input" yaml:"input"`                         // Default input for streams: "csv_data", "transformed_data"
 	Metrics      []PrometheusMetricConfig `json:"metrics,omitempty" yaml:"metrics,omitempty"` // Metrics configuration for all streams
 	Streams      []StreamConfig           `json:"streams" yaml:"streams"`
+	MetricPrefix string                   `json:"metric_prefix,omitempty" yaml:"metric_prefix,omitempty"` // Prepended to emitted metric names; streams may override with their own metric_prefix
 	LabelColumns []string                 `json:"label_columns,omitempty" yaml:"label_columns,omitempty"` // Columns to use as labels
+	// MaxConnsPerHost limits concurrent connections per host for HTTP streams (default
+	// resource_limits.max_connections, 0 unlimited); streams may override with max_conns_per_host
+	MaxConnsPerHost int `json:"max_conns_per_host,omitempty" yaml:"max_conns_per_host,omitempty"`
+	// MaxSeriesPerRun caps the series loaded per run; 0 is unlimited. A series is the CSV rows
+	// sharing the metrics' uniquefieldsIndex and label columns (each row without metrics), or a
+	// transformed data field of a result without CSV data, and is kept or dropped whole.
+	// SeriesPriorityColumn names a numeric CSV column whose highest values are kept first,
+	// otherwise series are truncated in order
+	MaxSeriesPerRun      int    `json:"max_series_per_run,omitempty" yaml:"max_series_per_run,omitempty"`
+	SeriesPriorityColumn string `json:"series_priority_column,omitempty" yaml:"series_priority_column,omitempty"`
+	// StreamTimeout bounds each stream's Load, including retries, so a slow stream cannot delay
+	// the batch past it (0 unlimited); streams may override with load_timeout
+	StreamTimeout time.Duration `json:"stream_timeout,omitempty" yaml:"stream_timeout,omitempty"`
+	// FailurePolicy decides when stream failures fail the load: all (default), any or best_effort
+	FailurePolicy string `json:"failure_policy,omitempty" yaml:"failure_policy,omitempty"`
 }
 
 // StreamConfig defines a single load stream
 type StreamConfig struct {
-	Type        string                 `json:"type" yaml:"type"` // gem, otel, prometheus, debug, csv
+	Type        string                 `json:"type" yaml:"type"` // gem, otel, prometheus, debug, csv, stdout, syslog, exec, avro
 	Config      map[string]interface{} `json:"config" yaml:"config"`
 	BasicAuth   *BasicAuthConfig       `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
 	InsecureTLS bool                   `json:"insecure_tls,omitempty" yaml:"insecure_tls,omitempty"`
 	Labels      map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Input       string                 `json:"input,omitempty" yaml:"input,omitempty"` // csv_data or transformed_data; overrides LoadConfig.Input
 }
 
+// Load inputs a stream can consume
+const (
+	InputCSVData         = "csv_data"         // Use CSV rows when present
+	InputTransformedData = "transformed_data" // Use the flattened transformed data
+)
+
+// Load failure policies
+const (
+	FailurePolicyAll        = "all"         // Fail if any stream fails
+	FailurePolicyAny        = "any"         // Fail only if every stream fails
+	FailurePolicyBestEffort = "best_effort" // Never fail; stream failures are only logged
+)
+
 // BasicAuthConfig defines basic authentication configuration
 type BasicAuthConfig struct {
 	Username string `json:"username" yaml:"username"`
@@ -88,9 +287,12 @@ type BasicAuthConfig struct {
 
 // GlobalConfig contains global application settings
 type GlobalConfig struct {
-	ResourceLimits ResourceLimits `json:"resource_limits" yaml:"resource_limits"`
-	Metrics        MetricsConfig  `json:"metrics" yaml:"metrics"`
-	Logging        LoggingConfig  `json:"logging" yaml:"logging"`
+	ResourceLimits     ResourceLimits `json:"resource_limits" yaml:"resource_limits"`
+	Metrics            MetricsConfig  `json:"metrics" yaml:"metrics"`
+	Logging            LoggingConfig  `json:"logging" yaml:"logging"`
+	StartupConcurrency int            `json:"startup_concurrency,omitempty" yaml:"startup_concurrency,omitempty"` // Max pipelines starting (and running their first execution) at once; 0 starts all together
+	StartupWindow      time.Duration  `json:"startup_window,omitempty" yaml:"startup_window,omitempty"`           // Spread staggered starts over this window
+	ShutdownTimeout    time.Duration  `json:"shutdown_timeout,omitempty" yaml:"shutdown_timeout,omitempty"`       // Time allowed to stop pipelines and the metrics server (default 30s)
 }
 
 // ResourceLimits defines resource consumption limits
@@ -103,24 +305,75 @@ type ResourceLimits struct {
 
 // MetricsConfig defines metrics collection settings
 type MetricsConfig struct {
-	Enabled  bool          `json:"enabled" yaml:"enabled"`
-	Port     int           `json:"port" yaml:"port"`
-	Path     string        `json:"path" yaml:"path"`
-	Interval time.Duration `json:"interval" yaml:"interval"`
+	Enabled    bool             `json:"enabled" yaml:"enabled"`
+	Port       int              `json:"port" yaml:"port"`
+	Path       string           `json:"path" yaml:"path"`
+	Interval   time.Duration    `json:"interval" yaml:"interval"`
+	GRPCHealth GRPCHealthConfig `json:"grpc_health,omitempty" yaml:"grpc_health,omitempty"`
+	// ShutdownReportFile, if set, receives the shutdown report in addition to the log
+	ShutdownReportFile string `json:"shutdown_report_file,omitempty" yaml:"shutdown_report_file,omitempty"`
+	// DebugToken enables /debug/ endpoints for requests sending it as a bearer token; supports ${VAR}
+	DebugToken string `json:"debug_token,omitempty" yaml:"debug_token,omitempty"`
+	// HeartbeatInterval, if set, advances the heartbeat counter on this cadence regardless of pipeline runs
+	HeartbeatInterval time.Duration `json:"heartbeat_interval,omitempty" yaml:"heartbeat_interval,omitempty"`
+	// RuntimeMetrics adds the standard go_* runtime and process_* metrics to the Prometheus endpoint
+	RuntimeMetrics bool `json:"runtime_metrics,omitempty" yaml:"runtime_metrics,omitempty"`
+}
+
+// GRPCHealthConfig defines the optional gRPC health checking server
+type GRPCHealthConfig struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+	Port    int  `json:"port" yaml:"port"`
 }
 
 // LoggingConfig defines logging settings
 type LoggingConfig struct {
-	Level  string `json:"level" yaml:"level"`
-	Format string `json:"format" yaml:"format"` // json, text
-	Output string `json:"output" yaml:"output"` // stdout, file
-	File   string `json:"file,omitempty" yaml:"file,omitempty"`
+	Level      string `json:"level" yaml:"level"`
+	Format     string `json:"format" yaml:"format"` // json, text
+	Output     string `json:"output" yaml:"output"` // stdout, file
+	File       string `json:"file,omitempty" yaml:"file,omitempty"`
+	MaxSizeMB  int    `json:"max_size_mb,omitempty" yaml:"max_size_mb,omitempty"`   // Rotate the log file at this size (default 100)
+	MaxBackups int    `json:"max_backups,omitempty" yaml:"max_backups,omitempty"`   // Rotated files to keep (default all)
+	MaxAgeDays int    `json:"max_age_days,omitempty" yaml:"max_age_days,omitempty"` // Days to keep rotated files (default forever)
+	Compress   bool   `json:"compress,omitempty" yaml:"compress,omitempty"`         // Gzip rotated files
+}
+
+// TLSConfig holds PEM files for TLS connections to Elasticsearch
+type TLSConfig struct {
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"` // Client certificate presented to the server
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`   // Private key for cert_file
+	CAFile   string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`     // CA bundle used instead of the system roots to verify the server
+}
+
+// AuthConfig builds the extraction Authorization header. Values support ${VAR}
+type AuthConfig struct {
+	Type         string        `json:"type" yaml:"type"`                                       // apikey, bearer or basic
+	APIKey       string        `json:"api_key,omitempty" yaml:"api_key,omitempty"`             // Base64 encoded id:key, sent as "ApiKey <value>"
+	Token        string        `json:"token,omitempty" yaml:"token,omitempty"`                 // Static bearer token
+	TokenCommand []string      `json:"token_command,omitempty" yaml:"token_command,omitempty"` // Command and arguments printing a bearer token (or OAuth JSON with expires_in)
+	TokenTTL     time.Duration `json:"token_ttl,omitempty" yaml:"token_ttl,omitempty"`         // Cache duration for command tokens without expires_in (default: 5m)
+	Username     string        `json:"username,omitempty" yaml:"username,omitempty"`
+	Password     string        `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// Extraction auth types
+const (
+	AuthAPIKey = "apikey"
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+)
+
+// JSONPathConfig is one of several JSON paths extracted from the same response
+type JSONPathConfig struct {
+	Path       string `json:"path" yaml:"path"`
+	Prefix     string `json:"prefix,omitempty" yaml:"prefix,omitempty"`           // Prepended to the flattened keys (default: metric_name, else the path)
+	MetricName string `json:"metric_name,omitempty" yaml:"metric_name,omitempty"` // Metric family for the path's values; the rest of each key becomes the field label
 }
 
 // DebugConfig defines debug settings for extraction phase
 type DebugConfig struct {
-	Enabled bool   `json:"enabled" yaml:"enabled"`
-	Path    string `json:"path,omitempty" yaml:"path,omitempty"`
+	Enabled EnvBool `json:"enabled" yaml:"enabled"`               // true/false or a string such as "${DEBUG_ENABLED}"
+	Path    string  `json:"path,omitempty" yaml:"path,omitempty"` // Supports ${VAR}
 }
 
 // DynamicLabelConfig defines how to create labels from CSV data
@@ -149,10 +402,17 @@ type PrometheusMetricConfig struct {
 	Name              string                  `json:"name" yaml:"name"`
 	UniqueFieldsIndex []int                   `json:"uniquefieldsIndex" yaml:"uniquefieldsIndex"`
 	Value             int                     `json:"value" yaml:"value"`
-	Timestamp         int                     `json:"timestamp" yaml:"timestamp"`
+	Timestamp         int                     `json:"timestamp" yaml:"timestamp"`                                   // CSV column of the sample timestamp; NoTimestampColumn (-1, the default when omitted) for none
+	TimestampSource   string                  `json:"timestamp_source,omitempty" yaml:"timestamp_source,omitempty"` // "column" (default) or "now" to fall back to extraction time
 	Labels            []PrometheusLabelConfig `json:"labels,omitempty" yaml:"labels,omitempty"`
 }
 
+// Timestamp sources for PrometheusMetricConfig
+const (
+	TimestampSourceColumn = "column"
+	TimestampSourceNow    = "now"
+)
+
 // PrometheusLabelConfig defines label configuration for Prometheus metrics
 type PrometheusLabelConfig struct {
 	LabelName      string `json:"label_name" yaml:"label_name"`
@@ -0,0 +1,114 @@
+package extract
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+const (
+	// defaultTokenTTL is how long a token_command token is cached when its output has no expiry
+	defaultTokenTTL = 5 * time.Minute
+	// tokenExpirySkew refreshes command tokens this long before they expire
+	tokenExpirySkew = 30 * time.Second
+	// tokenCommandTimeout bounds a single token_command run
+	tokenCommandTimeout = 30 * time.Second
+)
+
+// tokenCommand serves a short-lived bearer token produced by an external command, running the
+// command again only once the cached token is about to expire. The command prints either the
+// bare token or an OAuth-style JSON object with access_token and expires_in (seconds)
+type tokenCommand struct {
+	command []string
+	ttl     time.Duration
+	mutex   sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// newTokenCommand creates a token source running command, caching tokens without an expiry for ttl
+func newTokenCommand(command []string, ttl time.Duration) *tokenCommand {
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	return &tokenCommand{command: command, ttl: ttl}
+}
+
+// Token returns the cached token, running the command when it is missing or about to expire
+func (t *tokenCommand) Token(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.token != "" && time.Now().Add(tokenExpirySkew).Before(t.expires) {
+		return t.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tokenCommandTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, t.command[0], t.command[1:]...)
+	cmd.WaitDelay = time.Second
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("token command failed: %w", err)
+	}
+
+	token, lifetime := parseTokenOutput(output)
+	if token == "" {
+		return "", fmt.Errorf("token command printed no token")
+	}
+	if lifetime <= 0 {
+		lifetime = t.ttl
+	}
+
+	t.token = token
+	t.expires = time.Now().Add(lifetime)
+	return token, nil
+}
+
+// parseTokenOutput reads a token_command's output as an OAuth token response, falling back to
+// the trimmed output as the token itself
+func parseTokenOutput(output []byte) (string, time.Duration) {
+	var response struct {
+		AccessToken string  `json:"access_token"`
+		Token       string  `json:"token"`
+		ExpiresIn   float64 `json:"expires_in"`
+	}
+	if err := json.Unmarshal(output, &response); err == nil {
+		token := response.AccessToken
+		if token == "" {
+			token = response.Token
+		}
+		return token, time.Duration(response.ExpiresIn * float64(time.Second))
+	}
+	return strings.TrimSpace(string(output)), 0
+}
+
+// authorization builds the Authorization header value for the structured auth config
+func (e *Extractor) authorization(ctx context.Context, auth *config.AuthConfig) (string, error) {
+	switch auth.Type {
+	case config.AuthAPIKey:
+		return "ApiKey " + substituteEnvVars(auth.APIKey), nil
+	case config.AuthBasic:
+		credentials := substituteEnvVars(auth.Username) + ":" + substituteEnvVars(auth.Password)
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), nil
+	case config.AuthBearer:
+		if e.tokenCommand != nil {
+			token, err := e.tokenCommand.Token(ctx)
+			if err != nil {
+				return "", err
+			}
+			return "Bearer " + token, nil
+		}
+		return "Bearer " + substituteEnvVars(auth.Token), nil
+	default:
+		return "", fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+}
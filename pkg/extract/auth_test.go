@@ -0,0 +1,88 @@
+package extract
+
+import (
+	"context"
+	"encoding/base64"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestAuthorization(t *testing.T) {
+	t.Setenv("AUTH_TEST_PASSWORD", "secret")
+	extractor := &Extractor{}
+	authorization := func(auth config.AuthConfig) string {
+		t.Helper()
+		header, err := extractor.authorization(context.Background(), &auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return header
+	}
+
+	if got := authorization(config.AuthConfig{Type: config.AuthAPIKey, APIKey: "abc"}); got != "ApiKey abc" {
+		t.Errorf("apikey authorization = %q, want ApiKey abc", got)
+	}
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("u:secret"))
+	if got := authorization(config.AuthConfig{Type: config.AuthBasic, Username: "u", Password: "${AUTH_TEST_PASSWORD}"}); got != want {
+		t.Errorf("basic authorization = %q, want %q", got, want)
+	}
+	if got := authorization(config.AuthConfig{Type: config.AuthBearer, Token: "t"}); got != "Bearer t" {
+		t.Errorf("bearer authorization = %q, want Bearer t", got)
+	}
+	if _, err := extractor.authorization(context.Background(), &config.AuthConfig{Type: "digest"}); err == nil {
+		t.Error("expected an error for an unknown auth type")
+	}
+}
+
+func TestParseTokenOutput(t *testing.T) {
+	token, ttl := parseTokenOutput([]byte(`{"access_token":"a","expires_in":60}`))
+	if token != "a" || ttl != time.Minute {
+		t.Errorf("OAuth response = %q, %v; want a, 1m", token, ttl)
+	}
+	token, ttl = parseTokenOutput([]byte(`{"token":"b"}`))
+	if token != "b" || ttl != 0 {
+		t.Errorf("token field = %q, %v; want b without expiry", token, ttl)
+	}
+	token, ttl = parseTokenOutput([]byte("  c\n"))
+	if token != "c" || ttl != 0 {
+		t.Errorf("plain text = %q, %v; want c without expiry", token, ttl)
+	}
+}
+
+func TestTokenCommandCachesToken(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	// Each run appends a line to runs and prints the line count, so the token shows the run
+	runs := filepath.Join(t.TempDir(), "runs")
+	script := "echo x >> " + runs + "; wc -l < " + runs
+
+	source := newTokenCommand([]string{"sh", "-c", script}, time.Hour)
+	for i := 0; i < 2; i++ {
+		token, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "1" {
+			t.Errorf("call %d token = %q, want the first run's token cached", i+1, token)
+		}
+	}
+
+	// A token within the expiry skew of expiring is refreshed
+	expiring := newTokenCommand([]string{"sh", "-c", script + ` | sed 's/.*/{"token":"&","expires_in":10}/'`}, time.Hour)
+	first, err := expiring.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := expiring.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("token %q reused within the expiry skew", first)
+	}
+}
@@ -0,0 +1,59 @@
+package extract
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// acceptEncoding is sent when accept_gzip is enabled. Setting it explicitly turns off the
+// transport's transparent decompression, so responses are decoded by readResponseBody
+const acceptEncoding = "gzip, deflate"
+
+// readResponseBody reads a response body, decoding gzip and deflate content encodings
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	reader, err := decodedBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// decodedBody wraps body in a decompressor for the given Content-Encoding
+func decodedBody(body io.Reader, encoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		reader, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+		return reader, nil
+	case "deflate":
+		// deflate is meant to be zlib-wrapped, but some servers send raw deflate data
+		buffered := bufio.NewReader(body)
+		header, err := buffered.Peek(2)
+		if err == nil && isZlibHeader(header) {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode deflate response: %w", err)
+			}
+			return reader, nil
+		}
+		return flate.NewReader(buffered), nil
+	default:
+		return nil, fmt.Errorf("unsupported response content encoding %q", encoding)
+	}
+}
+
+// isZlibHeader reports whether header is a zlib stream header using the deflate method
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
@@ -0,0 +1,91 @@
+package extract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"elasticetl/pkg/config"
+)
+
+const encodedText = `{"hits":{"total":{"value":3}}}`
+
+// compressText returns encodedText compressed by the writer newWriter creates
+func compressText(t *testing.T, newWriter func(io.Writer) (io.WriteCloser, error)) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, encodedText)
+	w.Close()
+	return buf.Bytes()
+}
+
+// decodeText decodes body with the given Content-Encoding
+func decodeText(t *testing.T, body []byte, encoding string) string {
+	t.Helper()
+	reader, err := decodedBody(bytes.NewReader(body), encoding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(decoded)
+}
+
+func TestDecodedBody(t *testing.T) {
+	gzipped := compressText(t, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+	zlibbed := compressText(t, func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil })
+	rawDeflate := compressText(t, func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.DefaultCompression) })
+
+	if got := decodeText(t, []byte(encodedText), ""); got != encodedText {
+		t.Errorf("plain body decoded to %q", got)
+	}
+	if got := decodeText(t, gzipped, "GZIP"); got != encodedText {
+		t.Errorf("gzip body decoded to %q", got)
+	}
+
+	// deflate should be zlib-wrapped, but raw deflate data is accepted too
+	if got := decodeText(t, zlibbed, "deflate"); got != encodedText {
+		t.Errorf("zlib deflate body decoded to %q", got)
+	}
+	if got := decodeText(t, rawDeflate, "deflate"); got != encodedText {
+		t.Errorf("raw deflate body decoded to %q", got)
+	}
+
+	if _, err := decodedBody(bytes.NewReader(nil), "br"); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestExtractAcceptGzip(t *testing.T) {
+	gzipped := compressText(t, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != acceptEncoding {
+			t.Errorf("Accept-Encoding = %q, want %q", r.Header.Get("Accept-Encoding"), acceptEncoding)
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{AcceptGzip: true})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Data["hits.total"] != 3.0 {
+		t.Errorf("results = %+v, want the decoded hit count", results)
+	}
+}
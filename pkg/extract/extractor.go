@@ -3,19 +3,23 @@ package extract
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"elasticetl/pkg/config"
+	"elasticetl/pkg/logging"
 	"elasticetl/pkg/utils"
 
 	"github.com/tidwall/gjson"
@@ -42,34 +46,111 @@ type Result struct {
 	Metadata  map[string]interface{} `json:"metadata"`
 }
 
+// EndpointStatus reports the outcome of the last extraction from a single endpoint
+type EndpointStatus struct {
+	URL         string
+	ClusterName string
+	Up          bool
+	Error       string
+	Latency     time.Duration // Time taken by the endpoint's request, including retries
+}
+
+// RawResponse is the most recent response body received from an endpoint, with secrets redacted
+type RawResponse struct {
+	StatusCode int
+	Body       []byte
+	Truncated  bool // Body was cut to the capture limit
+	CapturedAt time.Time
+}
+
+// defaultLastResponseMaxBytes limits the captured response body when no limit is configured
+const defaultLastResponseMaxBytes = 64 * 1024
+
+// defaultMetadataMaxBytes limits each string metadata field when no limit is configured
+const defaultMetadataMaxBytes = 16 * 1024
+
+// secretFieldPattern matches JSON fields whose names suggest they hold credentials and whose
+// values are strings, numbers or booleans. Object and array values, and secrets in bodies that
+// are not JSON, are not matched
+var secretFieldPattern = regexp.MustCompile(`(?i)("[^"]*(?:password|passwd|secret|token|api_?key|authorization|credential)[^"]*"\s*:\s*)(?:"(?:[^"\\]|\\.)*"|-?[0-9][0-9.eE+-]*|true|false)`)
+
 // Extractor handles data extraction from Elasticsearch
 type Extractor struct {
 	config           config.ExtractConfig
 	httpClient       *http.Client
+	tlsStamp         string // tls file stamp the client was built from
 	macroSubstituter *utils.MacroSubstituter
+	lastStatus       []EndpointStatus
+	lastResponses    map[int]*RawResponse
+	tokenFile        *tokenFile    // Set when token_file is configured
+	tokenCommand     *tokenCommand // Set when auth.token_command is configured
+	logger           *logging.Logger
 	mutex            sync.RWMutex
 }
 
-// NewExtractor creates a new extractor
-func NewExtractor(cfg config.ExtractConfig) *Extractor {
-	macroSubstituter := utils.NewMacroSubstituter(cfg.StartTime, cfg.EndTime)
+// NewExtractor creates a new extractor logging through logger
+func NewExtractor(cfg config.ExtractConfig, logger *logging.Logger) (*Extractor, error) {
+	macroSubstituter, err := utils.NewMacroSubstituter(cfg.StartTime, cfg.EndTime, cfg.TimeUnit, cfg.Timezone)
+	if err != nil {
+		return nil, err
+	}
 
-	// Configure HTTP client with TLS settings
-	transport := &http.Transport{}
-	if cfg.InsecureTLS {
-		transport.TLSClientConfig = &tls.Config{
-			InsecureSkipVerify: true,
-		}
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	return &Extractor{
+	extractor := &Extractor{
 		config:           cfg,
 		macroSubstituter: macroSubstituter,
-		httpClient: &http.Client{
-			Timeout:   cfg.Timeout,
-			Transport: transport,
-		},
+		lastResponses:    make(map[int]*RawResponse),
+		httpClient:       httpClient,
+		tlsStamp:         tlsFileStamp(cfg.TLS),
+		logger:           logger,
+	}
+	if cfg.TokenFile != "" {
+		extractor.tokenFile = newTokenFile(cfg.TokenFile)
 	}
+	if cfg.Auth != nil && len(cfg.Auth.TokenCommand) > 0 {
+		extractor.tokenCommand = newTokenCommand(cfg.Auth.TokenCommand, cfg.Auth.TokenTTL)
+	}
+
+	return extractor, nil
+}
+
+// newHTTPClient creates the HTTP client for cfg's timeout, TLS and per-host connection settings
+func newHTTPClient(cfg config.ExtractConfig) (*http.Client, error) {
+	// Configure HTTP client with TLS settings
+	tlsConfig, err := clientTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	transport := &http.Transport{
+		MaxConnsPerHost: cfg.MaxConnsPerHost,
+		TLSClientConfig: tlsConfig,
+	}
+
+	// Timeouts are applied per request so endpoints can have their own (see endpointTimeout)
+	return &http.Client{
+		Transport: transport,
+	}, nil
+}
+
+// endpointTimeout returns the request timeout for the endpoint at index: its timeouts entry
+// when set, otherwise the shared timeout
+func (e *Extractor) endpointTimeout(index int) time.Duration {
+	if len(e.config.Timeouts) > index && e.config.Timeouts[index] > 0 {
+		return e.config.Timeouts[index]
+	}
+	return e.config.Timeout
+}
+
+// withOptionalTimeout derives a cancellable context from ctx, with a deadline when timeout is set
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 // Extract performs data extraction from all configured endpoints
@@ -77,20 +158,17 @@ func (e *Extractor) Extract(ctx context.Context) ([]*Result, error) {
 	var results []*Result
 	var wg sync.WaitGroup
 
-	// Calculate minimum length to avoid index out of bounds
-	minLen := len(e.config.URLs)
-	if len(e.config.ClusterNames) < minLen {
-		minLen = len(e.config.ClusterNames)
-	}
-	if len(e.config.AuthHeaders) > 0 && len(e.config.AuthHeaders) < minLen {
-		minLen = len(e.config.AuthHeaders)
-	}
-	if len(e.config.AdditionalHeaders) > 0 && len(e.config.AdditionalHeaders) < minLen {
-		minLen = len(e.config.AdditionalHeaders)
-	}
+	minLen := e.endpointCount()
 
 	resultsChan := make(chan *Result, minLen)
 	errorsChan := make(chan error, minLen)
+	statuses := make([]EndpointStatus, minLen)
+
+	// Bound the endpoints queried at once when max_concurrency is set
+	var semaphore chan struct{}
+	if e.config.MaxConcurrency > 0 {
+		semaphore = make(chan struct{}, e.config.MaxConcurrency)
+	}
 
 	// Extract from all endpoints concurrently
 	for i := 0; i < minLen; i++ {
@@ -98,9 +176,14 @@ func (e *Extractor) Extract(ctx context.Context) ([]*Result, error) {
 		go func(index int) {
 			defer wg.Done()
 
-			result, err := e.extractFromEndpoint(ctx, index)
+			if semaphore != nil {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+			}
+
+			result, err := e.extractEndpoint(ctx, index, statuses)
 			if err != nil {
-				errorsChan <- fmt.Errorf("endpoint %s: %w", e.config.URLs[index], err)
+				errorsChan <- err
 				return
 			}
 
@@ -140,19 +223,187 @@ func (e *Extractor) Extract(ctx context.Context) ([]*Result, error) {
 		}
 	}
 
+	e.mutex.Lock()
+	e.lastStatus = statuses
+	e.mutex.Unlock()
+
 	// Return error if all extractions failed
 	if len(results) == 0 && len(errors) > 0 {
 		return nil, fmt.Errorf("all extractions failed: %v", errors)
 	}
 
-	// Debug output after extract phase if enabled
-	if e.config.Debug.Enabled && e.config.Debug.Path != "" {
-		if err := e.writeDebugOutput(results); err != nil {
-			fmt.Printf("Failed to write debug output: %v\n", err)
+	e.debugOutput(results)
+	return results, nil
+}
+
+// ExtractEach extracts from the configured endpoints, passing each result to handle as it
+// arrives. max_concurrency endpoints are queried at once (one when unset), and a finished query
+// waits until handle has taken the previous result, so at most that many results are held in
+// memory. handle is never called concurrently. It returns the number of results handled, and an
+// error only if every endpoint failed
+func (e *Extractor) ExtractEach(ctx context.Context, handle func(*Result)) (int, error) {
+	minLen := e.endpointCount()
+	statuses := make([]EndpointStatus, minLen)
+
+	concurrency := e.config.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	semaphore := make(chan struct{}, concurrency)
+
+	// Unbuffered, so each worker keeps its slot until its result is handed over
+	resultsChan := make(chan *Result)
+	errorsChan := make(chan error, minLen)
+
+	var wg sync.WaitGroup
+	for i := 0; i < minLen; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			result, err := e.extractEndpoint(ctx, index, statuses)
+			if err != nil {
+				errorsChan <- err
+				return
+			}
+			if result != nil {
+				resultsChan <- result
+			}
+		}(i)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+		close(errorsChan)
+	}()
+
+	handled := 0
+	for result := range resultsChan {
+		e.debugOutput([]*Result{result})
+		handle(result)
+		handled++
+	}
+
+	var errors []error
+	for err := range errorsChan {
+		errors = append(errors, err)
+	}
+
+	e.mutex.Lock()
+	e.lastStatus = statuses
+	e.mutex.Unlock()
+
+	if handled == 0 && len(errors) > 0 {
+		return 0, fmt.Errorf("all extractions failed: %v", errors)
+	}
+
+	return handled, nil
+}
+
+// extractEndpoint extracts from the endpoint at index, recording its outcome and latency in
+// statuses[index]. Errors name the endpoint
+func (e *Extractor) extractEndpoint(ctx context.Context, index int, statuses []EndpointStatus) (*Result, error) {
+	statuses[index] = EndpointStatus{
+		URL:         e.config.URLs[index],
+		ClusterName: e.config.ClusterNames[index],
+		Up:          true,
+	}
+
+	requestStart := time.Now()
+	result, err := e.extractFromEndpoint(ctx, index)
+	statuses[index].Latency = time.Since(requestStart)
+	if err != nil {
+		statuses[index].Up = false
+		statuses[index].Error = err.Error()
+		return nil, fmt.Errorf("endpoint %s: %w", e.config.URLs[index], err)
+	}
+	return result, nil
+}
+
+// debugOutput writes results to the debug path after the extract phase, if enabled
+func (e *Extractor) debugOutput(results []*Result) {
+	if !e.config.Debug.Enabled || e.config.Debug.Path == "" {
+		return
+	}
+	if err := e.writeDebugOutput(results); err != nil {
+		e.logger.Printf("Failed to write debug output: %v", err)
+	}
+}
+
+// endpointCount returns the number of fully configured endpoints, guarding against
+// mismatched URL, cluster name and header slices
+func (e *Extractor) endpointCount() int {
+	minLen := len(e.config.URLs)
+	if len(e.config.ClusterNames) < minLen {
+		minLen = len(e.config.ClusterNames)
+	}
+	if len(e.config.AuthHeaders) > 0 && len(e.config.AuthHeaders) < minLen {
+		minLen = len(e.config.AuthHeaders)
+	}
+	if len(e.config.AdditionalHeaders) > 0 && len(e.config.AdditionalHeaders) < minLen {
+		minLen = len(e.config.AdditionalHeaders)
+	}
+	return minLen
+}
+
+// isRetryableStatus reports whether a response status should be retried: any listed
+// retryable status code, or 429 and 5xx when none are configured
+func (e *Extractor) isRetryableStatus(statusCode int) bool {
+	if len(e.config.RetryableStatusCodes) > 0 {
+		return containsStatus(e.config.RetryableStatusCodes, statusCode)
+	}
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// containsStatus reports whether statusCode is in codes
+func containsStatus(codes []int, statusCode int) bool {
+	for _, code := range codes {
+		if code == statusCode {
+			return true
 		}
 	}
+	return false
+}
 
-	return results, nil
+// searchURL builds the _search URL for one or more indices or aliases on a cluster URL
+func searchURL(clusterURL string, indices config.IndexList) string {
+	return strings.TrimSuffix(clusterURL, "/") + "/" + indices.String() + "/_search"
+}
+
+// withQueryParams adds params (with environment variable substitution in values) to the
+// query string of rawURL, overriding any parameters of the same name
+func withQueryParams(rawURL string, params map[string]string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	query := parsed.Query()
+	for key, value := range params {
+		query.Set(key, substituteEnvVars(value))
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String(), nil
+}
+
+// withSourceParam adds query as the source parameter of rawURL, for sending searches with GET
+func withSourceParam(rawURL, query string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	params := parsed.Query()
+	params.Set("source", query)
+	params.Set("source_content_type", "application/json")
+	parsed.RawQuery = params.Encode()
+
+	return parsed.String(), nil
 }
 
 // extractFromEndpoint extracts data from a single endpoint by index
@@ -161,23 +412,75 @@ func (e *Extractor) extractFromEndpoint(ctx context.Context, index int) (*Result
 	clusterName := e.config.ClusterNames[index]
 
 	// Substitute macros in the query
-	processedQuery, err := e.macroSubstituter.SubstituteQuery(e.config.ElasticsearchQuery, clusterName)
+	originalQuery, queryName := e.config.ElasticsearchQuery, config.DefaultQueryName
+	if override, ok := e.config.QueryOverrides[clusterName]; ok {
+		originalQuery, queryName = override, clusterName
+	}
+	processedQuery, err := e.macroSubstituter.SubstituteQuery(originalQuery, clusterName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to substitute macros in query: %w", err)
 	}
 
+	// Target the endpoint's index/alias if configured, otherwise the URL as given
+	targetURL := url
+	if len(e.config.Indices) > index && len(e.config.Indices[index]) > 0 {
+		targetURL = searchURL(url, e.config.Indices[index])
+	}
+
+	if len(e.config.QueryParams) > 0 {
+		targetURL, err = withQueryParams(targetURL, e.config.QueryParams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Open a scroll context so pages beyond the first can be fetched
+	if e.config.Scroll != "" {
+		targetURL, err = withScrollParam(targetURL, e.config.Scroll)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Prepare Elasticsearch query - use raw query string directly
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBufferString(processedQuery))
+	var req *http.Request
+	if strings.EqualFold(e.config.Method, http.MethodGet) {
+		// Proxies that block POST to _search still accept the query in the source parameter
+		targetURL, err = withSourceParam(targetURL, processedQuery)
+		if err != nil {
+			return nil, err
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, targetURL, bytes.NewBufferString(processedQuery))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-
 	// Add auth header if provided (with environment variable substitution)
 	if len(e.config.AuthHeaders) > index && e.config.AuthHeaders[index] != "" {
 		authHeader := substituteEnvVars(e.config.AuthHeaders[index])
 		req.Header.Set("Authorization", authHeader)
+	} else if e.config.Auth != nil {
+		authHeader, err := e.authorization(ctx, e.config.Auth)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", authHeader)
+	} else if e.tokenFile != nil {
+		token, err := e.tokenFile.Token()
+		if err != nil {
+			return nil, err
+		}
+		scheme := e.config.TokenScheme
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+		req.Header.Set("Authorization", scheme+" "+token)
 	}
 
 	// Add additional headers if provided (with environment variable substitution)
@@ -199,41 +502,111 @@ func (e *Extractor) extractFromEndpoint(ctx context.Context, index int) (*Result
 		}
 	}
 
-	// Execute request with retries
+	if e.config.AcceptGzip {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	// Execute request with retries, each attempt bounded by the endpoint's timeout. The last
+	// attempt's context stays open until its response body has been read
 	var resp *http.Response
 	var lastErr error
+	attempts := 0
+	retryStart := time.Now()
+	timeout := e.endpointTimeout(index)
+	cancelAttempt := context.CancelFunc(func() {})
+	defer func() { cancelAttempt() }()
 
 	for attempt := 0; attempt <= e.config.MaxRetries; attempt++ {
-		resp, lastErr = e.httpClient.Do(req)
-		if lastErr == nil && resp.StatusCode < 500 {
-			break
+		cancelAttempt()
+		var attemptCtx context.Context
+		attemptCtx, cancelAttempt = withOptionalTimeout(ctx, timeout)
+		req = req.WithContext(attemptCtx)
+
+		// The previous attempt consumed the body, so rewind it to resend the full query
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
 		}
 
-		if resp != nil {
-			resp.Body.Close()
+		resp, lastErr = e.httpClient.Do(req)
+		attempts++
+		if lastErr == nil && !e.isRetryableStatus(resp.StatusCode) {
+			break
 		}
 
 		if attempt < e.config.MaxRetries {
-			time.Sleep(time.Duration(attempt+1) * time.Second)
+			// Honor Retry-After on throttling responses, otherwise back off linearly
+			delay := utils.RetryDelay(resp, time.Duration(attempt+1)*time.Second, e.config.MaxRetryAfter)
+
+			// Give up early rather than retry past max_retry_duration
+			if e.config.MaxRetryDuration > 0 && time.Since(retryStart)+delay > e.config.MaxRetryDuration {
+				break
+			}
+
+			if resp != nil {
+				resp.Body.Close()
+			}
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("retry wait cancelled: %w", ctx.Err())
+			}
 		}
 	}
 
 	if lastErr != nil {
-		return nil, fmt.Errorf("request failed after %d retries: %w", e.config.MaxRetries, lastErr)
+		if errors.Is(lastErr, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("cluster %s: request timed out after %s (%d attempts): %w", clusterName, timeout, attempts, lastErr)
+		}
+		return nil, fmt.Errorf("request failed after %d attempts: %w", attempts, lastErr)
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		body, _ := io.ReadAll(resp.Body)
+		// Statuses listed as successful (e.g. 404 for an empty index) mean no data
+		if containsStatus(e.config.SuccessStatusCodes, resp.StatusCode) {
+			return nil, nil
+		}
+		body, _ := readResponseBody(resp)
+		e.captureResponse(index, resp.StatusCode, body)
+		if e.isRetryableStatus(resp.StatusCode) && attempts > 1 {
+			return nil, fmt.Errorf("retries exhausted after %d attempts, last response HTTP %d: %s", attempts, resp.StatusCode, string(body))
+		}
 		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
 	}
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readResponseBody(resp)
 	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil, fmt.Errorf("cluster %s: response read timed out after %s: %w", clusterName, timeout, err)
+		}
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	// Round trip time including retries, up to the first response page being read
+	httpLatency := time.Since(retryStart)
+	e.captureResponse(index, resp.StatusCode, body)
+
+	// Merge the remaining scroll pages into the response
+	if e.config.Scroll != "" {
+		endpoint, err := scrollURL(url, len(e.config.Indices) > index && len(e.config.Indices[index]) > 0)
+		if err != nil {
+			return nil, err
+		}
+		body, err = e.followScroll(ctx, body, endpoint, req.Header, timeout)
+		if err != nil {
+			return nil, err
+		}
+	} else if e.config.SearchAfter {
+		body, err = e.followSearchAfter(ctx, body, targetURL, req.Header, processedQuery, timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// Extract data using JSON paths
 	extractedData, err := e.extractDataFromResponse(body)
@@ -246,43 +619,154 @@ func (e *Extractor) extractFromEndpoint(ctx context.Context, index int) (*Result
 		Source:    url,
 		Data:      extractedData,
 		Metadata: map[string]interface{}{
-			"endpoint":       url,
-			"cluster_name":   clusterName,
-			"query":          processedQuery,
-			"original_query": e.config.ElasticsearchQuery,
-			"response_size":  len(body),
+			"endpoint":        url,
+			"cluster_name":    clusterName,
+			"query":           processedQuery,
+			"original_query":  originalQuery,
+			"query_name":      queryName,
+			"response_size":   len(body),
+			"http_latency_ms": httpLatency.Milliseconds(),
+			"status_code":     resp.StatusCode,
+			"attempts":        attempts,
 		},
 	}
+	if len(e.config.Indices) > index && len(e.config.Indices[index]) > 0 {
+		result.Metadata["index"] = e.config.Indices[index].String()
+	}
+
+	if families := e.metricFamilies(); len(families) > 0 {
+		result.Metadata["metric_families"] = families
+	}
+
+	// Capture configured response headers
+	if len(e.config.CaptureHeaders) > 0 {
+		headers := make(map[string]string)
+		for _, name := range e.config.CaptureHeaders {
+			if value := resp.Header.Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		result.Metadata["headers"] = headers
+	}
+
+	e.truncateMetadata(result.Metadata)
 
 	return result, nil
 }
 
+// truncateMetadata shortens string metadata fields and captured header values longer than
+// metadata_max_bytes, marking each with the number of bytes removed
+func (e *Extractor) truncateMetadata(metadata map[string]interface{}) {
+	maxBytes := e.config.MetadataMaxBytes
+	if maxBytes < 0 {
+		return
+	}
+	if maxBytes == 0 {
+		maxBytes = defaultMetadataMaxBytes
+	}
+
+	for key, value := range metadata {
+		switch v := value.(type) {
+		case string:
+			metadata[key] = truncateString(v, maxBytes)
+		case map[string]string:
+			for name, s := range v {
+				v[name] = truncateString(s, maxBytes)
+			}
+		}
+	}
+}
+
+// truncateString cuts s to at most maxBytes on a UTF-8 boundary, appending a truncation marker
+func truncateString(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + fmt.Sprintf("...[truncated %d bytes]", len(s)-cut)
+}
+
+// jsonPathPrefix returns the key prefix for a json_paths entry: the configured prefix, else the
+// metric name, else the path itself
+func jsonPathPrefix(path config.JSONPathConfig) string {
+	switch {
+	case path.Prefix != "":
+		return path.Prefix
+	case path.MetricName != "":
+		return path.MetricName
+	default:
+		return path.Path
+	}
+}
+
+// metricFamilies maps the key prefix of each json_paths entry with a metric_name to that name
+func (e *Extractor) metricFamilies() map[string]string {
+	families := make(map[string]string)
+	for _, path := range e.config.JSONPaths {
+		if path.MetricName != "" {
+			families[jsonPathPrefix(path)] = path.MetricName
+		}
+	}
+	return families
+}
+
+// unmarshalJSON decodes response JSON, keeping numbers as json.Number when use_json_number
+// is set so 64-bit counters are not rounded through float64
+func (e *Extractor) unmarshalJSON(data []byte, v interface{}) error {
+	if !e.config.UseJSONNumber {
+		return json.Unmarshal(data, v)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
 // extractDataFromResponse extracts data from Elasticsearch response using single JSON path and flattens it
 func (e *Extractor) extractDataFromResponse(responseBody []byte) (map[string]interface{}, error) {
-	if e.config.JSONPath == "" {
+	if e.config.JSONPath == "" && len(e.config.JSONPaths) == 0 {
 		// If no JSON path specified, return the entire response flattened
 		var data interface{}
-		if err := json.Unmarshal(responseBody, &data); err != nil {
+		if err := e.unmarshalJSON(responseBody, &data); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
 		return e.flattenJSON(data, ""), nil
 	}
 
 	responseStr := string(responseBody)
-	result := gjson.Get(responseStr, e.config.JSONPath)
+	flattened := make(map[string]interface{})
 
-	if !result.Exists() {
-		return make(map[string]interface{}), nil
+	// json_path flattens without a prefix; each of json_paths under its prefix, in order, so
+	// later paths overwrite earlier keys on collision
+	paths := make([]config.JSONPathConfig, 0, len(e.config.JSONPaths)+1)
+	if e.config.JSONPath != "" {
+		paths = append(paths, config.JSONPathConfig{Path: e.config.JSONPath})
 	}
-
-	// Parse the extracted JSON
-	var extractedData interface{}
-	if err := json.Unmarshal([]byte(result.Raw), &extractedData); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal extracted JSON: %w", err)
+	for _, path := range e.config.JSONPaths {
+		path.Prefix = jsonPathPrefix(path)
+		paths = append(paths, path)
 	}
 
-	// Flatten the extracted data
-	flattened := e.flattenJSON(extractedData, "")
+	for _, path := range paths {
+		result := gjson.Get(responseStr, path.Path)
+		if !result.Exists() {
+			continue
+		}
+
+		// Parse the extracted JSON
+		var extractedData interface{}
+		if err := e.unmarshalJSON([]byte(result.Raw), &extractedData); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal extracted JSON at %s: %w", path.Path, err)
+		}
+
+		// Flatten the extracted data
+		for key, value := range e.flattenJSON(extractedData, path.Prefix) {
+			flattened[key] = value
+		}
+	}
 
 	// Apply filters
 	filtered := e.applyFilters(flattened)
@@ -292,8 +776,115 @@ func (e *Extractor) extractDataFromResponse(responseBody []byte) (map[string]int
 
 // flattenJSON recursively flattens a JSON structure
 func (e *Extractor) flattenJSON(data interface{}, prefix string) map[string]interface{} {
+	return FlattenJSONExcluding(data, prefix, e.config.ArrayIndexFormat, e.config.FlattenExclude)
+}
+
+// FlattenJSON recursively flattens a JSON structure into dotted keys, writing array indices
+// in the given array index format
+func FlattenJSON(data interface{}, prefix string, arrayIndexFormat string) map[string]interface{} {
+	return FlattenJSONExcluding(data, prefix, arrayIndexFormat, nil)
+}
+
+// bracketIndexPattern matches bracket format array indices in flattened keys
+var bracketIndexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// SplitFlattenedKey splits a flattened key into its dot-separated segments, keeping quoted
+// segments whole. The dot array index format quotes numeric object keys, e.g. "95.0"
+func SplitFlattenedKey(key string) []string {
+	var segments []string
+	start, quoted := 0, false
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case '"':
+			quoted = !quoted
+		case '.':
+			if !quoted {
+				segments = append(segments, key[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(segments, key[start:])
+}
+
+// numericKey reports whether an object key consists of digits and dots, such as a status code
+// bucket, a 95.0 percentile or an IP address
+func numericKey(key string) bool {
+	digits := false
+	for _, r := range key {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = true
+		case r != '.':
+			return false
+		}
+	}
+	return digits
+}
+
+// isDigits reports whether s is a non-empty run of digits, as a dot format array index is
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// dotFormatKey returns an object key as written by the dot array index format. Numeric keys
+// are quoted so their segments cannot be mistaken for array indices
+func dotFormatKey(key string) string {
+	if numericKey(key) {
+		return `"` + key + `"`
+	}
+	return key
+}
+
+// excludedPath reports whether a flattened key names one of the exclude paths. Array indices
+// are ignored, so "hits.hits._source" matches the _source of every hit
+func excludedPath(key string, exclude []string, arrayIndexFormat string) bool {
+	if len(exclude) == 0 || key == "" {
+		return false
+	}
+	normalized := key
+	if arrayIndexFormat == config.ArrayIndexFormatDot {
+		var segments []string
+		for _, segment := range SplitFlattenedKey(key) {
+			if !isDigits(segment) {
+				segments = append(segments, segment)
+			}
+		}
+		normalized = strings.Join(segments, ".")
+	} else {
+		normalized = bracketIndexPattern.ReplaceAllString(key, "")
+	}
+	for _, path := range exclude {
+		if normalized == path {
+			return true
+		}
+	}
+	return false
+}
+
+// FlattenJSONExcluding flattens like FlattenJSON, except subtrees at the exclude paths are
+// stored as a single compact JSON string instead of being flattened
+func FlattenJSONExcluding(data interface{}, prefix string, arrayIndexFormat string, exclude []string) map[string]interface{} {
 	result := make(map[string]interface{})
 
+	if excludedPath(prefix, exclude, arrayIndexFormat) {
+		switch data.(type) {
+		case map[string]interface{}, []interface{}:
+			if encoded, err := json.Marshal(data); err == nil {
+				result[prefix] = string(encoded)
+				return result
+			}
+		}
+	}
+
 	switch v := data.(type) {
 	case map[string]interface{}:
 		// Handle single key-value pair with "value" key (case insensitive)
@@ -313,12 +904,15 @@ func (e *Extractor) flattenJSON(data interface{}, prefix string) map[string]inte
 
 		// Regular object flattening
 		for key, value := range v {
+			if arrayIndexFormat == config.ArrayIndexFormatDot {
+				key = dotFormatKey(key)
+			}
 			newKey := key
 			if prefix != "" {
 				newKey = prefix + "." + key
 			}
 
-			flattened := e.flattenJSON(value, newKey)
+			flattened := FlattenJSONExcluding(value, newKey, arrayIndexFormat, exclude)
 			for k, v := range flattened {
 				result[k] = v
 			}
@@ -328,11 +922,16 @@ func (e *Extractor) flattenJSON(data interface{}, prefix string) map[string]inte
 		// Handle arrays - create multiple rows
 		for i, item := range v {
 			indexKey := fmt.Sprintf("%s[%d]", prefix, i)
-			if prefix == "" {
+			if arrayIndexFormat == config.ArrayIndexFormatDot {
+				indexKey = fmt.Sprintf("%s.%d", prefix, i)
+				if prefix == "" {
+					indexKey = strconv.Itoa(i)
+				}
+			} else if prefix == "" {
 				indexKey = fmt.Sprintf("[%d]", i)
 			}
 
-			flattened := e.flattenJSON(item, indexKey)
+			flattened := FlattenJSONExcluding(item, indexKey, arrayIndexFormat, exclude)
 			for k, v := range flattened {
 				result[k] = v
 			}
@@ -379,14 +978,14 @@ func (e *Extractor) applyFilters(data map[string]interface{}) map[string]interfa
 		if filter.Type == "exclude" {
 			// Remove keys that match the filter
 			for key := range result {
-				if e.matchesFilter(key, filter.Pattern) {
+				if e.matchesFilter(key, filter) {
 					delete(result, key)
 				}
 			}
 		} else if filter.Type == "include" {
 			// Add keys that match the filter
 			for key, value := range data {
-				if e.matchesFilter(key, filter.Pattern) {
+				if e.matchesFilter(key, filter) {
 					result[key] = value
 				}
 			}
@@ -396,33 +995,143 @@ func (e *Extractor) applyFilters(data map[string]interface{}) map[string]interfa
 	return result
 }
 
-// matchesFilter checks if a key matches a filter pattern using regular expressions
-func (e *Extractor) matchesFilter(key, pattern string) bool {
-	// Compile the regular expression pattern
-	regex, err := regexp.Compile(pattern)
+// matchesFilter checks if a key matches a filter pattern using regular expressions,
+// or exact string match for literal filters
+func (e *Extractor) matchesFilter(key string, filter config.FilterConfig) bool {
+	if filter.Literal {
+		return filter.Pattern == key
+	}
+
+	// Compile the regular expression pattern; patterns are validated at config load
+	regex, err := regexp.Compile(filter.Pattern)
 	if err != nil {
-		// If pattern is invalid regex, fall back to exact string match
-		return pattern == key
+		return false
 	}
 
 	// Use regex to match the key
 	return regex.MatchString(key)
 }
 
+// GetEndpointStatus returns the outcome of the last extraction for each endpoint
+func (e *Extractor) GetEndpointStatus() []EndpointStatus {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	// Return a copy to prevent external modification
+	status := make([]EndpointStatus, len(e.lastStatus))
+	copy(status, e.lastStatus)
+	return status
+}
+
+// captureResponse keeps the redacted, size-capped body of the latest response from an endpoint
+func (e *Extractor) captureResponse(index, statusCode int, body []byte) {
+	e.mutex.RLock()
+	maxBytes := e.config.LastResponseMaxBytes
+	e.mutex.RUnlock()
+
+	if maxBytes < 0 {
+		return
+	}
+	if maxBytes == 0 {
+		maxBytes = defaultLastResponseMaxBytes
+	}
+
+	captured := &RawResponse{
+		StatusCode: statusCode,
+		CapturedAt: time.Now(),
+	}
+	// Redact before truncating, so a secret cut by the limit still matches
+	body = redactSecrets(body)
+	if len(body) > maxBytes {
+		body = body[:maxBytes]
+		captured.Truncated = true
+	}
+	captured.Body = body
+
+	e.mutex.Lock()
+	e.lastResponses[index] = captured
+	e.mutex.Unlock()
+}
+
+// redactSecrets replaces the values of credential-like JSON fields in body
+func redactSecrets(body []byte) []byte {
+	return secretFieldPattern.ReplaceAll(body, []byte(`$1"[REDACTED]"`))
+}
+
+// GetLastResponse returns the latest captured response from the endpoint at index
+func (e *Extractor) GetLastResponse(index int) (*RawResponse, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	captured, ok := e.lastResponses[index]
+	if !ok {
+		return nil, false
+	}
+
+	// Return a copy to prevent external modification
+	response := *captured
+	return &response, true
+}
+
 // UpdateConfig updates the extractor configuration
-func (e *Extractor) UpdateConfig(cfg config.ExtractConfig) {
+func (e *Extractor) UpdateConfig(cfg config.ExtractConfig) error {
+	macroSubstituter, err := utils.NewMacroSubstituter(cfg.StartTime, cfg.EndTime, cfg.TimeUnit, cfg.Timezone)
+	if err != nil {
+		return err
+	}
+
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
+	// Transport settings cannot change in place, so build a new client when they differ. The
+	// certificate files are also re-read when they changed on disk, so a reload picks up
+	// certificates renewed under the same paths
+	tlsStamp := tlsFileStamp(cfg.TLS)
+	if cfg.MaxConnsPerHost != e.config.MaxConnsPerHost || cfg.InsecureTLS != e.config.InsecureTLS || cfg.TLS != e.config.TLS || tlsStamp != e.tlsStamp {
+		httpClient, err := newHTTPClient(cfg)
+		if err != nil {
+			return err
+		}
+		e.httpClient.CloseIdleConnections()
+		e.httpClient = httpClient
+		e.tlsStamp = tlsStamp
+	}
+
+	// Keep the cached token unless the file changed
+	if cfg.TokenFile != e.config.TokenFile {
+		e.tokenFile = nil
+		if cfg.TokenFile != "" {
+			e.tokenFile = newTokenFile(cfg.TokenFile)
+		}
+	}
+
+	// Likewise keep the command token unless the command or its TTL changed
+	var command, previous []string
+	var ttl, previousTTL time.Duration
+	if cfg.Auth != nil {
+		command, ttl = cfg.Auth.TokenCommand, cfg.Auth.TokenTTL
+	}
+	if e.config.Auth != nil {
+		previous, previousTTL = e.config.Auth.TokenCommand, e.config.Auth.TokenTTL
+	}
+	if !slices.Equal(command, previous) || ttl != previousTTL {
+		e.tokenCommand = nil
+		if len(command) > 0 {
+			e.tokenCommand = newTokenCommand(command, ttl)
+		}
+	}
+
 	e.config = cfg
-	e.httpClient.Timeout = cfg.Timeout
-	e.macroSubstituter = utils.NewMacroSubstituter(cfg.StartTime, cfg.EndTime)
+	e.lastResponses = make(map[int]*RawResponse) // Endpoint indices may refer to different URLs now
+	e.macroSubstituter = macroSubstituter
+	return nil
 }
 
 // writeDebugOutput writes extraction results to debug file
 func (e *Extractor) writeDebugOutput(results []*Result) error {
 	// Create debug directory if it doesn't exist
-	debugDir := filepath.Dir(e.config.Debug.Path)
+	debugPath := substituteEnvVars(e.config.Debug.Path)
+	debugDir := filepath.Dir(debugPath)
 	if err := os.MkdirAll(debugDir, 0755); err != nil {
 		return fmt.Errorf("failed to create debug directory: %w", err)
 	}
@@ -443,7 +1152,7 @@ func (e *Extractor) writeDebugOutput(results []*Result) error {
 
 	// Generate filename with timestamp
 	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("%s_extract_%s.json", filepath.Base(e.config.Debug.Path), timestamp)
+	filename := fmt.Sprintf("%s_extract_%s.json", filepath.Base(debugPath), timestamp)
 	fullPath := filepath.Join(debugDir, filename)
 
 	// Write to file
@@ -451,6 +1160,6 @@ func (e *Extractor) writeDebugOutput(results []*Result) error {
 		return fmt.Errorf("failed to write debug file: %w", err)
 	}
 
-	fmt.Printf("Debug output written to: %s\n", fullPath)
+	e.logger.Printf("Debug output written to: %s", fullPath)
 	return nil
 }
@@ -0,0 +1,822 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestRedactSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"string", `{"password":"hunter2"}`, `{"password":"[REDACTED]"}`},
+		{"escaped quote", `{"api_key": "a\"b"}`, `{"api_key": "[REDACTED]"}`},
+		{"number", `{"token":12345,"n":1}`, `{"token":"[REDACTED]","n":1}`},
+		{"boolean", `{"secret_enabled":true}`, `{"secret_enabled":"[REDACTED]"}`},
+		{"case insensitive name", `{"X-Authorization":"Bearer x"}`, `{"X-Authorization":"[REDACTED]"}`},
+		{"other fields kept", `{"user":"bob","count":3}`, `{"user":"bob","count":3}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactSecrets([]byte(tt.body))); got != tt.want {
+				t.Errorf("redactSecrets(%s) = %s, want %s", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaptureResponseRedactsBeforeTruncating(t *testing.T) {
+	secret := strings.Repeat("s", 40)
+	body := `{"hits":1,"password":"` + secret + `"}`
+
+	// The limit falls inside the secret, which must still be redacted
+	limit := strings.Index(body, secret) + 10
+	e := &Extractor{
+		config:        config.ExtractConfig{LastResponseMaxBytes: limit},
+		lastResponses: make(map[int]*RawResponse),
+	}
+	e.captureResponse(0, 200, []byte(body))
+
+	captured, ok := e.GetLastResponse(0)
+	if !ok {
+		t.Fatal("response not captured")
+	}
+	if strings.Contains(string(captured.Body), "ssss") {
+		t.Fatalf("captured body leaks the secret: %s", captured.Body)
+	}
+	if !captured.Truncated || len(captured.Body) > limit {
+		t.Fatalf("body not truncated to %d bytes: %q", limit, captured.Body)
+	}
+}
+
+// newTestExtractor creates an extractor querying n copies of url
+func newTestExtractor(t *testing.T, url string, n int, cfg config.ExtractConfig) *Extractor {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		cfg.URLs = append(cfg.URLs, url)
+		cfg.ClusterNames = append(cfg.ClusterNames, fmt.Sprintf("c%d", i))
+	}
+	if cfg.ElasticsearchQuery == "" {
+		cfg.ElasticsearchQuery = `{"query":{"match_all":{}}}`
+	}
+	extractor, err := NewExtractor(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return extractor
+}
+
+func TestExtractEachConcurrency(t *testing.T) {
+	tests := []struct {
+		name           string
+		maxConcurrency int
+		want           int32
+	}{
+		{"unset queries one at a time", 0, 1},
+		{"bounded by max_concurrency", 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inflight, peak atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := inflight.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				inflight.Add(-1)
+				io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+			}))
+			defer server.Close()
+
+			extractor := newTestExtractor(t, server.URL, 6, config.ExtractConfig{MaxConcurrency: tt.maxConcurrency})
+			handling := false
+			handled, err := extractor.ExtractEach(context.Background(), func(*Result) {
+				if handling {
+					t.Error("handle called concurrently")
+				}
+				handling = true
+				time.Sleep(time.Millisecond)
+				handling = false
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if handled != 6 {
+				t.Errorf("handled %d results, want 6", handled)
+			}
+			if got := peak.Load(); got != tt.want {
+				t.Errorf("peak concurrent queries = %d, want %d", got, tt.want)
+			}
+			if statuses := extractor.GetEndpointStatus(); len(statuses) != 6 || !statuses[5].Up {
+				t.Errorf("statuses not recorded: %+v", statuses)
+			}
+		})
+	}
+}
+
+func TestFlattenJSONArrayIndexFormat(t *testing.T) {
+	doc := map[string]interface{}{
+		"hosts":       []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "b"}},
+		"percentiles": map[string]interface{}{"95.0": 12.0},
+		"status":      map[string]interface{}{"200": 3.0},
+	}
+	tests := []struct {
+		name   string
+		format string
+		want   map[string]interface{}
+	}{
+		{"bracket", config.ArrayIndexFormatBracket, map[string]interface{}{
+			"hosts[0].name": "a", "hosts[1].name": "b", "percentiles.95.0": 12.0, "status.200": 3.0,
+		}},
+		{"dot quotes numeric object keys", config.ArrayIndexFormatDot, map[string]interface{}{
+			"hosts.0.name": "a", "hosts.1.name": "b", `percentiles."95.0"`: 12.0, `status."200"`: 3.0,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FlattenJSON(doc, "", tt.format)
+			if len(got) != len(tt.want) {
+				t.Fatalf("FlattenJSON = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %v, want %v (got %v)", key, got[key], value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestFlattenJSONExcluding(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"a":{"b":1},"big":{"x":[1,2],"y":{"z":3}},"items":[{"raw":{"k":1},"n":2}]}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// Exclude paths leave out array indices, so items.raw matches inside every array element
+	got := FlattenJSONExcluding(doc, "", "", []string{"big", "items.raw"})
+	want := map[string]interface{}{
+		"a.b":          1.0,
+		"big":          `{"x":[1,2],"y":{"z":3}}`,
+		"items[0].raw": `{"k":1}`,
+		"items[0].n":   2.0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FlattenJSONExcluding = %v, want %v", got, want)
+	}
+}
+
+func TestExtractDataFromResponseJSONPaths(t *testing.T) {
+	response := []byte(`{"hits":{"total":{"value":7}},"aggregations":{"by_node":{"buckets":[{"key":"n1","doc_count":3}]}},"status":{"total":{"value":9}}}`)
+	extractor := newTestExtractor(t, "http://127.0.0.1:1", 1, config.ExtractConfig{
+		JSONPath: "hits",
+		JSONPaths: []config.JSONPathConfig{
+			{Path: "aggregations.by_node.buckets", Prefix: "nodes"},
+			{Path: "hits.total", MetricName: "hit_count"},
+			{Path: "hits.total", Prefix: "count"},
+			{Path: "status.total", Prefix: "count"},
+			{Path: "missing.path"},
+		},
+	})
+
+	got, err := extractor.extractDataFromResponse(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// json_path is unprefixed; both count paths flatten to the same key and the later one wins
+	want := map[string]interface{}{
+		"total":              7.0,
+		"nodes[0].key":       "n1",
+		"nodes[0].doc_count": 3.0,
+		"hit_count":          7.0,
+		"count":              9.0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("data = %v, want %v", got, want)
+	}
+}
+
+func TestExtractMetricFamilies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"aggregations":{"cpu":{"user":0.5,"system":0.25},"disk":{"used":{"value":10}}}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{
+		JSONPaths: []config.JSONPathConfig{
+			{Path: "aggregations.cpu", MetricName: "node_cpu"},
+			{Path: "aggregations.disk", Prefix: "disk", MetricName: "node_disk"},
+		},
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	// Keys sit under each path's prefix, which maps to the path's metric family
+	wantData := map[string]interface{}{"node_cpu.user": 0.5, "node_cpu.system": 0.25, "disk.used": 10.0}
+	if !reflect.DeepEqual(results[0].Data, wantData) {
+		t.Errorf("data = %v, want %v", results[0].Data, wantData)
+	}
+	wantFamilies := map[string]string{"node_cpu": "node_cpu", "disk": "node_disk"}
+	if got := results[0].Metadata["metric_families"]; !reflect.DeepEqual(got, wantFamilies) {
+		t.Errorf("metric_families = %v, want %v", got, wantFamilies)
+	}
+}
+
+func TestExtractMetadataMaxBytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace", strings.Repeat("t", 40))
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	query := `{"query":{"match_all":{}},"_name":"` + strings.Repeat("q", 100) + `"}`
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{
+		ElasticsearchQuery: query,
+		CaptureHeaders:     []string{"X-Trace"},
+		MetadataMaxBytes:   32,
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := results[0].Metadata
+
+	wantQuery := query[:32] + fmt.Sprintf("...[truncated %d bytes]", len(query)-32)
+	if metadata["query"] != wantQuery || metadata["original_query"] != wantQuery {
+		t.Errorf("query metadata = %q, want %q", metadata["query"], wantQuery)
+	}
+	if got := metadata["headers"].(map[string]string)["X-Trace"]; got != strings.Repeat("t", 32)+"...[truncated 8 bytes]" {
+		t.Errorf("captured header = %q, want it truncated", got)
+	}
+	if metadata["cluster_name"] != "c0" {
+		t.Errorf("cluster_name = %v, want short fields kept", metadata["cluster_name"])
+	}
+
+	// -1 keeps metadata whole
+	extractor = newTestExtractor(t, server.URL, 1, config.ExtractConfig{ElasticsearchQuery: query, MetadataMaxBytes: -1})
+	results, err = extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Metadata["query"] != query {
+		t.Errorf("query with metadata_max_bytes -1 = %q, want it whole", results[0].Metadata["query"])
+	}
+}
+
+func TestTruncateString(t *testing.T) {
+	// The cut backs up to a rune boundary rather than splitting the two-byte é
+	if got := truncateString("café au lait", 4); got != "caf...[truncated 10 bytes]" {
+		t.Errorf("truncateString = %q", got)
+	}
+	if got := truncateString("short", 16); got != "short" {
+		t.Errorf("truncateString of a short value = %q, want it unchanged", got)
+	}
+}
+
+func TestSplitFlattenedKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"a.b.0", []string{"a", "b", "0"}},
+		{`p."95.0".v`, []string{"p", `"95.0"`, "v"}},
+		{`ip."10.0.0.1"`, []string{"ip", `"10.0.0.1"`}},
+		{"single", []string{"single"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := SplitFlattenedKey(tt.key); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("SplitFlattenedKey(%s) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExcludedPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		format  string
+		exclude string
+		want    bool
+	}{
+		{"bracket index", "hits.hits[3]._source", config.ArrayIndexFormatBracket, "hits.hits._source", true},
+		{"dot index", "hits.hits.3._source", config.ArrayIndexFormatDot, "hits.hits._source", true},
+		{"nested dot indices", "a.0.1.b", config.ArrayIndexFormatDot, "a.b", true},
+		{"quoted numeric key kept", `by_status."200".raw`, config.ArrayIndexFormatDot, `by_status."200".raw`, true},
+		{"numeric object key is not an index", "by_status.200.raw", config.ArrayIndexFormatBracket, "by_status.raw", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excludedPath(tt.key, []string{tt.exclude}, tt.format); got != tt.want {
+				t.Errorf("excludedPath(%s, %s) = %v, want %v", tt.key, tt.exclude, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractIndexPath(t *testing.T) {
+	var mu sync.Mutex
+	paths := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path] = true
+		mu.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 3, config.ExtractConfig{
+		Indices: []config.IndexList{{"metrics-prod-*"}, {"metrics-stage-*", "logs"}, nil},
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"/metrics-prod-*/_search": true, "/metrics-stage-*,logs/_search": true, "/": true}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("request paths = %v, want %v", paths, want)
+	}
+	for _, result := range results {
+		index, _ := result.Metadata["index"].(string)
+		if result.Metadata["cluster_name"] == "c2" && index != "" {
+			t.Errorf("endpoint without indices recorded index %q", index)
+		}
+	}
+}
+
+func TestExtractCaptureHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Warning", "299 deprecated")
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{
+		CaptureHeaders: []string{"X-Elastic-Product", "X-Not-Sent"},
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	want := map[string]string{"X-Elastic-Product": "Elasticsearch"}
+	if got := results[0].Metadata["headers"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("metadata headers = %v, want %v", got, want)
+	}
+}
+
+func TestExtractQueryParams(t *testing.T) {
+	t.Setenv("ES_SEARCH_TIMEOUT", "30s")
+
+	var mu sync.Mutex
+	var query url.Values
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		query, path = r.URL.Query(), r.URL.Path
+		mu.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{
+		Indices:     []config.IndexList{{"metrics-*"}},
+		QueryParams: map[string]string{"preference": "_local", "timeout": "${ES_SEARCH_TIMEOUT}"},
+	})
+	if _, err := extractor.Extract(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := url.Values{"preference": {"_local"}, "timeout": {"30s"}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+	if path != "/metrics-*/_search" {
+		t.Errorf("path = %s, want the index search path", path)
+	}
+}
+
+func TestExtractRetryableStatusCodes(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		io.WriteString(w, `{"hits":{"total":{"value":3},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{MaxRetries: 2, RetryableStatusCodes: []int{429}})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("sent %d requests, want the 429 retried once", got)
+	}
+}
+
+func TestExtractRetryResendsBody(t *testing.T) {
+	query := `{"query":{"term":{"cluster":"c0"}},"size":0}`
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		attempt := len(bodies)
+		mu.Unlock()
+		if attempt <= 2 {
+			// A 503 with Retry-After 0 is retried without the default backoff
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{ElasticsearchQuery: query, MaxRetries: 2})
+	if _, err := extractor.Extract(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 3 {
+		t.Fatalf("sent %d requests, want two 503s retried", len(bodies))
+	}
+	for i, body := range bodies {
+		if body != query {
+			t.Errorf("attempt %d sent %q, want the complete query", i+1, body)
+		}
+	}
+}
+
+func TestExtractSuccessStatusCodes(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"index_not_found_exception"}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{MaxRetries: 2, SuccessStatusCodes: []int{404}})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatalf("404 listed in success_status_codes failed: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("got %d results, want none for an empty index", len(results))
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("sent %d requests, want 404 not retried", got)
+	}
+
+	extractor = newTestExtractor(t, server.URL, 1, config.ExtractConfig{})
+	if _, err := extractor.Extract(context.Background()); err == nil {
+		t.Error("404 without success_status_codes did not fail")
+	}
+}
+
+func TestExtractHonorsRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	// Retry-After is capped by max_retry_after; the default backoff would wait a second
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{MaxRetries: 1, MaxRetryAfter: 50 * time.Millisecond})
+	started := time.Now()
+	if _, err := extractor.Extract(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(started)
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("sent %d requests, want the throttled one retried once", got)
+	}
+	if elapsed < 50*time.Millisecond || elapsed >= time.Second {
+		t.Errorf("retried after %v, want the capped Retry-After of 50ms", elapsed)
+	}
+}
+
+func TestExtractMaxRetryDuration(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// Each retry would wait a second, so a 1.5s budget allows one retry of the ten configured
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{MaxRetries: 10, MaxRetryDuration: 1500 * time.Millisecond})
+	started := time.Now()
+	if _, err := extractor.Extract(context.Background()); err == nil {
+		t.Fatal("expected the extraction to fail")
+	}
+	elapsed := time.Since(started)
+
+	if got := requests.Load(); got != 2 {
+		t.Errorf("sent %d requests, want retries to stop at the time bound", got)
+	}
+	if elapsed >= 1500*time.Millisecond {
+		t.Errorf("retried for %v, want to stop within max_retry_duration", elapsed)
+	}
+}
+
+func TestExtractPerEndpointTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	// c0 gets 50ms; c1 has no entry of its own and falls back to the shared timeout
+	extractor := newTestExtractor(t, server.URL, 2, config.ExtractConfig{
+		Timeout:  5 * time.Second,
+		Timeouts: []time.Duration{50 * time.Millisecond},
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Metadata["cluster_name"] != "c1" {
+		t.Errorf("results = %+v, want c1 within the shared timeout", results)
+	}
+
+	statuses := extractor.GetEndpointStatus()
+	if len(statuses) != 2 || statuses[0].Up || !statuses[1].Up {
+		t.Fatalf("statuses = %+v, want only c0 down", statuses)
+	}
+	if !strings.Contains(statuses[0].Error, "c0") || !strings.Contains(statuses[0].Error, "50ms") {
+		t.Errorf("c0 error %q should name the cluster and its timeout", statuses[0].Error)
+	}
+}
+
+func TestExtractRequestMetadata(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, `{"hits":{"total":{"value":1},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{MaxRetries: 1})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata := results[0].Metadata
+
+	if metadata["status_code"] != http.StatusOK || metadata["attempts"] != 2 {
+		t.Errorf("status_code, attempts = %v, %v; want 200, 2", metadata["status_code"], metadata["attempts"])
+	}
+	if latency, ok := metadata["http_latency_ms"].(int64); !ok || latency < 20 {
+		t.Errorf("http_latency_ms = %v, want at least the 20ms round trip", metadata["http_latency_ms"])
+	}
+
+	// The fields survive a JSON round trip such as the debug output
+	encoded, err := json.Marshal(results[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Result
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"http_latency_ms", "status_code", "attempts"} {
+		if _, ok := decoded.Metadata[key]; !ok {
+			t.Errorf("decoded metadata lacks %s", key)
+		}
+	}
+}
+
+// connCounter tracks the open and peak connection counts of a test server
+type connCounter struct {
+	mutex      sync.Mutex
+	open, peak int
+}
+
+func (c *connCounter) track(conn net.Conn, state http.ConnState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch state {
+	case http.StateNew:
+		c.open++
+		if c.open > c.peak {
+			c.peak = c.open
+		}
+	case http.StateClosed, http.StateHijacked:
+		c.open--
+	}
+}
+
+func TestExtractMaxConnsPerHost(t *testing.T) {
+	var conns connCounter
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	server.Config.ConnState = conns.track
+	server.Start()
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 6, config.ExtractConfig{MaxConcurrency: 6, MaxConnsPerHost: 2})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 6 {
+		t.Fatalf("got %d results, want 6", len(results))
+	}
+
+	conns.mutex.Lock()
+	defer conns.mutex.Unlock()
+	if conns.peak > 2 {
+		t.Errorf("%d connections open at once, want at most 2", conns.peak)
+	}
+}
+
+func TestExtractTokenScheme(t *testing.T) {
+	var mu sync.Mutex
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorization = r.Header.Get("Authorization")
+		mu.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{TokenFile: path, TokenScheme: "ApiKey"})
+	if _, err := extractor.Extract(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if authorization != "ApiKey secret" {
+		t.Errorf("Authorization = %q, want %q", authorization, "ApiKey secret")
+	}
+}
+
+func TestExtractMethod(t *testing.T) {
+	type request struct {
+		method, contentType, body string
+		query                     url.Values
+	}
+	var mu sync.Mutex
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, request{r.Method, r.Header.Get("Content-Type"), string(body), r.URL.Query()})
+		mu.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	query := `{"query":{"term":{"cluster":"__CLUSTER__"}}}`
+	want := `{"query":{"term":{"cluster":"c0"}}}`
+	for _, method := range []string{"", "GET"} {
+		extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{ElasticsearchQuery: query, Method: method})
+		if _, err := extractor.Extract(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(requests))
+	}
+
+	// POST (the default) sends the substituted query as the body
+	post := requests[0]
+	if post.method != http.MethodPost || post.contentType != "application/json" || post.body != want || post.query.Has("source") {
+		t.Errorf("POST request = %+v, want the query %s in the body", post, want)
+	}
+
+	// GET sends the same substituted query in the source parameter and no body
+	get := requests[1]
+	if get.method != http.MethodGet || get.body != "" {
+		t.Errorf("GET request = %+v, want no body", get)
+	}
+	if got := get.query.Get("source"); got != want {
+		t.Errorf("source = %s, want %s", got, want)
+	}
+	if got := get.query.Get("source_content_type"); got != "application/json" {
+		t.Errorf("source_content_type = %q, want application/json", got)
+	}
+}
+
+func TestExtractQueryOverrides(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":0},"hits":[]}}`)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 3, config.ExtractConfig{
+		ElasticsearchQuery: `{"query":{"term":{"cluster":"__CLUSTER__"}}}`,
+		QueryOverrides:     map[string]string{"c1": `{"query":{"term":{"legacy_cluster":"__CLUSTER__"}}}`},
+	})
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the overridden cluster gets its own query, with macros substituted all the same
+	mu.Lock()
+	sort.Strings(bodies)
+	mu.Unlock()
+	want := []string{
+		`{"query":{"term":{"cluster":"c0"}}}`,
+		`{"query":{"term":{"cluster":"c2"}}}`,
+		`{"query":{"term":{"legacy_cluster":"c1"}}}`,
+	}
+	if !reflect.DeepEqual(bodies, want) {
+		t.Errorf("queries sent = %v, want %v", bodies, want)
+	}
+
+	for _, result := range results {
+		cluster := result.Metadata["cluster_name"]
+		wantName := config.DefaultQueryName
+		if cluster == "c1" {
+			wantName = "c1"
+		}
+		if name := result.Metadata["query_name"]; name != wantName {
+			t.Errorf("cluster %v: query_name = %v, want %s", cluster, name, wantName)
+		}
+	}
+}
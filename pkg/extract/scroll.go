@@ -0,0 +1,146 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// scrollClearTimeout bounds the request that releases a scroll context after extraction
+const scrollClearTimeout = 10 * time.Second
+
+// scrollURL returns the _search/scroll endpoint for a cluster. With indices configured the
+// endpoint URL is the cluster base (possibly behind a path prefix); otherwise the URL names a
+// search path and the scroll endpoint sits at the host root
+func scrollURL(endpointURL string, hasIndices bool) (string, error) {
+	if hasIndices {
+		return strings.TrimRight(endpointURL, "/") + "/_search/scroll", nil
+	}
+
+	parsed, err := url.Parse(endpointURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", endpointURL, err)
+	}
+	parsed.Path = "/_search/scroll"
+	parsed.RawPath = ""
+	parsed.RawQuery = ""
+	return parsed.String(), nil
+}
+
+// withScrollParam adds the scroll keep-alive to a search URL so the response opens a scroll context
+func withScrollParam(rawURL, keepAlive string) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %s: %w", rawURL, err)
+	}
+
+	params := parsed.Query()
+	params.Set("scroll", keepAlive)
+	parsed.RawQuery = params.Encode()
+
+	return parsed.String(), nil
+}
+
+// followScroll fetches the remaining pages of a scrolled search and returns the first response
+// with every page's hits merged into hits.hits, so it flattens like a single search response.
+// The scroll context is cleared when done, including on error or cancellation
+func (e *Extractor) followScroll(ctx context.Context, firstBody []byte, endpoint string, header http.Header, timeout time.Duration) ([]byte, error) {
+	var response map[string]interface{}
+	if err := e.unmarshalJSON(firstBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scroll response: %w", err)
+	}
+
+	hitsObject, _ := response["hits"].(map[string]interface{})
+	if hitsObject == nil {
+		return firstBody, nil
+	}
+	allHits, _ := hitsObject["hits"].([]interface{})
+
+	scrollID, _ := response["_scroll_id"].(string)
+	if scrollID == "" {
+		return firstBody, nil
+	}
+	defer func() {
+		e.clearScroll(endpoint, header, scrollID)
+	}()
+
+	page := allHits
+	for len(page) > 0 {
+		payload, err := json.Marshal(map[string]string{"scroll": e.config.Scroll, "scroll_id": scrollID})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := e.sendJSON(ctx, http.MethodPost, endpoint, header, payload, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("scroll request failed: %w", err)
+		}
+
+		var next map[string]interface{}
+		if err := e.unmarshalJSON(body, &next); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal scroll response: %w", err)
+		}
+		if id, ok := next["_scroll_id"].(string); ok && id != "" {
+			scrollID = id
+		}
+
+		page = nil
+		if nextHits, ok := next["hits"].(map[string]interface{}); ok {
+			page, _ = nextHits["hits"].([]interface{})
+		}
+		allHits = append(allHits, page...)
+	}
+
+	hitsObject["hits"] = allHits
+	delete(response, "_scroll_id")
+	return json.Marshal(response)
+}
+
+// sendJSON sends a JSON payload to an Elasticsearch API and returns the response body. A
+// positive timeout bounds the request including reading the body
+func (e *Extractor) sendJSON(ctx context.Context, method, endpoint string, header http.Header, payload []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := withOptionalTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponseBody(resp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// clearScroll releases a scroll context. It uses its own timeout so contexts are still cleared
+// after the extraction context is cancelled
+func (e *Extractor) clearScroll(endpoint string, header http.Header, scrollID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), scrollClearTimeout)
+	defer cancel()
+
+	payload, err := json.Marshal(map[string][]string{"scroll_id": {scrollID}})
+	if err != nil {
+		return
+	}
+	if _, err := e.sendJSON(ctx, http.MethodDelete, endpoint, header, payload, 0); err != nil {
+		e.logger.Printf("Failed to clear scroll context at %s: %v", endpoint, err)
+	}
+}
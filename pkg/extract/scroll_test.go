@@ -0,0 +1,102 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestScrollURL(t *testing.T) {
+	// Without indices the URL names a search path, so the scroll endpoint sits at the host root
+	got, err := scrollURL("http://es:9200/logs/_search?size=10", false)
+	if err != nil || got != "http://es:9200/_search/scroll" {
+		t.Errorf("scrollURL(search path) = %s, %v", got, err)
+	}
+
+	// With indices the URL is the cluster base, which may sit behind a path prefix
+	got, err = scrollURL("https://proxy/es/", true)
+	if err != nil || got != "https://proxy/es/_search/scroll" {
+		t.Errorf("scrollURL(base with prefix) = %s, %v", got, err)
+	}
+
+	got, err = withScrollParam("http://es:9200/logs/_search?size=10", "2m")
+	if err != nil || got != "http://es:9200/logs/_search?scroll=2m&size=10" {
+		t.Errorf("withScrollParam = %s, %v", got, err)
+	}
+}
+
+func TestFollowScroll(t *testing.T) {
+	pages := []int{2, 1, 0} // Hits on each page after the first
+	var mu sync.Mutex
+	var scrollIDs []string
+	cleared := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodDelete {
+			cleared = string(body)
+			return
+		}
+
+		var payload map[string]string
+		json.Unmarshal(body, &payload)
+		if payload["scroll"] != "1m" {
+			t.Errorf("scroll request %s lacks the keep-alive", body)
+		}
+		scrollIDs = append(scrollIDs, payload["scroll_id"])
+		page := len(scrollIDs) - 1
+		hits := strings.TrimSuffix(strings.Repeat(`{"_id":"x"},`, pages[page]), ",")
+		fmt.Fprintf(w, `{"_scroll_id":"id%d","hits":{"hits":[%s]}}`, page+1, hits)
+	}))
+	defer server.Close()
+
+	extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{Scroll: "1m"})
+	first := []byte(`{"_scroll_id":"id0","hits":{"hits":[{"_id":"a"},{"_id":"b"}]}}`)
+	merged, err := extractor.followScroll(context.Background(), first, server.URL, http.Header{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(merged, &response); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := response["_scroll_id"]; exists {
+		t.Error("merged response keeps the scroll id")
+	}
+	if got := len(response["hits"].(map[string]interface{})["hits"].([]interface{})); got != 5 {
+		t.Errorf("merged %d hits, want 5", got)
+	}
+
+	// Each page is requested with the scroll id from the one before, and the last is cleared
+	mu.Lock()
+	defer mu.Unlock()
+	if got := strings.Join(scrollIDs, ","); got != "id0,id1,id2" {
+		t.Errorf("scroll ids sent = %s, want id0,id1,id2", got)
+	}
+	if cleared != `{"scroll_id":["id3"]}` {
+		t.Errorf("cleared %q, want the last scroll id", cleared)
+	}
+}
+
+func TestFollowScrollWithoutScrollID(t *testing.T) {
+	extractor := newTestExtractor(t, "http://127.0.0.1:1", 1, config.ExtractConfig{Scroll: "1m"})
+	first := []byte(`{"hits":{"hits":[{"_id":"a"}]}}`)
+	got, err := extractor.followScroll(context.Background(), first, "http://127.0.0.1:1", http.Header{}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(first) {
+		t.Errorf("response without a scroll id = %s, want it unchanged", got)
+	}
+}
@@ -0,0 +1,120 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultSearchSize is the page size Elasticsearch uses when a query sets none
+const defaultSearchSize = 10
+
+// decodeExact decodes JSON keeping numbers as json.Number. Paging always decodes this way,
+// whatever use_json_number says, because sort values such as epoch nanoseconds or long _ids
+// must be sent back exactly; rounding them through float64 skips or repeats hits
+func decodeExact(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// withSearchAfter returns query with search_after set to sortValues
+func withSearchAfter(query string, sortValues []interface{}) (string, error) {
+	var parsed map[string]interface{}
+	if err := decodeExact([]byte(query), &parsed); err != nil {
+		return "", fmt.Errorf("search_after requires a JSON object query: %w", err)
+	}
+	parsed["search_after"] = sortValues
+
+	rewritten, err := json.Marshal(parsed)
+	if err != nil {
+		return "", err
+	}
+	return string(rewritten), nil
+}
+
+// searchPageSize returns the query's size, checking it sorts so pages can be resumed
+func searchPageSize(query string) (int, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(query), &parsed); err != nil {
+		return 0, fmt.Errorf("search_after requires a JSON object query: %w", err)
+	}
+	if _, ok := parsed["sort"]; !ok {
+		return 0, fmt.Errorf("search_after requires the query to define sort")
+	}
+
+	size := defaultSearchSize
+	if raw, ok := parsed["size"].(float64); ok {
+		size = int(raw)
+	}
+	return size, nil
+}
+
+// followSearchAfter fetches the pages after a first search response by resending the query with
+// search_after set to the last hit's sort values, until a page returns fewer than size hits. It
+// returns the first response with every page's hits merged into hits.hits; numbers are carried
+// through verbatim, leaving use_json_number to apply when the merged response is extracted
+func (e *Extractor) followSearchAfter(ctx context.Context, firstBody []byte, targetURL string, header http.Header, query string, timeout time.Duration) ([]byte, error) {
+	size, err := searchPageSize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var response map[string]interface{}
+	if err := decodeExact(firstBody, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal search response: %w", err)
+	}
+	hitsObject, _ := response["hits"].(map[string]interface{})
+	if hitsObject == nil {
+		return firstBody, nil
+	}
+	allHits, _ := hitsObject["hits"].([]interface{})
+
+	page := allHits
+	for size > 0 && len(page) >= size {
+		last, _ := page[len(page)-1].(map[string]interface{})
+		sortValues, _ := last["sort"].([]interface{})
+		if len(sortValues) == 0 {
+			return nil, fmt.Errorf("search_after: last hit has no sort values")
+		}
+
+		nextQuery, err := withSearchAfter(query, sortValues)
+		if err != nil {
+			return nil, err
+		}
+
+		var body []byte
+		if strings.EqualFold(e.config.Method, http.MethodGet) {
+			pageURL, err := withSourceParam(targetURL, nextQuery)
+			if err != nil {
+				return nil, err
+			}
+			body, err = e.sendJSON(ctx, http.MethodGet, pageURL, header, nil, timeout)
+			if err != nil {
+				return nil, fmt.Errorf("search_after request failed: %w", err)
+			}
+		} else {
+			body, err = e.sendJSON(ctx, http.MethodPost, targetURL, header, []byte(nextQuery), timeout)
+			if err != nil {
+				return nil, fmt.Errorf("search_after request failed: %w", err)
+			}
+		}
+
+		var next map[string]interface{}
+		if err := decodeExact(body, &next); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal search response: %w", err)
+		}
+		page = nil
+		if nextHits, ok := next["hits"].(map[string]interface{}); ok {
+			page, _ = nextHits["hits"].([]interface{})
+		}
+		allHits = append(allHits, page...)
+	}
+
+	hitsObject["hits"] = allHits
+	return json.Marshal(response)
+}
@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+// searchAfterBase is a sort value float64 cannot represent, so rounding it repeats or skips hits
+const searchAfterBase int64 = 1700000000000000001
+
+// searchPage returns the hits, at most size, whose sort value follows after
+func searchPage(total, size int, after int64) []byte {
+	hits := []string{}
+	for i := 0; i < total && len(hits) < size; i++ {
+		if value := searchAfterBase + int64(i); value > after {
+			hits = append(hits, fmt.Sprintf(`{"_id":"%d","sort":[%d]}`, i, value))
+		}
+	}
+	return []byte(fmt.Sprintf(`{"hits":{"hits":[%s]}}`, strings.Join(hits, ",")))
+}
+
+func TestFollowSearchAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		total         int
+		size          int
+		useJSONNumber bool
+		wantRequests  int
+	}{
+		{"single short page", 1, 2, false, 0},
+		{"last page partial", 5, 2, false, 2},
+		{"last page empty", 4, 2, false, 2},
+		{"use_json_number set", 5, 2, true, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests > tt.total {
+					// Rounded sort values resend the same page forever
+					t.Error("paging does not advance")
+					w.Write([]byte(`{"hits":{"hits":[]}}`))
+					return
+				}
+				var query struct {
+					SearchAfter []json.Number `json:"search_after"`
+				}
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &query); err != nil || len(query.SearchAfter) != 1 {
+					t.Errorf("bad search_after query %s: %v", body, err)
+					return
+				}
+				after, err := query.SearchAfter[0].Int64()
+				if err != nil {
+					t.Errorf("search_after value %s is not an exact integer", query.SearchAfter[0])
+				}
+				w.Write(searchPage(tt.total, tt.size, after))
+			}))
+			defer server.Close()
+
+			extractor := newTestExtractor(t, server.URL, 1, config.ExtractConfig{SearchAfter: true, UseJSONNumber: tt.useJSONNumber})
+			query := fmt.Sprintf(`{"size":%d,"sort":[{"@timestamp":"asc"}],"query":{"range":{"n":{"gte":%d}}}}`, tt.size, searchAfterBase)
+			merged, err := extractor.followSearchAfter(context.Background(), searchPage(tt.total, tt.size, 0), server.URL, http.Header{}, query, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var response struct {
+				Hits struct {
+					Hits []struct {
+						ID   string        `json:"_id"`
+						Sort []json.Number `json:"sort"`
+					} `json:"hits"`
+				} `json:"hits"`
+			}
+			if err := json.Unmarshal(merged, &response); err != nil {
+				t.Fatal(err)
+			}
+			if len(response.Hits.Hits) != tt.total {
+				t.Fatalf("merged %d hits, want %d", len(response.Hits.Hits), tt.total)
+			}
+			for i, hit := range response.Hits.Hits {
+				if want := fmt.Sprint(searchAfterBase + int64(i)); hit.ID != fmt.Sprint(i) || string(hit.Sort[0]) != want {
+					t.Errorf("hit %d = %s sorted by %s, want %d sorted by %s", i, hit.ID, hit.Sort[0], i, want)
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("sent %d page requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestWithSearchAfterKeepsQueryNumbers(t *testing.T) {
+	query := `{"query":{"range":{"n":{"gte":1700000000000000001}}},"sort":["n"]}`
+	rewritten, err := withSearchAfter(query, []interface{}{json.Number("1700000000000000003")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"gte":1700000000000000001`, `"search_after":[1700000000000000003]`} {
+		if !strings.Contains(rewritten, want) {
+			t.Errorf("rewritten query %s lacks %s", rewritten, want)
+		}
+	}
+}
+
+func TestSearchPageSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    int
+		wantErr bool
+	}{
+		{"explicit size", `{"size":50,"sort":["n"]}`, 50, false},
+		{"default size", `{"sort":["n"]}`, defaultSearchSize, false},
+		{"no sort", `{"size":50}`, 0, true},
+		{"not an object", `[1]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchPageSize(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("searchPageSize(%s) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("searchPageSize(%s) = %d, want %d", tt.query, got, tt.want)
+			}
+		})
+	}
+}
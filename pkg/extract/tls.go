@@ -0,0 +1,63 @@
+package extract
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"elasticetl/pkg/config"
+)
+
+// clientTLSConfig builds the transport TLS settings from insecure_tls and the tls client
+// certificate and CA files. It returns nil when the defaults apply
+func clientTLSConfig(cfg config.ExtractConfig) (*tls.Config, error) {
+	if !cfg.InsecureTLS && cfg.TLS == (config.TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: cfg.InsecureTLS,
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate %s and key %s: %w", cfg.TLS.CertFile, cfg.TLS.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.TLS.CAFile != "" {
+		pem, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %s contains no PEM certificates", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// tlsFileStamp identifies the current contents of the tls files by their modification times and
+// sizes, so a reload can tell renewed certificates from unchanged ones. Missing files are
+// recorded as such
+func tlsFileStamp(tlsCfg config.TLSConfig) string {
+	stamp := ""
+	for _, path := range []string{tlsCfg.CertFile, tlsCfg.KeyFile, tlsCfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			stamp += path + ":missing;"
+			continue
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp
+}
@@ -0,0 +1,79 @@
+package extract
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+// writeCAFile writes a self-signed certificate named commonName to path
+func writeCAFile(t *testing.T, path, commonName string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateConfigReloadsRenewedCertificates(t *testing.T) {
+	tests := []struct {
+		name       string
+		renew      bool
+		wantReload bool
+	}{
+		{"unchanged files keep the client", false, false},
+		{"renewed files rebuild the client", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caFile := filepath.Join(t.TempDir(), "ca.pem")
+			writeCAFile(t, caFile, "old")
+			cfg := config.ExtractConfig{TLS: config.TLSConfig{CAFile: caFile}}
+			extractor := newTestExtractor(t, "https://es:9200", 1, cfg)
+			cfg = extractor.config
+			before := extractor.httpClient
+
+			if tt.renew {
+				writeCAFile(t, caFile, "renewed")
+				// Make the renewal visible even on filesystems with coarse timestamps
+				later := time.Now().Add(time.Minute)
+				if err := os.Chtimes(caFile, later, later); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := extractor.UpdateConfig(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			if reloaded := extractor.httpClient != before; reloaded != tt.wantReload {
+				t.Errorf("client rebuilt = %v, want %v", reloaded, tt.wantReload)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenFile serves a credential token from a file rewritten by an external process. The file is
+// re-read only when its modification time or size changes, so rotated tokens are picked up on
+// the next request without a config reload
+type tokenFile struct {
+	path    string
+	mutex   sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// newTokenFile creates a token source for path
+func newTokenFile(path string) *tokenFile {
+	return &tokenFile{path: path}
+}
+
+// Token returns the current token, re-reading the file if it changed since the last read
+func (t *tokenFile) Token() (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat token file: %w", err)
+	}
+	if t.token != "" && info.ModTime().Equal(t.modTime) && info.Size() == t.size {
+		return t.token, nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", t.path)
+	}
+
+	t.token = token
+	t.modTime = info.ModTime()
+	t.size = info.Size()
+	return token, nil
+}
@@ -0,0 +1,55 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source := newTokenFile(path)
+
+	token, err := source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "first" {
+		t.Fatalf("token = %q, want %q", token, "first")
+	}
+
+	// Rotate the token; the new modification time makes the next call re-read the file
+	if err := os.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	token, err = source.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "second" {
+		t.Fatalf("token after rotation = %q, want %q", token, "second")
+	}
+}
+
+func TestTokenFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := newTokenFile(filepath.Join(dir, "missing")).Token(); err == nil {
+		t.Error("expected an error for a missing token file")
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTokenFile(empty).Token(); err == nil {
+		t.Error("expected an error for an empty token file")
+	}
+}
@@ -0,0 +1,309 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"elasticetl/pkg/logging"
+	"elasticetl/pkg/transform"
+)
+
+// Avro field types supported by the avro stream
+var avroTypes = map[string]bool{"string": true, "long": true, "double": true, "boolean": true}
+
+// AvroStream writes CSV rows to Avro object container files. Each load writes a new
+// timestamped file with its own schema, so columns may change between runs
+type AvroStream struct {
+	path   string
+	types  map[string]string // Configured field type per CSV column; other columns are inferred
+	logger *logging.Logger
+}
+
+// avroField is one column of a written file's schema
+type avroField struct {
+	Name     string      `json:"name"`
+	Type     []string    `json:"type"`
+	Default  interface{} `json:"default"`
+	column   int
+	dataType string
+}
+
+// NewAvroStream creates a new Avro stream
+func NewAvroStream(config map[string]interface{}) (*AvroStream, error) {
+	path, ok := safeString(config["path"])
+	if !ok || path == "" {
+		return nil, fmt.Errorf("avro stream requires 'path' configuration")
+	}
+
+	types := make(map[string]string)
+	if rawTypes, exists := config["types"]; exists {
+		typeMap, ok := safeMapStringInterface(rawTypes)
+		if !ok {
+			return nil, fmt.Errorf("avro stream 'types' must map column names to types")
+		}
+		for column, rawType := range typeMap {
+			dataType, _ := safeString(rawType)
+			if !avroTypes[dataType] {
+				return nil, fmt.Errorf("avro stream column %s: unsupported type %q (must be string, long, double or boolean)", column, dataType)
+			}
+			types[column] = dataType
+		}
+	}
+
+	return &AvroStream{
+		path:  path,
+		types: types,
+	}, nil
+}
+
+// Load writes the CSV rows of results to a new Avro file. Results may have different headers,
+// so the schema holds the union of their columns; cells of columns a result lacks are null
+func (a *AvroStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	headers, rows := unionRows(results)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	fields := a.schemaFields(headers, rows)
+	schema, err := json.Marshal(map[string]interface{}{
+		"type":      "record",
+		"name":      "row",
+		"namespace": "elasticetl",
+		"fields":    fields,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode avro schema: %w", err)
+	}
+
+	var block bytes.Buffer
+	for _, row := range rows {
+		for _, field := range fields {
+			if err := writeAvroValue(&block, field, row); err != nil {
+				return err
+			}
+		}
+	}
+
+	avroDir := filepath.Dir(a.path)
+	if err := os.MkdirAll(avroDir, 0755); err != nil {
+		return fmt.Errorf("failed to create avro directory: %w", err)
+	}
+
+	// Generate filename with timestamp
+	timestamp := time.Now().Format("20060102_150405")
+	filename := fmt.Sprintf("%s_%s.avro", filepath.Base(a.path), timestamp)
+	fullPath := filepath.Join(avroDir, filename)
+
+	var sync [16]byte
+	if _, err := rand.Read(sync[:]); err != nil {
+		return fmt.Errorf("failed to generate avro sync marker: %w", err)
+	}
+
+	// Header: magic, metadata map and sync marker, followed by a single block of all rows
+	var file bytes.Buffer
+	file.WriteString("Obj\x01")
+	writeAvroLong(&file, 2)
+	writeAvroBytes(&file, []byte("avro.schema"))
+	writeAvroBytes(&file, schema)
+	writeAvroBytes(&file, []byte("avro.codec"))
+	writeAvroBytes(&file, []byte("null"))
+	writeAvroLong(&file, 0)
+	file.Write(sync[:])
+	writeAvroLong(&file, int64(len(rows)))
+	writeAvroLong(&file, int64(block.Len()))
+	file.Write(block.Bytes())
+	file.Write(sync[:])
+
+	if err := os.WriteFile(fullPath, file.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write avro file: %w", err)
+	}
+
+	a.logger.Printf("Avro output written to: %s", fullPath)
+	return nil
+}
+
+// unionRows returns the union of the CSV headers of results, in order of first appearance,
+// and every result's rows remapped onto it
+func unionRows(results []*transform.TransformedResult) ([]string, [][]string) {
+	var headers []string
+	var rows [][]string
+	columns := make(map[string]int)
+	for _, result := range results {
+		if len(result.CSVHeaders) == 0 || len(result.CSVData) == 0 {
+			continue
+		}
+
+		positions := make([]int, len(result.CSVHeaders))
+		for i, header := range result.CSVHeaders {
+			column, exists := columns[header]
+			if !exists {
+				column = len(headers)
+				columns[header] = column
+				headers = append(headers, header)
+			}
+			positions[i] = column
+		}
+
+		for _, row := range result.CSVData {
+			rows = append(rows, remapRow(row, positions, len(headers)))
+		}
+	}
+
+	// Rows remapped before later results added columns are padded with empty (null) cells
+	for i, row := range rows {
+		if len(row) < len(headers) {
+			rows[i] = append(row, make([]string, len(headers)-len(row))...)
+		}
+	}
+	return headers, rows
+}
+
+// remapRow places the cells of row at positions in a row of width cells
+func remapRow(row []string, positions []int, width int) []string {
+	remapped := make([]string, width)
+	for i, cell := range row {
+		if i < len(positions) {
+			remapped[positions[i]] = cell
+		}
+	}
+	return remapped
+}
+
+// schemaFields builds the nullable schema fields for headers, using the configured type of a
+// column or else the narrowest of long, double and string that holds all its non-empty values
+func (a *AvroStream) schemaFields(headers []string, rows [][]string) []avroField {
+	fields := make([]avroField, len(headers))
+	names := make(map[string]bool, len(headers))
+	for i, header := range headers {
+		dataType, configured := a.types[header]
+		if !configured {
+			dataType = inferAvroType(rows, i)
+		}
+
+		// Field names must be unique Avro names
+		name := avroName(header)
+		for suffix := 2; names[name]; suffix++ {
+			name = fmt.Sprintf("%s_%d", avroName(header), suffix)
+		}
+		names[name] = true
+
+		fields[i] = avroField{
+			Name:     name,
+			Type:     []string{"null", dataType},
+			column:   i,
+			dataType: dataType,
+		}
+	}
+	return fields
+}
+
+// inferAvroType returns long if every non-empty value of column parses as an integer, double
+// if they parse as numbers, and string otherwise
+func inferAvroType(rows [][]string, column int) string {
+	dataType := "long"
+	for _, row := range rows {
+		if column >= len(row) || row[column] == "" {
+			continue
+		}
+		if _, err := strconv.ParseInt(row[column], 10, 64); err == nil {
+			continue
+		}
+		if _, err := strconv.ParseFloat(row[column], 64); err == nil {
+			dataType = "double"
+			continue
+		}
+		return "string"
+	}
+	return dataType
+}
+
+// avroName converts a CSV header to an Avro name: letters, digits and underscores, not starting
+// with a digit
+func avroName(header string) string {
+	name := []byte(header)
+	for i, c := range name {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		name = append([]byte("_"), name...)
+	}
+	return string(name)
+}
+
+// writeAvroValue writes the field's cell of row as a ["null", type] union; empty cells are null
+func writeAvroValue(buf *bytes.Buffer, field avroField, row []string) error {
+	if field.column >= len(row) || row[field.column] == "" {
+		writeAvroLong(buf, 0)
+		return nil
+	}
+	value := row[field.column]
+	writeAvroLong(buf, 1)
+
+	switch field.dataType {
+	case "long":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("avro field %s: value %q is not a long", field.Name, value)
+		}
+		writeAvroLong(buf, n)
+	case "double":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("avro field %s: value %q is not a double", field.Name, value)
+		}
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(f))
+		buf.Write(b[:])
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("avro field %s: value %q is not a boolean", field.Name, value)
+		}
+		if b {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	default:
+		writeAvroBytes(buf, []byte(value))
+	}
+	return nil
+}
+
+// writeAvroLong writes n zig-zag varint encoded
+func writeAvroLong(buf *bytes.Buffer, n int64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutVarint(b[:], n)])
+}
+
+// writeAvroBytes writes data prefixed with its length
+func writeAvroBytes(buf *bytes.Buffer, data []byte) {
+	writeAvroLong(buf, int64(len(data)))
+	buf.Write(data)
+}
+
+// setLogger sets the logger reporting written files
+func (a *AvroStream) setLogger(logger *logging.Logger) {
+	a.logger = logger
+}
+
+// Close does nothing; each load writes its own file
+func (a *AvroStream) Close() error {
+	return nil
+}
+
+// GetType returns the stream type
+func (a *AvroStream) GetType() string {
+	return "avro"
+}
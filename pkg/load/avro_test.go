@@ -0,0 +1,170 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+)
+
+// avroReader decodes the parts of an Avro object container file the avro stream writes
+type avroReader struct {
+	t *testing.T
+	r *bytes.Reader
+}
+
+func (a avroReader) long() int64 {
+	n, err := binary.ReadVarint(a.r)
+	if err != nil {
+		a.t.Fatalf("read avro long: %v", err)
+	}
+	return n
+}
+
+func (a avroReader) bytes() []byte {
+	data := make([]byte, a.long())
+	if _, err := io.ReadFull(a.r, data); err != nil {
+		a.t.Fatalf("read avro bytes: %v", err)
+	}
+	return data
+}
+
+// readAvroFile returns the field names of the file's schema and its rows, with nulls as nil
+func readAvroFile(t *testing.T, path string) ([]string, [][]interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("Obj\x01")) {
+		t.Fatalf("%s is not an avro file", path)
+	}
+	a := avroReader{t: t, r: bytes.NewReader(data[4:])}
+
+	metadata := make(map[string]string)
+	for count := a.long(); count > 0; count = a.long() {
+		for i := int64(0); i < count; i++ {
+			key := string(a.bytes())
+			metadata[key] = string(a.bytes())
+		}
+	}
+	var schema struct {
+		Fields []struct {
+			Name string   `json:"name"`
+			Type []string `json:"type"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(metadata["avro.schema"]), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	var sync [16]byte
+	io.ReadFull(a.r, sync[:])
+
+	var names []string
+	for _, field := range schema.Fields {
+		names = append(names, field.Name)
+	}
+
+	var rows [][]interface{}
+	count := a.long()
+	a.long() // Block size
+	for i := int64(0); i < count; i++ {
+		row := make([]interface{}, len(schema.Fields))
+		for j, field := range schema.Fields {
+			if a.long() == 0 {
+				continue // null
+			}
+			switch field.Type[1] {
+			case "long":
+				row[j] = a.long()
+			case "double":
+				var b [8]byte
+				io.ReadFull(a.r, b[:])
+				row[j] = math.Float64frombits(binary.LittleEndian.Uint64(b[:]))
+			case "boolean":
+				b, _ := a.r.ReadByte()
+				row[j] = b == 1
+			default:
+				row[j] = string(a.bytes())
+			}
+		}
+		rows = append(rows, row)
+	}
+	return names, rows
+}
+
+// csvResult returns a result carrying CSV headers and rows
+func csvResult(headers []string, rows ...[]string) *transform.TransformedResult {
+	return &transform.TransformedResult{Result: &extract.Result{}, CSVHeaders: headers, CSVData: rows}
+}
+
+func TestAvroStreamLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		types     map[string]interface{}
+		results   []*transform.TransformedResult
+		wantNames []string
+		wantRows  [][]interface{}
+	}{
+		{
+			name:      "inferred types",
+			results:   []*transform.TransformedResult{csvResult([]string{"host", "count", "ratio"}, []string{"a", "3", "0.5"}, []string{"b", "", "1"})},
+			wantNames: []string{"host", "count", "ratio"},
+			wantRows:  [][]interface{}{{"a", int64(3), 0.5}, {"b", nil, 1.0}},
+		},
+		{
+			name:      "configured type",
+			types:     map[string]interface{}{"up": "boolean"},
+			results:   []*transform.TransformedResult{csvResult([]string{"up"}, []string{"true"}, []string{"false"})},
+			wantNames: []string{"up"},
+			wantRows:  [][]interface{}{{true}, {false}},
+		},
+		{
+			name: "results with different headers",
+			results: []*transform.TransformedResult{
+				csvResult([]string{"host", "count"}, []string{"a", "1"}),
+				csvResult([]string{"count", "host.name", "host"}, []string{"2", "x", "b"}),
+			},
+			wantNames: []string{"host", "count", "host_name"},
+			wantRows:  [][]interface{}{{"a", int64(1), nil}, {"b", int64(2), "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := map[string]interface{}{"path": filepath.Join(dir, "rows")}
+			if tt.types != nil {
+				cfg["types"] = tt.types
+			}
+			stream, err := NewAvroStream(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Load(context.Background(), tt.results); err != nil {
+				t.Fatal(err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "rows_*.avro"))
+			if len(files) != 1 {
+				t.Fatalf("wrote %d files, want 1", len(files))
+			}
+			names, rows := readAvroFile(t, files[0])
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("fields = %v, want %v", names, tt.wantNames)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %v, want %v", rows, tt.wantRows)
+			}
+		})
+	}
+}
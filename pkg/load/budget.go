@@ -0,0 +1,171 @@
+package load
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/transform"
+)
+
+// seriesCandidate is one series a load would emit: the CSV rows of a result sharing the
+// series' label columns, or a transformed data field of a result without CSV data
+type seriesCandidate struct {
+	result   int
+	rows     []int  // CSV row indices; empty for a transformed data field
+	key      string // Transformed data field
+	priority float64
+}
+
+// seriesColumns returns the CSV columns whose values identify a series: the unique fields and
+// label columns of all metrics, sorted. Without metrics every row is its own series
+func seriesColumns(metrics []config.PrometheusMetricConfig) []int {
+	seen := make(map[int]bool)
+	var columns []int
+	add := func(column int) {
+		if column >= 0 && !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	for _, metric := range metrics {
+		for _, column := range metric.UniqueFieldsIndex {
+			add(column)
+		}
+		for _, label := range metric.Labels {
+			if label.StaticValue == "" {
+				add(label.IndexInCSVData)
+			}
+		}
+	}
+	sort.Ints(columns)
+	return columns
+}
+
+// limitSeries keeps at most maxSeries series across results, returning the limited results and
+// the number of series dropped. Rows with the same values in columns (see seriesColumns) belong
+// to one series and are kept or dropped together; with no columns each row is a series. Series
+// with the highest numeric value in priorityColumn (the highest of their rows) are kept first;
+// without a priority column (or on ties) earlier series win, so truncation is deterministic.
+// The input results are not modified
+func limitSeries(results []*transform.TransformedResult, maxSeries int, priorityColumn string, columns []int) ([]*transform.TransformedResult, int) {
+	var candidates []seriesCandidate
+	for r, result := range results {
+		if len(result.CSVData) > 0 {
+			column := -1
+			for i, header := range result.CSVHeaders {
+				if header == priorityColumn {
+					column = i
+					break
+				}
+			}
+
+			// Group rows by series, in order of each series' first row
+			groups := make(map[string]int)
+			for i, row := range result.CSVData {
+				priority := rowPriority(row, column)
+				key := rowSeriesKey(row, i, columns)
+				if g, exists := groups[key]; exists {
+					candidates[g].rows = append(candidates[g].rows, i)
+					candidates[g].priority = math.Max(candidates[g].priority, priority)
+					continue
+				}
+				groups[key] = len(candidates)
+				candidates = append(candidates, seriesCandidate{result: r, rows: []int{i}, priority: priority})
+			}
+			continue
+		}
+
+		keys := make([]string, 0, len(result.TransformedData))
+		for key := range result.TransformedData {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			candidates = append(candidates, seriesCandidate{result: r, key: key, priority: math.Inf(-1)})
+		}
+	}
+
+	if len(candidates) <= maxSeries {
+		return results, 0
+	}
+
+	if priorityColumn != "" {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].priority > candidates[j].priority
+		})
+	}
+	kept := candidates[:maxSeries]
+
+	// Rebuild each result with only its kept series, preserving their original order
+	keptRows := make(map[int]map[int]bool)
+	keptKeys := make(map[int]map[string]bool)
+	for _, candidate := range kept {
+		if len(candidate.rows) > 0 {
+			if keptRows[candidate.result] == nil {
+				keptRows[candidate.result] = make(map[int]bool)
+			}
+			for _, row := range candidate.rows {
+				keptRows[candidate.result][row] = true
+			}
+		} else {
+			if keptKeys[candidate.result] == nil {
+				keptKeys[candidate.result] = make(map[string]bool)
+			}
+			keptKeys[candidate.result][candidate.key] = true
+		}
+	}
+
+	var limited []*transform.TransformedResult
+	for r, result := range results {
+		limitedResult := *result
+		switch {
+		case len(keptRows[r]) > 0:
+			limitedResult.CSVData = nil
+			for i, row := range result.CSVData {
+				if keptRows[r][i] {
+					limitedResult.CSVData = append(limitedResult.CSVData, row)
+				}
+			}
+		case len(keptKeys[r]) > 0:
+			limitedResult.TransformedData = make(map[string]interface{}, len(keptKeys[r]))
+			for key := range keptKeys[r] {
+				limitedResult.TransformedData[key] = result.TransformedData[key]
+			}
+		default:
+			continue
+		}
+		limited = append(limited, &limitedResult)
+	}
+
+	return limited, len(candidates) - maxSeries
+}
+
+// rowSeriesKey returns the key of the series row belongs to: its values in columns, or its index
+// when there are no columns
+func rowSeriesKey(row []string, index int, columns []int) string {
+	if len(columns) == 0 {
+		return strconv.Itoa(index)
+	}
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		if column < len(row) {
+			values[i] = row[column]
+		}
+	}
+	return strings.Join(values, "\x00")
+}
+
+// rowPriority returns the numeric value of a row's priority column, lowest when missing
+func rowPriority(row []string, column int) float64 {
+	if column < 0 || column >= len(row) {
+		return math.Inf(-1)
+	}
+	value, err := strconv.ParseFloat(row[column], 64)
+	if err != nil || math.IsNaN(value) {
+		return math.Inf(-1)
+	}
+	return value
+}
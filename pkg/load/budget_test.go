@@ -0,0 +1,66 @@
+package load
+
+import (
+	"reflect"
+	"testing"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+)
+
+func TestLimitSeries(t *testing.T) {
+	// Rows are host, timestamp, value; host identifies the series
+	rows := [][]string{
+		{"a", "1", "10"},
+		{"b", "1", "50"},
+		{"a", "2", "11"},
+		{"c", "1", "30"},
+		{"b", "2", "51"},
+	}
+	metrics := []config.PrometheusMetricConfig{{Name: "m", UniqueFieldsIndex: []int{0}, Value: 2, Timestamp: 1}}
+
+	tests := []struct {
+		name        string
+		maxSeries   int
+		priority    string
+		metrics     []config.PrometheusMetricConfig
+		wantRows    [][]string
+		wantDropped int
+	}{
+		{"under the limit", 3, "", metrics, rows, 0},
+		{"whole series in order", 2, "", metrics, [][]string{rows[0], rows[1], rows[2], rows[4]}, 1},
+		{"whole series by priority", 2, "value", metrics, [][]string{rows[1], rows[3], rows[4]}, 1},
+		{"rows without metrics", 2, "", nil, [][]string{rows[0], rows[1]}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []*transform.TransformedResult{{
+				Result:     &extract.Result{Source: "es"},
+				CSVHeaders: []string{"host", "timestamp", "value"},
+				CSVData:    rows,
+			}}
+			limited, dropped := limitSeries(results, tt.maxSeries, tt.priority, seriesColumns(tt.metrics))
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped %d series, want %d", dropped, tt.wantDropped)
+			}
+			if len(limited) != 1 || !reflect.DeepEqual(limited[0].CSVData, tt.wantRows) {
+				t.Errorf("kept rows %v, want %v", limited[0].CSVData, tt.wantRows)
+			}
+			if len(results[0].CSVData) != len(rows) {
+				t.Error("input results modified")
+			}
+		})
+	}
+}
+
+func TestSeriesColumns(t *testing.T) {
+	metrics := []config.PrometheusMetricConfig{
+		{UniqueFieldsIndex: []int{3, 1}},
+		{UniqueFieldsIndex: []int{1}, Labels: []config.PrometheusLabelConfig{{IndexInCSVData: 4}, {StaticValue: "x"}}},
+	}
+	if got, want := seriesColumns(metrics), []int{1, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("seriesColumns = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,161 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"elasticetl/pkg/logging"
+	"elasticetl/pkg/transform"
+)
+
+// ExecStream pipes each batch to an external command's stdin
+type ExecStream struct {
+	command   string
+	args      []string
+	format    string // "json" (default) or "csv"
+	timeout   time.Duration
+	formatter *DebugStream // Shares the debug stream's json rendering
+	logger    *logging.Logger
+}
+
+// NewExecStream creates a new exec stream
+func NewExecStream(config map[string]interface{}) (*ExecStream, error) {
+	command, ok := safeString(config["command"])
+	if !ok || command == "" {
+		return nil, fmt.Errorf("exec stream requires 'command' configuration")
+	}
+
+	var args []string
+	if rawArgs, exists := config["args"]; exists {
+		list, ok := rawArgs.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("exec stream 'args' must be a list of strings")
+		}
+		for _, raw := range list {
+			arg, ok := safeString(raw)
+			if !ok {
+				return nil, fmt.Errorf("exec stream 'args' must be a list of strings")
+			}
+			args = append(args, substituteEnvVars(arg))
+		}
+	}
+
+	format := "json"
+	if f, ok := safeString(config["format"]); ok && f != "" {
+		format = f
+	}
+	switch format {
+	case "json", "csv":
+	default:
+		return nil, fmt.Errorf("unsupported exec format: %s (must be json or csv)", format)
+	}
+
+	timeout := 30 * time.Second
+	if t, ok := safeString(config["timeout"]); ok {
+		parsed, err := time.ParseDuration(t)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid exec timeout %q", t)
+		}
+		timeout = parsed
+	}
+
+	return &ExecStream{
+		command:   substituteEnvVars(command),
+		args:      args,
+		format:    format,
+		timeout:   timeout,
+		formatter: &DebugStream{format: "json"},
+	}, nil
+}
+
+// Load runs the command with the batch on stdin. The process is killed when the timeout
+// passes or ctx is cancelled; a non-zero exit fails the load with the command's stderr
+func (e *ExecStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var input bytes.Buffer
+	if e.format == "csv" {
+		if err := writeCSVResults(&input, results); err != nil {
+			return err
+		}
+	} else {
+		output, _, err := e.formatter.generateJSONFormat(results)
+		if err != nil {
+			return fmt.Errorf("failed to generate json output: %w", err)
+		}
+		input.Write(output)
+		input.WriteByte('\n')
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.command, e.args...)
+	cmd.Stdin = &input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = time.Second // Don't wait on pipes held open by orphaned children
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("command %s killed: %w", e.command, ctx.Err())
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fmt.Errorf("command %s exited with code %d: %s", e.command, exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("failed to run command %s: %w", e.command, err)
+	}
+
+	if out := strings.TrimSpace(stdout.String()); out != "" {
+		e.logger.Printf("Exec load output from %s: %s", e.command, out)
+	}
+	return nil
+}
+
+// writeCSVResults writes the CSV rows of results to w, with the first result's headers once
+func writeCSVResults(w io.Writer, results []*transform.TransformedResult) error {
+	writer := csv.NewWriter(w)
+	headersWritten := false
+	for _, result := range results {
+		if len(result.CSVHeaders) == 0 || len(result.CSVData) == 0 {
+			continue
+		}
+		if !headersWritten {
+			if err := writer.Write(result.CSVHeaders); err != nil {
+				return fmt.Errorf("failed to write CSV headers: %w", err)
+			}
+			headersWritten = true
+		}
+		if err := writer.WriteAll(result.CSVData); err != nil {
+			return fmt.Errorf("failed to write CSV rows: %w", err)
+		}
+	}
+	return nil
+}
+
+// setLogger sets the logger reporting command output
+func (e *ExecStream) setLogger(logger *logging.Logger) {
+	e.logger = logger
+}
+
+// Close does nothing; each load runs its own process
+func (e *ExecStream) Close() error {
+	return nil
+}
+
+// GetType returns the stream type
+func (e *ExecStream) GetType() string {
+	return "exec"
+}
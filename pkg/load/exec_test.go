@@ -0,0 +1,94 @@
+//go:build !windows
+
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+)
+
+func execResults() []*transform.TransformedResult {
+	return []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "http://es:9200", Metadata: map[string]interface{}{}},
+		TransformedData: map[string]interface{}{"up": 1.0},
+		CSVHeaders:      []string{"host", "cpu"},
+		CSVData:         [][]string{{"web-1", "0.5"}, {"web-2", "0.7"}},
+	}}
+}
+
+func TestExecStreamPipesThroughCat(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("EXEC_OUT_DIR", dir)
+
+	for _, format := range []string{"json", "csv"} {
+		stream, err := NewExecStream(map[string]interface{}{
+			"command": "sh",
+			"args":    []interface{}{"-c", "cat > ${EXEC_OUT_DIR}/" + format},
+			"format":  format,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Load(context.Background(), execResults()); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format == "csv" {
+			if got, want := string(data), "host,cpu\nweb-1,0.5\nweb-2,0.7\n"; got != want {
+				t.Errorf("csv input = %q, want %q", got, want)
+			}
+			continue
+		}
+		var batch map[string]interface{}
+		if err := json.Unmarshal(data, &batch); err != nil {
+			t.Fatalf("json input %q: %v", data, err)
+		}
+		if batch["results_count"] != 1.0 {
+			t.Errorf("results_count = %v, want 1", batch["results_count"])
+		}
+	}
+}
+
+func TestExecStreamCommandFails(t *testing.T) {
+	stream, err := NewExecStream(map[string]interface{}{
+		"command": "sh",
+		"args":    []interface{}{"-c", "cat > /dev/null; echo rejected >&2; exit 3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Load(context.Background(), execResults())
+	if err == nil || !strings.Contains(err.Error(), "exited with code 3: rejected") {
+		t.Errorf("Load error = %v, want the exit code and stderr", err)
+	}
+}
+
+func TestExecStreamTimeout(t *testing.T) {
+	stream, err := NewExecStream(map[string]interface{}{
+		"command": "sleep",
+		"args":    []interface{}{"5"},
+		"timeout": "50ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	if err := stream.Load(context.Background(), execResults()); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Errorf("Load error = %v, want the command killed", err)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("Load returned after %v, want it bounded by the timeout", elapsed)
+	}
+}
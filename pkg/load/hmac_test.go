@@ -0,0 +1,99 @@
+package load
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseRequestSigner(t *testing.T) {
+	t.Setenv("HMAC_TEST_SECRET", "from-env")
+
+	signer, err := parseRequestSigner(map[string]interface{}{})
+	if err != nil || signer != nil {
+		t.Fatalf("no hmac configured = %v, %v; want no signer", signer, err)
+	}
+
+	signer, err = parseRequestSigner(map[string]interface{}{"hmac": map[string]interface{}{"secret": "${HMAC_TEST_SECRET}"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.header != defaultSignatureHeader || signer.algorithm != "sha256" || string(signer.secret) != "from-env" {
+		t.Errorf("defaults = %s %s %s, want %s sha256 from-env", signer.header, signer.algorithm, signer.secret, defaultSignatureHeader)
+	}
+
+	signer, err = parseRequestSigner(map[string]interface{}{"hmac": map[string]interface{}{"secret": "s", "header": "X-Hub", "algorithm": "sha512"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer.header != "X-Hub" || signer.algorithm != "sha512" {
+		t.Errorf("custom = %s %s, want X-Hub sha512", signer.header, signer.algorithm)
+	}
+
+	for _, config := range []map[string]interface{}{
+		{"hmac": map[string]interface{}{}},
+		{"hmac": map[string]interface{}{"secret": ""}},
+		{"hmac": map[string]interface{}{"secret": "s", "algorithm": "md5"}},
+		{"hmac": "s"},
+	} {
+		if _, err := parseRequestSigner(config); err == nil {
+			t.Errorf("parseRequestSigner(%v) succeeded, want an error", config)
+		}
+	}
+}
+
+func TestRequestSignerSign(t *testing.T) {
+	signer, err := parseRequestSigner(map[string]interface{}{"hmac": map[string]interface{}{"secret": "key", "algorithm": "sha512"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"a":1}`)
+	req, _ := http.NewRequest(http.MethodPost, "http://receiver", nil)
+	signer.sign(req, body)
+
+	mac := hmac.New(sha512.New, []byte("key"))
+	mac.Write(body)
+	if got, want := req.Header.Get(defaultSignatureHeader), "sha512="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature header = %s, want %s", got, want)
+	}
+}
+
+func TestGEMStreamSignsRequests(t *testing.T) {
+	var mu sync.Mutex
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get("X-Signature")
+	}))
+	defer server.Close()
+
+	stream, err := NewGEMStream(map[string]interface{}{
+		"endpoint": server.URL,
+		"hmac":     map[string]interface{}{"secret": "key"},
+	}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Load(context.Background(), gemResults(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The signature covers the exact bytes sent, after compression
+	mu.Lock()
+	defer mu.Unlock()
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); signature != want {
+		t.Errorf("signature = %q, want %q", signature, want)
+	}
+}
@@ -1,29 +1,161 @@
 package load
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"elasticetl/pkg/config"
+	"elasticetl/pkg/logging"
 	"elasticetl/pkg/transform"
+	"elasticetl/pkg/utils"
 
 	"github.com/golang/snappy"
 	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
 )
 
+// rowTimestamp returns the sample timestamp (milliseconds) for a CSV row. The configured
+// timestamp column is used when present and numeric; with timestamp_source "now" a missing or
+// unconfigured (NoTimestampColumn) column falls back to the extraction time instead of skipping
+// the row
+func rowTimestamp(row []string, metric config.PrometheusMetricConfig, extractedAt time.Time) (int64, bool) {
+	if metric.Timestamp >= 0 && metric.Timestamp < len(row) {
+		if f, err := strconv.ParseFloat(row[metric.Timestamp], 64); err == nil {
+			return int64(f), true
+		}
+	}
+
+	if metric.TimestampSource == config.TimestampSourceNow {
+		return extractedAt.UnixMilli(), true
+	}
+
+	return 0, false
+}
+
+// labelPair is a label name and value
+type labelPair struct {
+	name  string
+	value string
+}
+
+// capturedHeaderLabels returns the response headers captured during extraction as labels,
+// sorted by name. Header names are lowercased with non-alphanumeric characters replaced by '_'
+func capturedHeaderLabels(metadata map[string]interface{}) []labelPair {
+	headers, ok := metadata["headers"].(map[string]string)
+	if !ok || len(headers) == 0 {
+		return nil
+	}
+
+	pairs := make([]labelPair, 0, len(headers))
+	for name, value := range headers {
+		pairs = append(pairs, labelPair{name: headerLabelName(name), value: value})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].name < pairs[j].name
+	})
+	return pairs
+}
+
+// metricFamily returns the metric name for a TransformedData key. Keys extracted by a json_paths
+// entry with a metric_name belong to that family, with the rest of the key as a field label;
+// other keys are their own metric name
+func metricFamily(metadata map[string]interface{}, key string) (string, []labelPair) {
+	families, ok := metadata["metric_families"].(map[string]string)
+	if !ok {
+		return key, nil
+	}
+
+	// Prefer the longest matching prefix so nested families resolve to the innermost one
+	name, field, matched := key, "", 0
+	for prefix, family := range families {
+		if len(prefix) <= matched {
+			continue
+		}
+		switch {
+		case key == prefix:
+			name, field, matched = family, "", len(prefix)
+		case strings.HasPrefix(key, prefix+"."), strings.HasPrefix(key, prefix+"["):
+			name, field, matched = family, strings.TrimPrefix(key[len(prefix):], "."), len(prefix)
+		}
+	}
+	if field == "" {
+		return name, nil
+	}
+	return name, []labelPair{{name: "field", value: field}}
+}
+
+// sortLabelPairs sorts rendered name="value" label pairs by label name, so series are emitted
+// identically on every run despite map iteration order
+func sortLabelPairs(pairs []string) {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		nameI, _, _ := strings.Cut(pairs[i], "=")
+		nameJ, _, _ := strings.Cut(pairs[j], "=")
+		return nameI < nameJ
+	})
+}
+
+// labelValueEscaper escapes label values for the text exposition format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabelPair renders a label as name="value", escaping backslashes, quotes and newlines in
+// the value as the exposition format requires
+func formatLabelPair(name, value string) string {
+	return name + `="` + labelValueEscaper.Replace(value) + `"`
+}
+
+// formatSampleValue renders a sample value for the exposition format: the shortest exact
+// representation, with +Inf, -Inf and NaN spelled as Prometheus expects
+func formatSampleValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortLabels sorts remote write labels by name, as the remote write protocol expects
+func sortLabels(labels []prompb.Label) {
+	sort.SliceStable(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+}
+
+// headerLabelName converts an HTTP header name into a valid label name
+func headerLabelName(header string) string {
+	var b strings.Builder
+	for i, r := range strings.ToLower(header) {
+		if (r >= 'a' && r <= 'z') || r == '_' || (i > 0 && r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
 // substituteEnvVars replaces environment variables in the format ${VAR_NAME}
 func substituteEnvVars(input string) string {
 	re := regexp.MustCompile(`\$\{([^}]+)\}`)
@@ -75,6 +207,158 @@ func parseBasicAuth(config map[string]interface{}) (string, error) {
 	return createBasicAuthHeader(username, password), nil
 }
 
+// retryPolicy controls how a stream retries throttled or failed requests
+type retryPolicy struct {
+	maxRetries    int           // Retries after the first attempt (0 disables retries)
+	maxRetryAfter time.Duration // Cap on Retry-After delays (utils.DefaultMaxRetryAfter when 0)
+}
+
+// parseRetryPolicy reads max_retries and max_retry_after from stream config
+func parseRetryPolicy(config map[string]interface{}) (retryPolicy, error) {
+	var policy retryPolicy
+	if raw, ok := config["max_retries"]; ok {
+		retries, ok := utils.SafeInt(raw)
+		if !ok || retries < 0 {
+			return policy, fmt.Errorf("max_retries must be a non-negative integer")
+		}
+		policy.maxRetries = retries
+	}
+	if s, ok := safeString(config["max_retry_after"]); ok {
+		parsed, err := time.ParseDuration(s)
+		if err != nil || parsed < 0 {
+			return policy, fmt.Errorf("invalid max_retry_after %q", s)
+		}
+		policy.maxRetryAfter = parsed
+	}
+	return policy, nil
+}
+
+// do sends req, retrying transport errors, 429 and 5xx responses. Retries wait for the
+// response's Retry-After when present, otherwise back off linearly
+func (r retryPolicy) do(ctx context.Context, client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := client.Do(req)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= r.maxRetries || req.GetBody == nil {
+			return resp, err
+		}
+
+		delay := utils.RetryDelay(resp, time.Duration(attempt+1)*time.Second, r.maxRetryAfter)
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		// Rewind the body for the next attempt
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset request body: %w", err)
+		}
+		req.Body = body
+	}
+}
+
+// defaultOnlyChangedTTL is how long an unchanged series may go unsent before it is resent
+const defaultOnlyChangedTTL = 5 * time.Minute
+
+// changeCache remembers the last value sent per series so streams with only_changed
+// skip samples whose value has not changed. Series are resent once their entry is older
+// than ttl so the remote end does not mark them stale
+type changeCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]changeEntry
+}
+
+// changeEntry is the last value sent for a series and when it was sent
+type changeEntry struct {
+	value  float64
+	sentAt time.Time
+}
+
+// newChangeCache reads only_changed and only_changed_ttl from stream config, returning
+// nil when only_changed is not enabled
+func newChangeCache(config map[string]interface{}) (*changeCache, error) {
+	enabled, _ := utils.SafeBool(config["only_changed"])
+	if !enabled {
+		return nil, nil
+	}
+
+	ttl := defaultOnlyChangedTTL
+	if s, ok := safeString(config["only_changed_ttl"]); ok {
+		parsed, err := time.ParseDuration(s)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid only_changed_ttl %q", s)
+		}
+		ttl = parsed
+	}
+
+	return &changeCache{
+		ttl:     ttl,
+		entries: make(map[string]changeEntry),
+	}, nil
+}
+
+// changed reports whether a series whose latest value is value should be sent, recording it
+// in pending. The cache itself is only updated by commit once the send succeeds
+func (c *changeCache) changed(key string, value float64, now time.Time, pending map[string]changeEntry) bool {
+	last, ok := pending[key]
+	if !ok {
+		c.mutex.Lock()
+		last, ok = c.entries[key]
+		c.mutex.Unlock()
+		if ok && now.Sub(last.sentAt) >= c.ttl {
+			ok = false
+		}
+	}
+
+	if ok && last.value == value {
+		return false
+	}
+
+	pending[key] = changeEntry{value: value, sentAt: now}
+	return true
+}
+
+// commit records the values of a successful send and drops expired series
+func (c *changeCache) commit(pending map[string]changeEntry) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.Sub(entry.sentAt) >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+	for key, entry := range pending {
+		c.entries[key] = entry
+	}
+}
+
+// seriesKey builds a stable cache key from a series' labels
+func seriesKey(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[name]))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
 // safeString safely converts a value to string, handling both JSON and YAML parsing
 func safeString(value interface{}) (string, bool) {
 	if value == nil {
@@ -88,6 +372,8 @@ func safeString(value interface{}) (string, bool) {
 		return fmt.Sprintf("%d", v), true
 	case int64:
 		return fmt.Sprintf("%d", v), true
+	case json.Number:
+		return v.String(), true
 	case float64:
 		// Check if it's actually an integer value
 		if v == float64(int64(v)) {
@@ -131,9 +417,14 @@ func safeMapStringInterface(value interface{}) (map[string]interface{}, bool) {
 
 // Loader handles data loading to various destinations
 type Loader struct {
-	config  config.LoadConfig
-	streams []Stream
-	mutex   sync.RWMutex
+	config     config.LoadConfig
+	streams    []Stream
+	transports *transportCache // Shared by the current streams
+	mutex      sync.RWMutex
+	onDropped  func(dropped int)
+	inflight   sync.WaitGroup // Loads in progress; streams are closed only once they finish
+	closed     bool
+	logger     *logging.Logger // Passed to streams that log
 }
 
 // Stream interface for different load destinations
@@ -143,15 +434,25 @@ type Stream interface {
 	GetType() string
 }
 
-// NewLoader creates a new loader
-func NewLoader(cfg config.LoadConfig) (*Loader, error) {
+// NewLoader creates a new loader whose streams log through logger
+func NewLoader(cfg config.LoadConfig, logger *logging.Logger) (*Loader, error) {
 	loader := &Loader{
-		config: cfg,
+		config:     cfg,
+		transports: newTransportCache(),
+		logger:     logger,
 	}
 
 	// Initialize streams
 	for _, streamCfg := range cfg.Streams {
-		stream, err := createStream(streamCfg, cfg.Metrics)
+		enabled, err := streamEnabled(streamCfg)
+		if err != nil {
+			return nil, fmt.Errorf("stream %s: %w", streamCfg.Type, err)
+		}
+		if !enabled {
+			continue
+		}
+
+		stream, err := createStream(streamCfg, cfg, loader.transports, loader.logger)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create stream %s: %w", streamCfg.Type, err)
 		}
@@ -161,13 +462,43 @@ func NewLoader(cfg config.LoadConfig) (*Loader, error) {
 	return loader, nil
 }
 
+// SetDroppedSeriesHandler sets a callback receiving the number of series dropped by
+// max_series_per_run on each load that exceeds it
+func (l *Loader) SetDroppedSeriesHandler(handler func(dropped int)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.onDropped = handler
+}
+
 // Load loads data to all configured streams
 func (l *Loader) Load(ctx context.Context, results []*transform.TransformedResult) error {
 	l.mutex.RLock()
+	if l.closed {
+		l.mutex.RUnlock()
+		return fmt.Errorf("loader is closed")
+	}
+	// Registered under the lock so Close and UpdateConfig, which hold it exclusively, see it
+	l.inflight.Add(1)
+	defer l.inflight.Done()
 	streams := make([]Stream, len(l.streams))
 	copy(streams, l.streams)
+	maxSeries := l.config.MaxSeriesPerRun
+	priorityColumn := l.config.SeriesPriorityColumn
+	columns := seriesColumns(l.config.Metrics)
+	failurePolicy := l.config.FailurePolicy
+	onDropped := l.onDropped
 	l.mutex.RUnlock()
 
+	// Enforce the series budget before any stream sees the results
+	if maxSeries > 0 {
+		var dropped int
+		results, dropped = limitSeries(results, maxSeries, priorityColumn, columns)
+		if dropped > 0 && onDropped != nil {
+			onDropped(dropped)
+		}
+	}
+
 	var wg sync.WaitGroup
 	errorsChan := make(chan error, len(streams))
 
@@ -194,24 +525,55 @@ func (l *Loader) Load(ctx context.Context, results []*transform.TransformedResul
 		errors = append(errors, err)
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("load errors: %v", errors)
+	if len(errors) == 0 {
+		return nil
 	}
 
-	return nil
+	// Tolerated failures are still logged so failing streams stay visible
+	err := fmt.Errorf("load errors: %v", errors)
+	switch failurePolicy {
+	case config.FailurePolicyAny:
+		if len(errors) < len(streams) {
+			l.logger.Printf("%d of %d streams failed: %v", len(errors), len(streams), err)
+			return nil
+		}
+	case config.FailurePolicyBestEffort:
+		l.logger.Printf("%d of %d streams failed: %v", len(errors), len(streams), err)
+		return nil
+	}
+	return err
 }
 
-// Close closes all streams
+// Delivers reports whether any stream ships data to a real sink, as opposed to only the debug
+// stream
+func (l *Loader) Delivers() bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	for _, stream := range l.streams {
+		if stream.GetType() != "debug" {
+			return true
+		}
+	}
+	return false
+}
+
+// Close closes all streams once loads in progress have finished. Later loads fail
 func (l *Loader) Close() error {
 	l.mutex.Lock()
-	defer l.mutex.Unlock()
+	l.closed = true
+	streams, transports := l.streams, l.transports
+	l.mutex.Unlock()
+
+	l.inflight.Wait()
 
 	var errors []error
-	for _, stream := range l.streams {
+	for _, stream := range streams {
 		if err := stream.Close(); err != nil {
 			errors = append(errors, err)
 		}
 	}
+	transports.closeIdleConnections()
 
 	if len(errors) > 0 {
 		return fmt.Errorf("close errors: %v", errors)
@@ -225,15 +587,26 @@ func (l *Loader) UpdateConfig(cfg config.LoadConfig) error {
 	l.mutex.Lock()
 	defer l.mutex.Unlock()
 
-	// Close existing streams
+	// Close existing streams once loads in progress have finished; new loads wait on the lock
+	l.inflight.Wait()
 	for _, stream := range l.streams {
 		stream.Close()
 	}
+	l.transports.closeIdleConnections()
 
 	// Create new streams
 	l.streams = nil
+	l.transports = newTransportCache()
 	for _, streamCfg := range cfg.Streams {
-		stream, err := createStream(streamCfg, cfg.Metrics)
+		enabled, err := streamEnabled(streamCfg)
+		if err != nil {
+			return fmt.Errorf("stream %s: %w", streamCfg.Type, err)
+		}
+		if !enabled {
+			continue
+		}
+
+		stream, err := createStream(streamCfg, cfg, l.transports, l.logger)
 		if err != nil {
 			return fmt.Errorf("failed to create stream %s: %w", streamCfg.Type, err)
 		}
@@ -244,8 +617,245 @@ func (l *Loader) UpdateConfig(cfg config.LoadConfig) error {
 	return nil
 }
 
-// createStream creates a stream based on configuration
-func createStream(cfg config.StreamConfig, metrics []config.PrometheusMetricConfig) (Stream, error) {
+// connLimiter is implemented by streams that send over HTTP and can cap connections per host
+type connLimiter interface {
+	setMaxConnsPerHost(n int)
+}
+
+// streamLogger is implemented by streams that log, so their lines name the pipeline
+type streamLogger interface {
+	setLogger(logger *logging.Logger)
+}
+
+// transportSharer is implemented by streams that send over HTTP and can share their transport
+// with other streams to the same host
+type transportSharer interface {
+	shareTransport(cache *transportCache)
+}
+
+// limitConnsPerHost caps concurrent connections per host on client's transport
+func limitConnsPerHost(client *http.Client, n int) {
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		transport.MaxConnsPerHost = n
+	}
+}
+
+// metricPrefixer is implemented by streams that emit named metrics and support metric_prefix
+type metricPrefixer interface {
+	setMetricPrefix(prefix string)
+}
+
+// createStream creates a stream based on configuration, applying the metric prefix (the
+// stream's own metric_prefix, else the load-level default) to streams that name metrics
+// and restricting streams whose input is transformed_data to the transformed data. HTTP
+// streams share transports through transports
+func createStream(cfg config.StreamConfig, loadCfg config.LoadConfig, transports *transportCache, logger *logging.Logger) (Stream, error) {
+	stream, err := newStream(cfg, loadCfg.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	if streamLogger, ok := stream.(streamLogger); ok {
+		streamLogger.setLogger(logger)
+	}
+
+	metricPrefix := loadCfg.MetricPrefix
+
+	if prefix, ok := safeString(cfg.Config["metric_prefix"]); ok {
+		if err := config.ValidateMetricPrefix(prefix); err != nil {
+			return nil, err
+		}
+		metricPrefix = prefix
+	}
+
+	if prefixer, ok := stream.(metricPrefixer); ok && metricPrefix != "" {
+		prefixer.setMetricPrefix(metricPrefix)
+	}
+
+	maxConns := loadCfg.MaxConnsPerHost
+	if raw, ok := cfg.Config["max_conns_per_host"]; ok {
+		n, ok := utils.SafeInt(raw)
+		if !ok || n < 0 {
+			return nil, fmt.Errorf("max_conns_per_host must be a non-negative integer")
+		}
+		maxConns = n
+	}
+
+	if limiter, ok := stream.(connLimiter); ok && maxConns > 0 {
+		limiter.setMaxConnsPerHost(maxConns)
+	}
+
+	// Share after the connection limit is set, since it is part of the transport's identity
+	if sharer, ok := stream.(transportSharer); ok {
+		sharer.shareTransport(transports)
+	}
+
+	if streamInput(cfg, loadCfg) == config.InputTransformedData {
+		stream = transformedDataStream{stream}
+	}
+
+	stream, err = parseSchedule(stream, cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := loadCfg.StreamTimeout
+	if t, ok := safeString(cfg.Config["load_timeout"]); ok {
+		parsed, err := time.ParseDuration(t)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid load_timeout %q", t)
+		}
+		timeout = parsed
+	}
+
+	if timeout > 0 {
+		stream = &timeoutStream{Stream: stream, timeout: timeout, logger: logger}
+	}
+
+	return stream, nil
+}
+
+// streamEnabled reports whether a stream should be created. The optional enabled key takes a
+// boolean or a string with environment variable references such as "${DEBUG_ENABLED}"
+func streamEnabled(cfg config.StreamConfig) (bool, error) {
+	switch v := cfg.Config["enabled"].(type) {
+	case nil:
+		return true, nil
+	case bool:
+		return v, nil
+	case string:
+		enabled, err := config.ParseEnvBool(v)
+		if err != nil {
+			return false, fmt.Errorf("enabled: %w", err)
+		}
+		return enabled, nil
+	default:
+		return false, fmt.Errorf("enabled must be a boolean or a string, got %T", v)
+	}
+}
+
+// streamInput returns the input a stream consumes: its own input, else the load-level default
+func streamInput(cfg config.StreamConfig, loadCfg config.LoadConfig) string {
+	if cfg.Input != "" {
+		return cfg.Input
+	}
+	return loadCfg.Input
+}
+
+// RequiresCSV reports whether any stream in cfg consumes CSV data, so the transformer must
+// generate it regardless of the transform output format
+func RequiresCSV(cfg config.LoadConfig) bool {
+	for _, streamCfg := range cfg.Streams {
+		if enabled, err := streamEnabled(streamCfg); err == nil && !enabled {
+			continue
+		}
+		input := streamInput(streamCfg, cfg)
+		if input == config.InputCSVData || ((streamCfg.Type == "csv" || streamCfg.Type == "avro") && input != config.InputTransformedData) {
+			return true
+		}
+	}
+	return false
+}
+
+// WritesStdout reports whether any enabled stream in cfg writes to standard output
+func WritesStdout(cfg config.LoadConfig) bool {
+	for _, streamCfg := range cfg.Streams {
+		if enabled, err := streamEnabled(streamCfg); err == nil && !enabled {
+			continue
+		}
+		if streamCfg.Type == "stdout" {
+			return true
+		}
+	}
+	return false
+}
+
+// transformedDataStream passes results to the wrapped stream without their CSV data so the
+// stream loads from TransformedData
+type transformedDataStream struct {
+	Stream
+}
+
+// Load strips CSV data from the results and loads them to the wrapped stream
+func (s transformedDataStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	stripped := make([]*transform.TransformedResult, len(results))
+	for i, result := range results {
+		stripped[i] = &transform.TransformedResult{
+			Result:          result.Result,
+			TransformedData: result.TransformedData,
+		}
+	}
+	return s.Stream.Load(ctx, stripped)
+}
+
+// timeoutStream bounds each Load of the wrapped stream with its own deadline
+type timeoutStream struct {
+	Stream
+	timeout time.Duration
+	pending sync.WaitGroup // Wrapped loads still running, including ones past their deadline
+	logger  *logging.Logger
+}
+
+// Load loads to the wrapped stream, returning once the deadline passes even if the stream
+// ignores its context, so one stuck stream does not hold up the rest of the batch
+func (s *timeoutStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		done <- s.Stream.Load(ctx, results)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("load did not finish within %s: %w", s.timeout, ctx.Err())
+	}
+}
+
+// Close closes the wrapped stream once loads abandoned at their deadline have returned, so the
+// stream is not closed or replaced while one is still writing to it. The wait is bounded by the
+// stream timeout so a load that ignores its context cannot hang reloads or shutdown; past it
+// the stream is closed anyway
+func (s *timeoutStream) Close() error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(s.timeout):
+		s.logger.Printf("Warning: closing %s stream with a load still running after %s", s.Stream.GetType(), s.timeout)
+	}
+	return s.Stream.Close()
+}
+
+// prefixMetricName prepends prefix to name unless the name already carries it
+func prefixMetricName(prefix, name string) string {
+	if prefix == "" || strings.HasPrefix(name, prefix) {
+		return name
+	}
+	return prefix + name
+}
+
+// prefixMetricConfigs returns a copy of metrics with prefix applied to each metric name
+func prefixMetricConfigs(prefix string, metrics []config.PrometheusMetricConfig) []config.PrometheusMetricConfig {
+	prefixed := make([]config.PrometheusMetricConfig, len(metrics))
+	for i, metric := range metrics {
+		metric.Name = prefixMetricName(prefix, metric.Name)
+		prefixed[i] = metric
+	}
+	return prefixed
+}
+
+// newStream constructs the stream for cfg.Type
+func newStream(cfg config.StreamConfig, metrics []config.PrometheusMetricConfig) (Stream, error) {
 	switch cfg.Type {
 	case "gem":
 		return NewGEMStream(cfg.Config, cfg.Labels, cfg.InsecureTLS, metrics)
@@ -259,6 +869,14 @@ func createStream(cfg config.StreamConfig, metrics []config.PrometheusMetricConf
 		return NewDebugStream(cfg.Config, metrics)
 	case "csv":
 		return NewCSVStream(cfg.Config)
+	case "stdout":
+		return NewStdoutStream(cfg.Config, metrics)
+	case "syslog":
+		return NewSyslogStream(cfg.Config)
+	case "exec":
+		return NewExecStream(cfg.Config)
+	case "avro":
+		return NewAvroStream(cfg.Config)
 	default:
 		return nil, fmt.Errorf("unsupported stream type: %s", cfg.Type)
 	}
@@ -266,10 +884,26 @@ func createStream(cfg config.StreamConfig, metrics []config.PrometheusMetricConf
 
 // GEMStream handles loading to GEM with Prometheus remote write
 type GEMStream struct {
-	endpoint   string
-	httpClient *http.Client
-	labels     map[string]string
-	metrics    []config.PrometheusMetricConfig
+	endpoint     string
+	httpClient   *http.Client
+	retry        retryPolicy
+	labels       map[string]string
+	metrics      []config.PrometheusMetricConfig
+	metricPrefix string
+	changes      *changeCache   // Set when only_changed is enabled
+	signer       *requestSigner // Set when hmac is configured
+	maxSamples   int            // Samples per write request; 0 sends everything in one request
+	compression  string         // snappy (default) or gzip request body encoding; empty sends uncompressed
+	uncompressed atomic.Bool    // Set once GEM rejects the encoding, so later writes skip it
+	fallbackOnce sync.Once
+	logger       *logging.Logger
+}
+
+// gemCompressions maps the GEM stream's compression setting to its Content-Encoding
+var gemCompressions = map[string]string{
+	"none":   "",
+	"gzip":   "gzip",
+	"snappy": "snappy",
 }
 
 // NewGEMStream creates a new GEM stream
@@ -294,10 +928,45 @@ func NewGEMStream(config map[string]interface{}, labels map[string]string, insec
 		}
 	}
 
+	retry, err := parseRetryPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("gem stream: %w", err)
+	}
+
+	changes, err := newChangeCache(config)
+	if err != nil {
+		return nil, fmt.Errorf("gem stream: %w", err)
+	}
+
+	maxSamples, err := parseMaxSamplesPerRequest(config)
+	if err != nil {
+		return nil, fmt.Errorf("gem stream: %w", err)
+	}
+
+	// Remote write expects snappy; gzip and none are for gateways that decode them
+	compression := "snappy"
+	if c, ok := safeString(config["compression"]); ok {
+		encoding, ok := gemCompressions[c]
+		if !ok {
+			return nil, fmt.Errorf("gem stream: invalid compression %q (must be snappy, gzip or none)", c)
+		}
+		compression = encoding
+	}
+
+	signer, err := parseRequestSigner(config)
+	if err != nil {
+		return nil, fmt.Errorf("gem stream: %w", err)
+	}
+
 	return &GEMStream{
-		endpoint: endpoint,
-		labels:   labels,
-		metrics:  metrics,
+		endpoint:    endpoint,
+		labels:      labels,
+		metrics:     metrics,
+		retry:       retry,
+		changes:     changes,
+		signer:      signer,
+		maxSamples:  maxSamples,
+		compression: compression,
 		httpClient: &http.Client{
 			Timeout:   timeout,
 			Transport: transport,
@@ -305,46 +974,252 @@ func NewGEMStream(config map[string]interface{}, labels map[string]string, insec
 	}, nil
 }
 
-// Load loads data to GEM
+// Load loads data to GEM as a protobuf prometheus.WriteRequest, snappy-compressed by default
 func (g *GEMStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
 	// Convert results to Prometheus remote write format
 	samples := g.convertToPrometheusSamples(results)
+
+	// Drop series whose latest value has not changed since the last send
+	pending := make(map[string]changeEntry)
+	if g.changes != nil {
+		samples = g.filterChanged(samples, pending)
+	}
 	if len(samples) == 0 {
 		return nil
 	}
 
-	// Create remote write request
-	writeRequest := map[string]interface{}{
-		"timeseries": samples,
+	// Send in requests of at most max_samples_per_request samples
+	if err := sendBatches(chunkTimeSeries(g.toTimeSeries(samples), g.maxSamples), func(batch []*prompb.TimeSeries) error {
+		return g.send(ctx, batch)
+	}); err != nil {
+		return err
 	}
 
-	jsonData, err := json.Marshal(writeRequest)
-	if err != nil {
-		return fmt.Errorf("failed to marshal prometheus data: %w", err)
+	if g.changes != nil {
+		g.changes.commit(pending)
 	}
 
-	// Send to GEM endpoint
-	req, err := http.NewRequestWithContext(ctx, "POST", g.endpoint, bytes.NewBuffer(jsonData))
+	return nil
+}
+
+// send posts time series to GEM as one snappy-compressed protobuf write request
+func (g *GEMStream) send(ctx context.Context, timeSeries []*prompb.TimeSeries) error {
+	data, err := encodeWriteRequestV1(timeSeries)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to marshal write request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
-
-	resp, err := g.httpClient.Do(req)
+	// Compress unless GEM has already rejected the encoding
+	encoding := g.compression
+	if g.uncompressed.Load() {
+		encoding = ""
+	}
+	resp, err := g.post(ctx, data, encoding)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return err
+	}
+	if encoding != "" && unsupportedEncoding(resp) {
+		resp.Body.Close()
+		g.uncompressed.Store(true)
+		g.fallbackOnce.Do(func() {
+			g.logger.Printf("Warning: GEM endpoint %s rejected %s request encoding, sending uncompressed", g.endpoint, encoding)
+		})
+		resp, err = g.post(ctx, data, "")
+		if err != nil {
+			return err
+		}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
 		return fmt.Errorf("GEM returned status %d", resp.StatusCode)
 	}
+	return nil
+}
+
+// post sends the encoded write request to GEM with the given Content-Encoding
+func (g *GEMStream) post(ctx context.Context, data []byte, encoding string) (*http.Response, error) {
+	body := data
+	switch encoding {
+	case "gzip":
+		compressed, err := gzipBytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		body = compressed
+	case "snappy":
+		body = snappy.Encode(nil, data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if g.signer != nil {
+		g.signer.sign(req, body)
+	}
+
+	resp, err := g.retry.do(ctx, g.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	return resp, nil
+}
+
+// unsupportedEncoding reports whether resp rejects the request's Content-Encoding: a 415, or a
+// 400 whose body mentions the encoding. The body is restored so callers can still read it
+func unsupportedEncoding(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnsupportedMediaType:
+		return true
+	case http.StatusBadRequest:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		message := strings.ToLower(string(body))
+		return strings.Contains(message, "encoding") || strings.Contains(message, "compress") ||
+			strings.Contains(message, "snappy") || strings.Contains(message, "gzip")
+	}
+	return false
+}
+
+// setLogger sets the logger reporting encoding fallbacks
+func (g *GEMStream) setLogger(logger *logging.Logger) {
+	g.logger = logger
+}
 
+// parseMaxSamplesPerRequest reads max_samples_per_request, the most samples sent in one remote
+// write request; 0 (the default) sends every sample in one request
+func parseMaxSamplesPerRequest(config map[string]interface{}) (int, error) {
+	raw, ok := config["max_samples_per_request"]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := utils.SafeInt(raw)
+	if !ok || n < 0 {
+		return 0, fmt.Errorf("max_samples_per_request must be a non-negative integer")
+	}
+	return n, nil
+}
+
+// sendBatches sends each batch in order. Every batch is attempted even if an earlier one fails,
+// and the failures are returned together; callers record unchanged values only on success
+func sendBatches(batches [][]*prompb.TimeSeries, send func(batch []*prompb.TimeSeries) error) error {
+	var errors []error
+	for i, batch := range batches {
+		if err := send(batch); err != nil {
+			if len(batches) > 1 {
+				err = fmt.Errorf("batch %d of %d: %w", i+1, len(batches), err)
+			}
+			errors = append(errors, err)
+		}
+	}
+
+	if len(errors) == 1 {
+		return errors[0]
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%d of %d requests failed: %v", len(errors), len(batches), errors)
+	}
 	return nil
 }
 
+// chunkTimeSeries splits time series into batches of at most maxSamples samples, splitting a
+// series with more samples across batches. maxSamples <= 0 keeps a single batch
+func chunkTimeSeries(timeSeries []*prompb.TimeSeries, maxSamples int) [][]*prompb.TimeSeries {
+	if maxSamples <= 0 {
+		return [][]*prompb.TimeSeries{timeSeries}
+	}
+
+	var batches [][]*prompb.TimeSeries
+	var batch []*prompb.TimeSeries
+	count := 0
+	for _, ts := range timeSeries {
+		samples := ts.Samples
+		for len(samples) > 0 {
+			if count == maxSamples {
+				batches = append(batches, batch)
+				batch, count = nil, 0
+			}
+			n := min(len(samples), maxSamples-count)
+			batch = append(batch, &prompb.TimeSeries{Labels: ts.Labels, Samples: samples[:n]})
+			samples = samples[n:]
+			count += n
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// toTimeSeries converts the grouped samples to remote write time series with labels sorted by
+// name
+func (g *GEMStream) toTimeSeries(samples []map[string]interface{}) []*prompb.TimeSeries {
+	timeSeries := make([]*prompb.TimeSeries, 0, len(samples))
+	for _, series := range samples {
+		ts := &prompb.TimeSeries{}
+		if labelSets, ok := series["labels"].([]map[string]string); ok {
+			for _, labels := range labelSets {
+				for name, value := range labels {
+					ts.Labels = append(ts.Labels, prompb.Label{Name: name, Value: value})
+				}
+			}
+		}
+		sortLabels(ts.Labels)
+
+		for _, sample := range series["samples"].([]map[string]interface{}) {
+			value, ok := g.toFloat64(sample["value"])
+			if !ok {
+				continue
+			}
+			timestamp, _ := sample["timestamp"].(int64)
+			ts.Samples = append(ts.Samples, prompb.Sample{Value: value, Timestamp: timestamp})
+		}
+		timeSeries = append(timeSeries, ts)
+	}
+	return timeSeries
+}
+
+// filterChanged drops time series whose latest sample has the value last sent for the
+// series. A changed series is sent whole, so earlier samples repeating a value are kept
+func (g *GEMStream) filterChanged(timeSeries []map[string]interface{}, pending map[string]changeEntry) []map[string]interface{} {
+	now := time.Now()
+	var filtered []map[string]interface{}
+	for _, ts := range timeSeries {
+		labels, ok := ts["labels"].([]map[string]string)
+		samples, _ := ts["samples"].([]map[string]interface{})
+		if !ok || len(labels) == 0 || len(samples) == 0 {
+			filtered = append(filtered, ts)
+			continue
+		}
+
+		// The latest sample is the one with the highest timestamp, the last of equals
+		latest := samples[0]
+		for _, sample := range samples[1:] {
+			latestTimestamp, _ := latest["timestamp"].(int64)
+			if timestamp, _ := sample["timestamp"].(int64); timestamp >= latestTimestamp {
+				latest = sample
+			}
+		}
+
+		value, ok := g.toFloat64(latest["value"])
+		if ok && !g.changes.changed(seriesKey(labels[0]), value, now, pending) {
+			continue
+		}
+		filtered = append(filtered, ts)
+	}
+	return filtered
+}
+
 // convertToPrometheusSamples converts transformed results to Prometheus samples using CSV data
 func (g *GEMStream) convertToPrometheusSamples(results []*transform.TransformedResult) []map[string]interface{} {
 	var samples []map[string]interface{}
@@ -354,7 +1229,7 @@ func (g *GEMStream) convertToPrometheusSamples(results []*transform.TransformedR
 		if len(result.CSVData) > 0 && len(g.metrics) > 0 {
 			// Generate time series for each metric using CSV data
 			for _, metric := range g.metrics {
-				metricSamples := g.createPrometheusTimeSeriesForMetric(result.CSVData, metric)
+				metricSamples := g.createPrometheusTimeSeriesForMetric(result.CSVData, metric, result.Timestamp, result.Metadata)
 				samples = append(samples, metricSamples...)
 			}
 			continue
@@ -366,16 +1241,25 @@ func (g *GEMStream) convertToPrometheusSamples(results []*transform.TransformedR
 			// Only include numeric values as metrics
 			if numValue, ok := g.toFloat64(value); ok {
 				// Create labels map starting with metric name and source
+				name, fieldLabels := metricFamily(result.Metadata, key)
 				labels := map[string]string{
-					"__name__": key,
+					"__name__": prefixMetricName(g.metricPrefix, name),
 					"source":   result.Source,
 				}
+				for _, pair := range fieldLabels {
+					labels[pair.name] = pair.value
+				}
 
 				// Add cluster name from metadata if available
 				if clusterName, ok := safeString(result.Metadata["cluster_name"]); ok && clusterName != "" {
 					labels["cluster"] = clusterName
 				}
 
+				// Add captured response headers
+				for _, pair := range capturedHeaderLabels(result.Metadata) {
+					labels[pair.name] = pair.value
+				}
+
 				// Add configured labels
 				for labelKey, labelValue := range g.labels {
 					labels[labelKey] = labelValue
@@ -399,7 +1283,7 @@ func (g *GEMStream) convertToPrometheusSamples(results []*transform.TransformedR
 }
 
 // createPrometheusTimeSeriesForMetric creates Prometheus remote write time series for a specific metric
-func (g *GEMStream) createPrometheusTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig) []map[string]interface{} {
+func (g *GEMStream) createPrometheusTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig, extractedAt time.Time, metadata map[string]interface{}) []map[string]interface{} {
 	var samples []map[string]interface{}
 
 	// Group CSV rows by unique field combinations
@@ -407,7 +1291,7 @@ func (g *GEMStream) createPrometheusTimeSeriesForMetric(csvData [][]string, metr
 
 	for _, row := range csvData {
 		// Check bounds for required columns
-		if metric.Value >= len(row) || metric.Timestamp >= len(row) {
+		if metric.Value >= len(row) {
 			continue // Skip rows that don't have required columns
 		}
 
@@ -426,7 +1310,7 @@ func (g *GEMStream) createPrometheusTimeSeriesForMetric(csvData [][]string, metr
 			continue
 		}
 
-		timestamp, ok := g.parseInt64(row[metric.Timestamp])
+		timestamp, ok := rowTimestamp(row, metric, extractedAt)
 		if !ok {
 			continue
 		}
@@ -464,6 +1348,11 @@ func (g *GEMStream) createPrometheusTimeSeriesForMetric(csvData [][]string, metr
 			}
 		}
 
+		// Add captured response headers
+		for _, pair := range capturedHeaderLabels(metadata) {
+			labels[pair.name] = pair.value
+		}
+
 		// Add configured labels
 		for labelKey, labelValue := range g.labels {
 			labels[labelKey] = labelValue
@@ -511,6 +1400,9 @@ func (g *GEMStream) toFloat64(value interface{}) (float64, bool) {
 	switch v := value.(type) {
 	case float64:
 		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	case int:
 		return float64(v), true
 	case int64:
@@ -525,16 +1417,101 @@ func (g *GEMStream) Close() error {
 	return nil
 }
 
+// setMetricPrefix applies prefix to the configured and fallback metric names
+func (g *GEMStream) setMetricPrefix(prefix string) {
+	g.metricPrefix = prefix
+	g.metrics = prefixMetricConfigs(prefix, g.metrics)
+}
+
+// setMaxConnsPerHost caps concurrent connections per host
+func (g *GEMStream) setMaxConnsPerHost(n int) {
+	limitConnsPerHost(g.httpClient, n)
+}
+
+// shareTransport uses the cached transport for the GEM endpoint's host
+func (g *GEMStream) shareTransport(cache *transportCache) {
+	cache.share(g.httpClient, g.endpoint)
+}
+
 // GetType returns the stream type
 func (g *GEMStream) GetType() string {
 	return "gem"
 }
 
+// OTEL signals supported by the otel stream
+const (
+	otelSignalMetrics = "metrics"
+	otelSignalTraces  = "traces"
+)
+
 // OTELStream handles loading to OpenTelemetry collector
 type OTELStream struct {
-	endpoint   string
-	httpClient *http.Client
-	labels     map[string]string
+	endpoint     string
+	httpClient   *http.Client
+	retry        retryPolicy
+	labels       map[string]string
+	metricPrefix string
+	signal       string          // "metrics" (default) or "traces"
+	spanFields   *otelSpanFields // Field mapping used when signal is "traces"
+	metrics      []config.PrometheusMetricConfig
+	timeUnit     time.Duration  // Unit of CSV timestamp columns (timestamp_unit, default ms)
+	signer       *requestSigner // Set when hmac is configured
+}
+
+// otelTimestampUnits maps timestamp_unit values to their duration
+var otelTimestampUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// otelPointTime returns a data point's time in nanoseconds: the metric's CSV timestamp column in
+// unit when present and numeric, otherwise the extraction time
+func otelPointTime(row []string, metric config.PrometheusMetricConfig, unit time.Duration, extractedAt time.Time) int64 {
+	if metric.Timestamp >= 0 && metric.Timestamp < len(row) {
+		value := strings.TrimSpace(row[metric.Timestamp])
+		// Parse integers exactly; epoch nanoseconds exceed float64 precision
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n * int64(unit)
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return int64(f * float64(unit))
+		}
+	}
+	return extractedAt.UnixNano()
+}
+
+// otelSpanFields maps span properties to CSV columns or flattened keys
+type otelSpanFields struct {
+	traceID      string
+	spanID       string
+	parentSpanID string
+	name         string
+	startTime    string
+	endTime      string
+}
+
+// parseOTELSpanFields parses the span_fields mapping required by signal: traces
+func parseOTELSpanFields(raw interface{}) (*otelSpanFields, error) {
+	fieldsMap, ok := safeMapStringInterface(raw)
+	if !ok {
+		return nil, fmt.Errorf("otel stream with signal 'traces' requires a 'span_fields' object")
+	}
+
+	fields := &otelSpanFields{}
+	fields.traceID, _ = safeString(fieldsMap["trace_id"])
+	fields.spanID, _ = safeString(fieldsMap["span_id"])
+	fields.parentSpanID, _ = safeString(fieldsMap["parent_span_id"])
+	fields.name, _ = safeString(fieldsMap["name"])
+	fields.startTime, _ = safeString(fieldsMap["start_time"])
+	fields.endTime, _ = safeString(fieldsMap["end_time"])
+
+	if fields.traceID == "" || fields.spanID == "" || fields.name == "" || fields.startTime == "" {
+		return nil, fmt.Errorf("span_fields requires trace_id, span_id, name and start_time")
+	}
+
+	return fields, nil
 }
 
 // NewOTELStream creates a new OTEL stream
@@ -551,6 +1528,33 @@ func NewOTELStream(config map[string]interface{}, labels map[string]string, inse
 		}
 	}
 
+	signal := otelSignalMetrics
+	if s, ok := safeString(config["signal"]); ok && s != "" {
+		signal = s
+	}
+
+	var spanFields *otelSpanFields
+	switch signal {
+	case otelSignalMetrics:
+	case otelSignalTraces:
+		fields, err := parseOTELSpanFields(config["span_fields"])
+		if err != nil {
+			return nil, err
+		}
+		spanFields = fields
+	default:
+		return nil, fmt.Errorf("unsupported otel signal: %s (must be %s or %s)", signal, otelSignalMetrics, otelSignalTraces)
+	}
+
+	timeUnit := time.Millisecond
+	if u, ok := safeString(config["timestamp_unit"]); ok && u != "" {
+		parsed, exists := otelTimestampUnits[u]
+		if !exists {
+			return nil, fmt.Errorf("unsupported otel timestamp_unit: %s (must be s, ms, us or ns)", u)
+		}
+		timeUnit = parsed
+	}
+
 	// Configure HTTP client with TLS settings
 	transport := &http.Transport{}
 	if insecureTLS {
@@ -559,9 +1563,25 @@ func NewOTELStream(config map[string]interface{}, labels map[string]string, inse
 		}
 	}
 
+	retry, err := parseRetryPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("otel stream: %w", err)
+	}
+
+	signer, err := parseRequestSigner(config)
+	if err != nil {
+		return nil, fmt.Errorf("otel stream: %w", err)
+	}
+
 	return &OTELStream{
-		endpoint: endpoint,
-		labels:   labels,
+		endpoint:   endpoint,
+		labels:     labels,
+		retry:      retry,
+		signal:     signal,
+		spanFields: spanFields,
+		metrics:    metrics,
+		timeUnit:   timeUnit,
+		signer:     signer,
 		httpClient: &http.Client{
 			Timeout:   timeout,
 			Transport: transport,
@@ -572,7 +1592,12 @@ func NewOTELStream(config map[string]interface{}, labels map[string]string, inse
 // Load loads data to OTEL collector
 func (o *OTELStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
 	// Convert results to OTEL format
-	otelData := o.convertToOTELFormat(results)
+	var otelData map[string]interface{}
+	if o.signal == otelSignalTraces {
+		otelData = o.convertToOTELTraces(results)
+	} else {
+		otelData = o.convertToOTELFormat(results)
+	}
 
 	jsonData, err := json.Marshal(otelData)
 	if err != nil {
@@ -586,8 +1611,11 @@ func (o *OTELStream) Load(ctx context.Context, results []*transform.TransformedR
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if o.signer != nil {
+		o.signer.sign(req, jsonData)
+	}
 
-	resp, err := o.httpClient.Do(req)
+	resp, err := o.retry.do(ctx, o.httpClient, req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -615,13 +1643,26 @@ func (o *OTELStream) convertToOTELFormat(results []*transform.TransformedResult)
 			attributes["cluster"] = clusterName
 		}
 
+		// Add captured response headers
+		for _, pair := range capturedHeaderLabels(result.Metadata) {
+			attributes[pair.name] = pair.value
+		}
+
 		// Add configured labels as attributes
 		for labelKey, labelValue := range o.labels {
 			attributes[labelKey] = labelValue
 		}
 
+		// With configured metrics, each CSV row becomes a data point stamped with its own time
+		if len(result.CSVData) > 0 && len(o.metrics) > 0 {
+			for _, metricConfig := range o.metrics {
+				metrics = append(metrics, o.convertCSVMetric(result, metricConfig, attributes))
+			}
+			continue
+		}
+
 		metric := map[string]interface{}{
-			"name":        "elasticetl_metric",
+			"name":        prefixMetricName(o.metricPrefix, "elasticetl_metric"),
 			"description": "Metric from ElasticETL",
 			"unit":        "1",
 			"data": map[string]interface{}{
@@ -662,11 +1703,221 @@ func (o *OTELStream) convertToOTELFormat(results []*transform.TransformedResult)
 	}
 }
 
+// convertCSVMetric builds an OTEL metric with one data point per CSV row that has a numeric value
+func (o *OTELStream) convertCSVMetric(result *transform.TransformedResult, metricConfig config.PrometheusMetricConfig, attributes map[string]interface{}) map[string]interface{} {
+	dataPoints := []map[string]interface{}{}
+
+	for _, row := range result.CSVData {
+		if metricConfig.Value < 0 || metricConfig.Value >= len(row) {
+			continue
+		}
+		value, err := strconv.ParseFloat(row[metricConfig.Value], 64)
+		if err != nil {
+			continue
+		}
+
+		pointAttributes := make(map[string]interface{}, len(attributes)+len(metricConfig.Labels))
+		for key, attr := range attributes {
+			pointAttributes[key] = attr
+		}
+		for _, label := range metricConfig.Labels {
+			if label.StaticValue != "" {
+				pointAttributes[label.LabelName] = label.StaticValue
+			} else if label.IndexInCSVData >= 0 && label.IndexInCSVData < len(row) {
+				pointAttributes[label.LabelName] = row[label.IndexInCSVData]
+			}
+		}
+
+		dataPoints = append(dataPoints, map[string]interface{}{
+			"attributes":   pointAttributes,
+			"timeUnixNano": otelPointTime(row, metricConfig, o.timeUnit, result.Timestamp),
+			"value":        value,
+		})
+	}
+
+	return map[string]interface{}{
+		"name":        prefixMetricName(o.metricPrefix, metricConfig.Name),
+		"description": "Metric from ElasticETL",
+		"unit":        "1",
+		"data": map[string]interface{}{
+			"dataPoints": dataPoints,
+		},
+	}
+}
+
+// convertToOTELTraces converts results to an OTLP traces payload. Each CSV row (or each
+// result's transformed data when no CSV data is present) becomes one span; fields not mapped
+// to a span property are carried as span attributes
+func (o *OTELStream) convertToOTELTraces(results []*transform.TransformedResult) map[string]interface{} {
+	spans := []map[string]interface{}{}
+
+	for _, result := range results {
+		if len(result.CSVData) > 0 {
+			for _, row := range result.CSVData {
+				fields := make(map[string]string, len(result.CSVHeaders))
+				for i, header := range result.CSVHeaders {
+					if i < len(row) {
+						fields[header] = row[i]
+					}
+				}
+				if span, ok := o.buildSpan(fields, result); ok {
+					spans = append(spans, span)
+				}
+			}
+			continue
+		}
+
+		fields := make(map[string]string, len(result.TransformedData))
+		for key, value := range result.TransformedData {
+			fields[key] = fmt.Sprintf("%v", value)
+		}
+		if span, ok := o.buildSpan(fields, result); ok {
+			spans = append(spans, span)
+		}
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{
+			{
+				"resource": map[string]interface{}{
+					"attributes": []map[string]interface{}{
+						{
+							"key":   "service.name",
+							"value": map[string]string{"stringValue": "elasticetl"},
+						},
+					},
+				},
+				"scopeSpans": []map[string]interface{}{
+					{
+						"scope": map[string]interface{}{
+							"name":    "elasticetl",
+							"version": "1.0.0",
+						},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+// buildSpan builds a single OTLP span from a row of fields, returning false if the row lacks
+// a trace id, span id or valid start time
+func (o *OTELStream) buildSpan(fields map[string]string, result *transform.TransformedResult) (map[string]interface{}, bool) {
+	traceID := fields[o.spanFields.traceID]
+	spanID := fields[o.spanFields.spanID]
+	if traceID == "" || spanID == "" {
+		return nil, false
+	}
+
+	startTime, err := parseSpanTime(fields[o.spanFields.startTime])
+	if err != nil {
+		return nil, false
+	}
+	endTime := startTime
+	if o.spanFields.endTime != "" {
+		if parsed, err := parseSpanTime(fields[o.spanFields.endTime]); err == nil {
+			endTime = parsed
+		}
+	}
+
+	mapped := map[string]bool{
+		o.spanFields.traceID:      true,
+		o.spanFields.spanID:       true,
+		o.spanFields.parentSpanID: true,
+		o.spanFields.name:         true,
+		o.spanFields.startTime:    true,
+		o.spanFields.endTime:      true,
+	}
+
+	// Collect attributes in a stable order: source, cluster, configured labels, then unmapped fields
+	attributes := []map[string]interface{}{otelStringAttribute("source", result.Source)}
+	if clusterName, ok := safeString(result.Metadata["cluster_name"]); ok && clusterName != "" {
+		attributes = append(attributes, otelStringAttribute("cluster", clusterName))
+	}
+	for _, pair := range capturedHeaderLabels(result.Metadata) {
+		attributes = append(attributes, otelStringAttribute(pair.name, pair.value))
+	}
+
+	labelKeys := make([]string, 0, len(o.labels))
+	for key := range o.labels {
+		labelKeys = append(labelKeys, key)
+	}
+	sort.Strings(labelKeys)
+	for _, key := range labelKeys {
+		attributes = append(attributes, otelStringAttribute(key, o.labels[key]))
+	}
+
+	fieldKeys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !mapped[key] {
+			fieldKeys = append(fieldKeys, key)
+		}
+	}
+	sort.Strings(fieldKeys)
+	for _, key := range fieldKeys {
+		attributes = append(attributes, otelStringAttribute(key, fields[key]))
+	}
+
+	span := map[string]interface{}{
+		"traceId":           traceID,
+		"spanId":            spanID,
+		"name":              fields[o.spanFields.name],
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": strconv.FormatInt(startTime.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(endTime.UnixNano(), 10),
+		"attributes":        attributes,
+	}
+	if parentSpanID := fields[o.spanFields.parentSpanID]; o.spanFields.parentSpanID != "" && parentSpanID != "" {
+		span["parentSpanId"] = parentSpanID
+	}
+
+	return span, true
+}
+
+// otelStringAttribute builds an OTLP key/value attribute with a string value
+func otelStringAttribute(key, value string) map[string]interface{} {
+	return map[string]interface{}{
+		"key":   key,
+		"value": map[string]string{"stringValue": value},
+	}
+}
+
+// parseSpanTime parses a span timestamp given as epoch milliseconds (as stored by Elasticsearch)
+// or an RFC3339 string
+func parseSpanTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty timestamp")
+	}
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+	if millis, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Unix(0, int64(millis*float64(time.Millisecond))), nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
 // Close closes the OTEL stream
 func (o *OTELStream) Close() error {
 	return nil
 }
 
+// setMetricPrefix applies prefix to the emitted metric name
+func (o *OTELStream) setMetricPrefix(prefix string) {
+	o.metricPrefix = prefix
+}
+
+// setMaxConnsPerHost caps concurrent connections per host
+func (o *OTELStream) setMaxConnsPerHost(n int) {
+	limitConnsPerHost(o.httpClient, n)
+}
+
+// shareTransport uses the cached transport for the collector endpoint's host
+func (o *OTELStream) shareTransport(cache *transportCache) {
+	cache.share(o.httpClient, o.endpoint)
+}
+
 // GetType returns the stream type
 func (o *OTELStream) GetType() string {
 	return "otel"
@@ -689,10 +1940,13 @@ type MetricColumnConfig struct {
 type PrometheusStream struct {
 	endpoint      string
 	httpClient    *http.Client
+	retry         retryPolicy
 	labels        map[string]string
 	dynamicLabels []DynamicLabelConfig
 	metricColumns []MetricColumnConfig
 	basicAuth     string
+	metricPrefix  string
+	signer        *requestSigner // Set when hmac is configured
 }
 
 // NewPrometheusStream creates a new Prometheus stream
@@ -722,9 +1976,15 @@ func NewPrometheusStream(config map[string]interface{}, labels map[string]string
 		}
 	}
 
+	retry, err := parseRetryPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus stream: %w", err)
+	}
+
 	stream := &PrometheusStream{
 		endpoint: endpoint,
 		labels:   labels,
+		retry:    retry,
 		httpClient: &http.Client{
 			Timeout:   timeout,
 			Transport: transport,
@@ -777,6 +2037,11 @@ func NewPrometheusStream(config map[string]interface{}, labels map[string]string
 	}
 	stream.basicAuth = basicAuth
 
+	stream.signer, err = parseRequestSigner(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus stream: %w", err)
+	}
+
 	return stream, nil
 }
 
@@ -797,8 +2062,11 @@ func (p *PrometheusStream) Load(ctx context.Context, results []*transform.Transf
 	if p.basicAuth != "" {
 		req.Header.Set("Authorization", p.basicAuth)
 	}
+	if p.signer != nil {
+		p.signer.sign(req, []byte(metricsText))
+	}
 
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.retry.do(ctx, p.httpClient, req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -828,21 +2096,31 @@ func (p *PrometheusStream) convertToPrometheusFormat(results []*transform.Transf
 		for key, value := range result.TransformedData {
 			if numValue, ok := p.toFloat64(value); ok {
 				// Build labels string
-				labelPairs := []string{fmt.Sprintf(`source="%s"`, result.Source)}
+				name, fieldLabels := metricFamily(result.Metadata, key)
+				labelPairs := []string{formatLabelPair("source", result.Source)}
+				for _, pair := range fieldLabels {
+					labelPairs = append(labelPairs, formatLabelPair(pair.name, pair.value))
+				}
 
 				// Add cluster name from metadata if available
 				if clusterName, ok := safeString(result.Metadata["cluster_name"]); ok && clusterName != "" {
-					labelPairs = append(labelPairs, fmt.Sprintf(`cluster="%s"`, clusterName))
+					labelPairs = append(labelPairs, formatLabelPair("cluster", clusterName))
+				}
+
+				// Add captured response headers
+				for _, pair := range capturedHeaderLabels(result.Metadata) {
+					labelPairs = append(labelPairs, formatLabelPair(pair.name, pair.value))
 				}
 
 				// Add configured labels
 				for labelKey, labelValue := range p.labels {
-					labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, labelKey, labelValue))
+					labelPairs = append(labelPairs, formatLabelPair(labelKey, labelValue))
 				}
 
+				sortLabelPairs(labelPairs)
 				labelsStr := strings.Join(labelPairs, ",")
-				line := fmt.Sprintf(`%s{%s} %f %d`,
-					key, labelsStr, numValue, result.Timestamp.UnixMilli())
+				line := fmt.Sprintf(`%s{%s} %s %d`,
+					prefixMetricName(p.metricPrefix, name), labelsStr, formatSampleValue(numValue), result.Timestamp.UnixMilli())
 				lines = append(lines, line)
 			}
 		}
@@ -878,27 +2156,28 @@ func (p *PrometheusStream) createPrometheusLinesFromCSV(csvData [][]string, csvH
 			// Parse the metric value
 			if numValue, ok := p.parseFloat(row[columnIndex]); ok {
 				// Build labels string
-				labelPairs := []string{fmt.Sprintf(`source="%s"`, source)}
+				labelPairs := []string{formatLabelPair("source", source)}
 
 				// Add dynamic labels from CSV columns
 				for _, labelConfig := range p.dynamicLabels {
 					if labelConfig.StaticValue != "" {
-						labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, labelConfig.LabelName, labelConfig.StaticValue))
+						labelPairs = append(labelPairs, formatLabelPair(labelConfig.LabelName, labelConfig.StaticValue))
 					} else if labelConfig.CSVColumn != "" {
 						if labelColumnIndex, exists := headerMap[labelConfig.CSVColumn]; exists && labelColumnIndex < len(row) {
-							labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, labelConfig.LabelName, row[labelColumnIndex]))
+							labelPairs = append(labelPairs, formatLabelPair(labelConfig.LabelName, row[labelColumnIndex]))
 						}
 					}
 				}
 
 				// Add configured static labels
 				for labelKey, labelValue := range p.labels {
-					labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, labelKey, labelValue))
+					labelPairs = append(labelPairs, formatLabelPair(labelKey, labelValue))
 				}
 
+				sortLabelPairs(labelPairs)
 				labelsStr := strings.Join(labelPairs, ",")
-				line := fmt.Sprintf(`%s{%s} %f %d`,
-					metricConfig.MetricName, labelsStr, numValue, timestamp)
+				line := fmt.Sprintf(`%s{%s} %s %d`,
+					metricConfig.MetricName, labelsStr, formatSampleValue(numValue), timestamp)
 				lines = append(lines, line)
 			}
 		}
@@ -920,6 +2199,9 @@ func (p *PrometheusStream) toFloat64(value interface{}) (float64, bool) {
 	switch v := value.(type) {
 	case float64:
 		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	case int:
 		return float64(v), true
 	case int64:
@@ -934,6 +2216,24 @@ func (p *PrometheusStream) Close() error {
 	return nil
 }
 
+// setMetricPrefix applies prefix to the metric column and fallback metric names
+func (p *PrometheusStream) setMetricPrefix(prefix string) {
+	p.metricPrefix = prefix
+	for i := range p.metricColumns {
+		p.metricColumns[i].MetricName = prefixMetricName(prefix, p.metricColumns[i].MetricName)
+	}
+}
+
+// setMaxConnsPerHost caps concurrent connections per host
+func (p *PrometheusStream) setMaxConnsPerHost(n int) {
+	limitConnsPerHost(p.httpClient, n)
+}
+
+// shareTransport uses the cached transport for the Prometheus endpoint's host
+func (p *PrometheusStream) shareTransport(cache *transportCache) {
+	cache.share(p.httpClient, p.endpoint)
+}
+
 // GetType returns the stream type
 func (p *PrometheusStream) GetType() string {
 	return "prometheus"
@@ -941,9 +2241,13 @@ func (p *PrometheusStream) GetType() string {
 
 // DebugStream handles loading to debug files
 type DebugStream struct {
-	path    string
-	format  string // "json", "prometheus", "otel"
-	metrics []config.PrometheusMetricConfig
+	path         string
+	format       string // "json", "prometheus", "otel"
+	pretty       bool   // Indent JSON output (default true)
+	compress     bool   // Gzip the written file, adding a .gz extension
+	metrics      []config.PrometheusMetricConfig
+	metricPrefix string
+	logger       *logging.Logger
 }
 
 // NewDebugStream creates a new debug stream
@@ -952,16 +2256,25 @@ func NewDebugStream(config map[string]interface{}, metrics []config.PrometheusMe
 	if !ok {
 		return nil, fmt.Errorf("debug stream requires 'path' configuration")
 	}
+	path = substituteEnvVars(path)
 
 	format := "json" // default format
 	if f, ok := safeString(config["format"]); ok {
 		format = f
 	}
 
+	pretty := true
+	if p, ok := utils.SafeBool(config["pretty"]); ok {
+		pretty = p
+	}
+	compress, _ := utils.SafeBool(config["compress"])
+
 	return &DebugStream{
-		path:    path,
-		format:  format,
-		metrics: metrics,
+		path:     path,
+		format:   format,
+		pretty:   pretty,
+		compress: compress,
+		metrics:  metrics,
 	}, nil
 }
 
@@ -996,6 +2309,14 @@ func (d *DebugStream) Load(ctx context.Context, results []*transform.Transformed
 	// Generate filename with timestamp
 	timestamp := time.Now().Format("20060102_150405")
 	filename := fmt.Sprintf("%s_load_%s.%s", filepath.Base(d.path), timestamp, fileExtension)
+
+	if d.compress {
+		outputData, err = gzipBytes(outputData)
+		if err != nil {
+			return fmt.Errorf("failed to compress debug output: %w", err)
+		}
+		filename += ".gz"
+	}
 	fullPath := filepath.Join(debugDir, filename)
 
 	// Write to file
@@ -1003,7 +2324,7 @@ func (d *DebugStream) Load(ctx context.Context, results []*transform.Transformed
 		return fmt.Errorf("failed to write debug file: %w", err)
 	}
 
-	fmt.Printf("Debug load output (%s format) written to: %s\n", d.format, fullPath)
+	d.logger.Printf("Debug load output (%s format) written to: %s", d.format, fullPath)
 	return nil
 }
 
@@ -1017,10 +2338,27 @@ func (d *DebugStream) generateJSONFormat(results []*transform.TransformedResult)
 		"results":       results,
 	}
 
+	if !d.pretty {
+		jsonData, err := json.Marshal(debugData)
+		return jsonData, "json", err
+	}
 	jsonData, err := json.MarshalIndent(debugData, "", "  ")
 	return jsonData, "json", err
 }
 
+// gzipBytes returns data gzip-compressed
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // generatePrometheusFormat generates Prometheus timeseries format using CSV data
 func (d *DebugStream) generatePrometheusFormat(results []*transform.TransformedResult) ([]byte, string, error) {
 	var lines []string
@@ -1046,7 +2384,7 @@ func (d *DebugStream) generatePrometheusFormat(results []*transform.TransformedR
 
 		// Generate time series for each metric using loader's metrics configuration
 		for _, metric := range d.metrics {
-			timeSeries := d.createTimeSeriesForMetric(result.CSVData, metric)
+			timeSeries := d.createTimeSeriesForMetric(result.CSVData, metric, result.Timestamp)
 			for _, ts := range timeSeries {
 				lines = append(lines, ts)
 			}
@@ -1066,7 +2404,7 @@ func (d *DebugStream) parseMetricsConfig(result *transform.TransformedResult) []
 		if metricsList, ok := metricsRaw.([]interface{}); ok {
 			for _, metricRaw := range metricsList {
 				if metricMap, ok := metricRaw.(map[string]interface{}); ok {
-					var metric config.PrometheusMetricConfig
+					metric := config.PrometheusMetricConfig{Timestamp: config.NoTimestampColumn}
 
 					if name, ok := metricMap["name"].(string); ok {
 						metric.Name = name
@@ -1120,7 +2458,7 @@ func (d *DebugStream) parseMetricsConfig(result *transform.TransformedResult) []
 }
 
 // createTimeSeriesForMetric creates time series for a specific metric
-func (d *DebugStream) createTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig) []string {
+func (d *DebugStream) createTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig, extractedAt time.Time) []string {
 	var lines []string
 
 	// Group CSV rows by unique field combinations
@@ -1128,7 +2466,7 @@ func (d *DebugStream) createTimeSeriesForMetric(csvData [][]string, metric confi
 
 	for _, row := range csvData {
 		// Check bounds for required columns
-		if metric.Value >= len(row) || metric.Timestamp >= len(row) {
+		if metric.Value >= len(row) {
 			continue // Skip rows that don't have required columns
 		}
 
@@ -1147,7 +2485,7 @@ func (d *DebugStream) createTimeSeriesForMetric(csvData [][]string, metric confi
 			continue
 		}
 
-		timestamp, ok := d.parseInt64(row[metric.Timestamp])
+		timestamp, ok := rowTimestamp(row, metric, extractedAt)
 		if !ok {
 			continue
 		}
@@ -1173,17 +2511,18 @@ func (d *DebugStream) createTimeSeriesForMetric(csvData [][]string, metric confi
 		row := firstSample["row"].([]string)
 
 		var labelPairs []string
-		labelPairs = append(labelPairs, fmt.Sprintf(`__name__="%s"`, metric.Name))
+		labelPairs = append(labelPairs, formatLabelPair("__name__", metric.Name))
 
 		// Add dynamic labels with bounds checking
 		for _, label := range metric.Labels {
 			if label.StaticValue != "" {
-				labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, label.LabelName, label.StaticValue))
+				labelPairs = append(labelPairs, formatLabelPair(label.LabelName, label.StaticValue))
 			} else if label.IndexInCSVData >= 0 && label.IndexInCSVData < len(row) {
-				labelPairs = append(labelPairs, fmt.Sprintf(`%s="%s"`, label.LabelName, row[label.IndexInCSVData]))
+				labelPairs = append(labelPairs, formatLabelPair(label.LabelName, row[label.IndexInCSVData]))
 			}
 		}
 
+		sortLabelPairs(labelPairs)
 		labelsStr := strings.Join(labelPairs, ", ")
 
 		// Generate timeseries block
@@ -1209,16 +2548,26 @@ func (d *DebugStream) generateFallbackPrometheusFormat(result *transform.Transfo
 	for key, value := range result.TransformedData {
 		if numValue, ok := d.toFloat64(value); ok {
 			// Build labels string
-			labelPairs := []string{fmt.Sprintf(`source="%s"`, result.Source)}
+			name, fieldLabels := metricFamily(result.Metadata, key)
+			labelPairs := []string{formatLabelPair("source", result.Source)}
+			for _, pair := range fieldLabels {
+				labelPairs = append(labelPairs, formatLabelPair(pair.name, pair.value))
+			}
 
 			// Add cluster name from metadata if available
 			if clusterName, ok := safeString(result.Metadata["cluster_name"]); ok && clusterName != "" {
-				labelPairs = append(labelPairs, fmt.Sprintf(`cluster="%s"`, clusterName))
+				labelPairs = append(labelPairs, formatLabelPair("cluster", clusterName))
 			}
 
+			// Add captured response headers
+			for _, pair := range capturedHeaderLabels(result.Metadata) {
+				labelPairs = append(labelPairs, formatLabelPair(pair.name, pair.value))
+			}
+
+			sortLabelPairs(labelPairs)
 			labelsStr := strings.Join(labelPairs, ",")
-			line := fmt.Sprintf(`%s{%s} %f %d`,
-				key, labelsStr, numValue, result.Timestamp.UnixMilli())
+			line := fmt.Sprintf(`%s{%s} %s %d`,
+				prefixMetricName(d.metricPrefix, name), labelsStr, formatSampleValue(numValue), result.Timestamp.UnixMilli())
 			*lines = append(*lines, line)
 		}
 	}
@@ -1255,8 +2604,13 @@ func (d *DebugStream) generateOTELFormat(results []*transform.TransformedResult)
 			attributes["cluster"] = clusterName
 		}
 
+		// Add captured response headers
+		for _, pair := range capturedHeaderLabels(result.Metadata) {
+			attributes[pair.name] = pair.value
+		}
+
 		metric := map[string]interface{}{
-			"name":        "elasticetl_metric",
+			"name":        prefixMetricName(d.metricPrefix, "elasticetl_metric"),
 			"description": "Metric from ElasticETL",
 			"unit":        "1",
 			"data": map[string]interface{}{
@@ -1308,6 +2662,9 @@ func (d *DebugStream) toFloat64(value interface{}) (float64, bool) {
 	switch v := value.(type) {
 	case float64:
 		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	case int:
 		return float64(v), true
 	case int64:
@@ -1322,6 +2679,17 @@ func (d *DebugStream) Close() error {
 	return nil
 }
 
+// setMetricPrefix applies prefix to the configured and fallback metric names
+func (d *DebugStream) setMetricPrefix(prefix string) {
+	d.metricPrefix = prefix
+	d.metrics = prefixMetricConfigs(prefix, d.metrics)
+}
+
+// setLogger sets the logger reporting written files
+func (d *DebugStream) setLogger(logger *logging.Logger) {
+	d.logger = logger
+}
+
 // GetType returns the stream type
 func (d *DebugStream) GetType() string {
 	return "debug"
@@ -1329,7 +2697,8 @@ func (d *DebugStream) GetType() string {
 
 // CSVStream handles loading to CSV files
 type CSVStream struct {
-	path string
+	path   string
+	logger *logging.Logger
 }
 
 // NewCSVStream creates a new CSV stream
@@ -1390,7 +2759,7 @@ func (c *CSVStream) Load(ctx context.Context, results []*transform.TransformedRe
 		}
 	}
 
-	fmt.Printf("CSV output written to: %s\n", fullPath)
+	c.logger.Printf("CSV output written to: %s", fullPath)
 	return nil
 }
 
@@ -1399,18 +2768,117 @@ func (c *CSVStream) Close() error {
 	return nil
 }
 
+// setLogger sets the logger reporting written files
+func (c *CSVStream) setLogger(logger *logging.Logger) {
+	c.logger = logger
+}
+
 // GetType returns the stream type
 func (c *CSVStream) GetType() string {
 	return "csv"
 }
 
+// StdoutStream writes each batch to standard output for debugging and shell piping
+type StdoutStream struct {
+	format    string // "json" (default), "csv", "prometheus"
+	writer    *bufio.Writer
+	formatter *DebugStream // Shares the debug stream's json/prometheus rendering
+	mutex     sync.Mutex
+}
+
+// NewStdoutStream creates a new stdout stream
+func NewStdoutStream(config map[string]interface{}, metrics []config.PrometheusMetricConfig) (*StdoutStream, error) {
+	format := "json"
+	if f, ok := safeString(config["format"]); ok && f != "" {
+		format = f
+	}
+
+	switch format {
+	case "json", "csv", "prometheus":
+	default:
+		return nil, fmt.Errorf("unsupported stdout format: %s (must be json, csv or prometheus)", format)
+	}
+
+	return &StdoutStream{
+		format:    format,
+		writer:    bufio.NewWriter(os.Stdout),
+		formatter: &DebugStream{format: format, metrics: metrics},
+	}, nil
+}
+
+// Load writes the batch to stdout and flushes it
+func (s *StdoutStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch s.format {
+	case "csv":
+		if err := writeCSVResults(s.writer, results); err != nil {
+			return err
+		}
+
+	case "prometheus":
+		output, _, err := s.formatter.generatePrometheusFormat(results)
+		if err != nil {
+			return fmt.Errorf("failed to generate prometheus output: %w", err)
+		}
+		if _, err := s.writer.Write(output); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+
+	default:
+		output, _, err := s.formatter.generateJSONFormat(results)
+		if err != nil {
+			return fmt.Errorf("failed to generate json output: %w", err)
+		}
+		if _, err := s.writer.Write(append(output, '\n')); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+	}
+
+	return s.writer.Flush()
+}
+
+// setMetricPrefix applies prefix to metric names in prometheus output
+func (s *StdoutStream) setMetricPrefix(prefix string) {
+	s.formatter.setMetricPrefix(prefix)
+}
+
+// Close flushes any buffered output
+func (s *StdoutStream) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writer.Flush()
+}
+
+// GetType returns the stream type
+func (s *StdoutStream) GetType() string {
+	return "stdout"
+}
+
+// Remote write protocol versions supported by PrometheusRemoteWriteStream
+const (
+	remoteWriteVersion1 = "1.0"
+	remoteWriteVersion2 = "2.0"
+)
+
 // PrometheusRemoteWriteStream handles loading to Prometheus using remote write protocol
 type PrometheusRemoteWriteStream struct {
-	endpoint   string
-	httpClient *http.Client
-	labels     map[string]string
-	metrics    []config.PrometheusMetricConfig
-	basicAuth  string
+	endpoint           string
+	httpClient         *http.Client
+	retry              retryPolicy
+	labels             map[string]string
+	metrics            []config.PrometheusMetricConfig
+	basicAuth          string
+	remoteWriteVersion string // "1.0" (default) or "2.0"
+	metricPrefix       string
+	changes            *changeCache   // Set when only_changed is enabled
+	signer             *requestSigner // Set when hmac is configured
+	maxSamples         int            // Samples per write request; 0 sends everything in one request
 }
 
 // NewPrometheusRemoteWriteStream creates a new Prometheus remote write stream
@@ -1439,10 +2907,37 @@ func NewPrometheusRemoteWriteStream(config map[string]interface{}, labels map[st
 		}
 	}
 
+	remoteWriteVersion := remoteWriteVersion1
+	if v, ok := safeString(config["remote_write_version"]); ok {
+		if v != remoteWriteVersion1 && v != remoteWriteVersion2 {
+			return nil, fmt.Errorf("unsupported remote_write_version %q (expected %q or %q)", v, remoteWriteVersion1, remoteWriteVersion2)
+		}
+		remoteWriteVersion = v
+	}
+
+	retry, err := parseRetryPolicy(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus remote write stream: %w", err)
+	}
+
+	changes, err := newChangeCache(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus remote write stream: %w", err)
+	}
+
+	maxSamples, err := parseMaxSamplesPerRequest(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus remote write stream: %w", err)
+	}
+
 	stream := &PrometheusRemoteWriteStream{
-		endpoint: endpoint,
-		labels:   labels,
-		metrics:  metrics,
+		endpoint:           endpoint,
+		labels:             labels,
+		retry:              retry,
+		metrics:            metrics,
+		remoteWriteVersion: remoteWriteVersion,
+		changes:            changes,
+		maxSamples:         maxSamples,
 		httpClient: &http.Client{
 			Timeout:   timeout,
 			Transport: transport,
@@ -1456,6 +2951,11 @@ func NewPrometheusRemoteWriteStream(config map[string]interface{}, labels map[st
 	}
 	stream.basicAuth = basicAuth
 
+	stream.signer, err = parseRequestSigner(config)
+	if err != nil {
+		return nil, fmt.Errorf("prometheus remote write stream: %w", err)
+	}
+
 	return stream, nil
 }
 
@@ -1467,18 +2967,45 @@ func (p *PrometheusRemoteWriteStream) Load(ctx context.Context, results []*trans
 
 	// Convert results to Prometheus remote write format
 	timeSeries := p.convertToPrometheusTimeSeries(results)
+
+	// Drop series whose latest value has not changed since the last send
+	pending := make(map[string]changeEntry)
+	if p.changes != nil {
+		timeSeries = p.filterChanged(timeSeries, pending)
+	}
 	if len(timeSeries) == 0 {
 		return nil
 	}
 
-	// Create WriteRequest
-	writeRequest := &prompb.WriteRequest{}
-	for _, ts := range timeSeries {
-		writeRequest.Timeseries = append(writeRequest.Timeseries, *ts)
+	// Send in requests of at most max_samples_per_request samples
+	if err := sendBatches(chunkTimeSeries(timeSeries, p.maxSamples), func(batch []*prompb.TimeSeries) error {
+		return p.send(ctx, batch)
+	}); err != nil {
+		return err
+	}
+
+	if p.changes != nil {
+		p.changes.commit(pending)
 	}
 
-	// Marshal to protobuf
-	data, err := writeRequest.Marshal()
+	return nil
+}
+
+// send posts time series as one snappy-compressed write request in the configured protocol
+// version
+func (p *PrometheusRemoteWriteStream) send(ctx context.Context, timeSeries []*prompb.TimeSeries) error {
+	// Marshal to protobuf in the configured protocol version
+	var data []byte
+	var err error
+	contentType := "application/x-protobuf"
+	versionHeader := "0.1.0"
+	if p.remoteWriteVersion == remoteWriteVersion2 {
+		data, err = encodeWriteRequestV2(timeSeries)
+		contentType = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+		versionHeader = "2.0.0"
+	} else {
+		data, err = encodeWriteRequestV1(timeSeries)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to marshal write request: %w", err)
 	}
@@ -1493,17 +3020,20 @@ func (p *PrometheusRemoteWriteStream) Load(ctx context.Context, results []*trans
 	}
 
 	// Set headers
-	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Content-Encoding", "snappy")
-	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", versionHeader)
 
 	// Add basic auth header if configured
 	if p.basicAuth != "" {
 		req.Header.Set("Authorization", p.basicAuth)
 	}
+	if p.signer != nil {
+		p.signer.sign(req, compressed)
+	}
 
 	// Send request
-	resp, err := p.httpClient.Do(req)
+	resp, err := p.retry.do(ctx, p.httpClient, req)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -1512,10 +3042,87 @@ func (p *PrometheusRemoteWriteStream) Load(ctx context.Context, results []*trans
 	if resp.StatusCode >= 400 {
 		return fmt.Errorf("Prometheus remote write returned status %d", resp.StatusCode)
 	}
-
 	return nil
 }
 
+// filterChanged drops time series whose latest sample has the value last sent for the
+// series. A changed series is sent whole, so earlier samples repeating a value are kept
+func (p *PrometheusRemoteWriteStream) filterChanged(timeSeries []*prompb.TimeSeries, pending map[string]changeEntry) []*prompb.TimeSeries {
+	now := time.Now()
+	var filtered []*prompb.TimeSeries
+	for _, ts := range timeSeries {
+		if len(ts.Samples) == 0 {
+			filtered = append(filtered, ts)
+			continue
+		}
+
+		labels := make(map[string]string, len(ts.Labels))
+		for _, label := range ts.Labels {
+			labels[label.Name] = label.Value
+		}
+
+		// The latest sample is the one with the highest timestamp, the last of equals
+		latest := ts.Samples[0]
+		for _, sample := range ts.Samples[1:] {
+			if sample.Timestamp >= latest.Timestamp {
+				latest = sample
+			}
+		}
+
+		if !p.changes.changed(seriesKey(labels), latest.Value, now, pending) {
+			continue
+		}
+		filtered = append(filtered, ts)
+	}
+	return filtered
+}
+
+// encodeWriteRequestV1 marshals time series as a remote write 1.0 prometheus.WriteRequest
+func encodeWriteRequestV1(timeSeries []*prompb.TimeSeries) ([]byte, error) {
+	writeRequest := &prompb.WriteRequest{}
+	for _, ts := range timeSeries {
+		writeRequest.Timeseries = append(writeRequest.Timeseries, *ts)
+	}
+	return writeRequest.Marshal()
+}
+
+// encodeWriteRequestV2 marshals time series as a remote write 2.0 io.prometheus.write.v2.Request,
+// interning label names and values in the request's symbols table
+func encodeWriteRequestV2(timeSeries []*prompb.TimeSeries) ([]byte, error) {
+	symbols := writev2.NewSymbolTable()
+	writeRequest := &writev2.Request{}
+
+	for _, ts := range timeSeries {
+		// Remote write 2.0 requires labels sorted by name and samples sorted by timestamp
+		labels := make([]prompb.Label, len(ts.Labels))
+		copy(labels, ts.Labels)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		labelRefs := make([]uint32, 0, len(labels)*2)
+		for _, label := range labels {
+			labelRefs = append(labelRefs, symbols.Symbolize(label.Name), symbols.Symbolize(label.Value))
+		}
+
+		samples := make([]writev2.Sample, 0, len(ts.Samples))
+		for _, sample := range ts.Samples {
+			samples = append(samples, writev2.Sample{Value: sample.Value, Timestamp: sample.Timestamp})
+		}
+		sort.Slice(samples, func(i, j int) bool {
+			return samples[i].Timestamp < samples[j].Timestamp
+		})
+
+		writeRequest.Timeseries = append(writeRequest.Timeseries, writev2.TimeSeries{
+			LabelsRefs: labelRefs,
+			Samples:    samples,
+		})
+	}
+
+	writeRequest.Symbols = symbols.Symbols()
+	return writeRequest.Marshal()
+}
+
 // convertToPrometheusTimeSeries converts transformed results to Prometheus time series using CSV data
 func (p *PrometheusRemoteWriteStream) convertToPrometheusTimeSeries(results []*transform.TransformedResult) []*prompb.TimeSeries {
 	var timeSeries []*prompb.TimeSeries
@@ -1525,7 +3132,7 @@ func (p *PrometheusRemoteWriteStream) convertToPrometheusTimeSeries(results []*t
 		if len(result.CSVData) > 0 && len(p.metrics) > 0 {
 			// Generate time series for each metric using CSV data
 			for _, metric := range p.metrics {
-				metricTimeSeries := p.createTimeSeriesForMetric(result.CSVData, metric)
+				metricTimeSeries := p.createTimeSeriesForMetric(result.CSVData, metric, result.Timestamp, result.Metadata)
 				timeSeries = append(timeSeries, metricTimeSeries...)
 			}
 			continue
@@ -1537,21 +3144,31 @@ func (p *PrometheusRemoteWriteStream) convertToPrometheusTimeSeries(results []*t
 			// Only include numeric values as metrics
 			if numValue, ok := p.toFloat64(value); ok {
 				// Create labels
+				name, fieldLabels := metricFamily(result.Metadata, key)
 				var labels []prompb.Label
-				labels = append(labels, prompb.Label{Name: "__name__", Value: key})
+				labels = append(labels, prompb.Label{Name: "__name__", Value: prefixMetricName(p.metricPrefix, name)})
 				labels = append(labels, prompb.Label{Name: "source", Value: result.Source})
+				for _, pair := range fieldLabels {
+					labels = append(labels, prompb.Label{Name: pair.name, Value: pair.value})
+				}
 
 				// Add cluster name from metadata if available
 				if clusterName, ok := safeString(result.Metadata["cluster_name"]); ok && clusterName != "" {
 					labels = append(labels, prompb.Label{Name: "cluster", Value: clusterName})
 				}
 
+				// Add captured response headers
+				for _, pair := range capturedHeaderLabels(result.Metadata) {
+					labels = append(labels, prompb.Label{Name: pair.name, Value: pair.value})
+				}
+
 				// Add configured labels
 				for labelKey, labelValue := range p.labels {
 					labels = append(labels, prompb.Label{Name: labelKey, Value: labelValue})
 				}
 
 				// Create time series
+				sortLabels(labels)
 				ts := &prompb.TimeSeries{
 					Labels: labels,
 					Samples: []prompb.Sample{
@@ -1570,7 +3187,7 @@ func (p *PrometheusRemoteWriteStream) convertToPrometheusTimeSeries(results []*t
 }
 
 // createTimeSeriesForMetric creates Prometheus remote write time series for a specific metric using CSV data
-func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig) []*prompb.TimeSeries {
+func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]string, metric config.PrometheusMetricConfig, extractedAt time.Time, metadata map[string]interface{}) []*prompb.TimeSeries {
 	var timeSeries []*prompb.TimeSeries
 
 	// Group CSV rows by unique field combinations
@@ -1578,7 +3195,7 @@ func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]stri
 
 	for _, row := range csvData {
 		// Check bounds for required columns
-		if metric.Value >= len(row) || metric.Timestamp >= len(row) {
+		if metric.Value >= len(row) {
 			continue // Skip rows that don't have required columns
 		}
 
@@ -1597,7 +3214,7 @@ func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]stri
 			continue
 		}
 
-		timestamp, ok := p.parseInt64(row[metric.Timestamp])
+		timestamp, ok := rowTimestamp(row, metric, extractedAt)
 		if !ok {
 			continue
 		}
@@ -1634,6 +3251,11 @@ func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]stri
 			}
 		}
 
+		// Add captured response headers
+		for _, pair := range capturedHeaderLabels(metadata) {
+			labels = append(labels, prompb.Label{Name: pair.name, Value: pair.value})
+		}
+
 		// Add configured labels
 		for labelKey, labelValue := range p.labels {
 			labels = append(labels, prompb.Label{Name: labelKey, Value: labelValue})
@@ -1649,6 +3271,7 @@ func (p *PrometheusRemoteWriteStream) createTimeSeriesForMetric(csvData [][]stri
 		}
 
 		// Create time series
+		sortLabels(labels)
 		ts := &prompb.TimeSeries{
 			Labels:  labels,
 			Samples: samples,
@@ -1681,6 +3304,9 @@ func (p *PrometheusRemoteWriteStream) toFloat64(value interface{}) (float64, boo
 	switch v := value.(type) {
 	case float64:
 		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
 	case int:
 		return float64(v), true
 	case int64:
@@ -1695,6 +3321,22 @@ func (p *PrometheusRemoteWriteStream) Close() error {
 	return nil
 }
 
+// setMetricPrefix applies prefix to the configured and fallback metric names
+func (p *PrometheusRemoteWriteStream) setMetricPrefix(prefix string) {
+	p.metricPrefix = prefix
+	p.metrics = prefixMetricConfigs(prefix, p.metrics)
+}
+
+// setMaxConnsPerHost caps concurrent connections per host
+func (p *PrometheusRemoteWriteStream) setMaxConnsPerHost(n int) {
+	limitConnsPerHost(p.httpClient, n)
+}
+
+// shareTransport uses the cached transport for the remote write endpoint's host
+func (p *PrometheusRemoteWriteStream) shareTransport(cache *transportCache) {
+	cache.share(p.httpClient, p.endpoint)
+}
+
 // GetType returns the stream type
 func (p *PrometheusRemoteWriteStream) GetType() string {
 	return "prometheus_remote_write"
@@ -0,0 +1,1104 @@
+package load
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+)
+
+// gemResults returns one result whose transformed data produces n samples
+func gemResults(n int) []*transform.TransformedResult {
+	data := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		data[string(rune('a'+i))] = float64(i)
+	}
+	return []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "http://es:9200", Timestamp: time.UnixMilli(1700000000000)},
+		TransformedData: data,
+	}}
+}
+
+// decodeWriteRequest decodes a remote write body sent with the given Content-Encoding
+func decodeWriteRequest(t *testing.T, body []byte, encoding string) *prompb.WriteRequest {
+	t.Helper()
+	if encoding == "snappy" {
+		decoded, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Fatalf("snappy decode: %v", err)
+		}
+		body = decoded
+	}
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(body); err != nil {
+		t.Fatalf("unmarshal write request: %v", err)
+	}
+	return &req
+}
+
+func TestGEMStreamEncoding(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression string
+		want        string
+	}{
+		{"default", "", "snappy"},
+		{"snappy", "snappy", "snappy"},
+		{"none", "none", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotEncoding, gotType string
+			var samples int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				gotEncoding = r.Header.Get("Content-Encoding")
+				gotType = r.Header.Get("Content-Type")
+				for _, ts := range decodeWriteRequest(t, body, gotEncoding).Timeseries {
+					samples += len(ts.Samples)
+				}
+			}))
+			defer server.Close()
+
+			cfg := map[string]interface{}{"endpoint": server.URL}
+			if tt.compression != "" {
+				cfg["compression"] = tt.compression
+			}
+			stream, err := NewGEMStream(cfg, nil, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Load(context.Background(), gemResults(3)); err != nil {
+				t.Fatal(err)
+			}
+
+			if gotEncoding != tt.want {
+				t.Errorf("Content-Encoding = %q, want %q", gotEncoding, tt.want)
+			}
+			if gotType != "application/x-protobuf" {
+				t.Errorf("Content-Type = %q, want application/x-protobuf", gotType)
+			}
+			if samples != 3 {
+				t.Errorf("decoded %d samples, want 3", samples)
+			}
+		})
+	}
+}
+
+func TestGEMStreamInvalidCompression(t *testing.T) {
+	if _, err := NewGEMStream(map[string]interface{}{"endpoint": "http://gem", "compression": "brotli"}, nil, false, nil); err == nil {
+		t.Fatal("expected an error for an unknown compression")
+	}
+}
+
+func TestGEMStreamEncodingFallback(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"415", http.StatusUnsupportedMediaType, ""},
+		{"400 mentioning encoding", http.StatusBadRequest, "unsupported content encoding"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var encodings []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				encoding := r.Header.Get("Content-Encoding")
+				mu.Lock()
+				encodings = append(encodings, encoding)
+				mu.Unlock()
+				if encoding != "" {
+					w.WriteHeader(tt.status)
+					io.WriteString(w, tt.body)
+					return
+				}
+				decodeWriteRequest(t, body, "")
+			}))
+			defer server.Close()
+
+			stream, err := NewGEMStream(map[string]interface{}{"endpoint": server.URL, "compression": "gzip"}, nil, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if err := stream.Load(context.Background(), gemResults(1)); err != nil {
+					t.Fatalf("load %d: %v", i, err)
+				}
+			}
+
+			// The first write falls back; the second goes straight out uncompressed
+			want := []string{"gzip", "", ""}
+			if len(encodings) != len(want) {
+				t.Fatalf("requests sent with encodings %q, want %q", encodings, want)
+			}
+			for i := range want {
+				if encodings[i] != want[i] {
+					t.Fatalf("requests sent with encodings %q, want %q", encodings, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGEMStreamUnrelatedBadRequest(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "out of order sample")
+	}))
+	defer server.Close()
+
+	stream, err := NewGEMStream(map[string]interface{}{"endpoint": server.URL}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Load(context.Background(), gemResults(1)); err == nil {
+		t.Fatal("expected the 400 to fail the load")
+	}
+	if requests != 1 {
+		t.Errorf("sent %d requests, want 1 without a fallback", requests)
+	}
+}
+
+// slowStream ignores its context and records whether Close ran while a load was writing
+type slowStream struct {
+	release     chan struct{}
+	mu          sync.Mutex
+	loading     bool
+	closedEarly bool
+}
+
+func (s *slowStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	s.mu.Lock()
+	s.loading = true
+	s.mu.Unlock()
+	<-s.release
+	s.mu.Lock()
+	s.loading = false
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *slowStream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closedEarly = s.loading
+	return nil
+}
+
+func (s *slowStream) GetType() string { return "slow" }
+
+func TestTimeoutStreamCloseWaitsForAbandonedLoad(t *testing.T) {
+	inner := &slowStream{release: make(chan struct{})}
+	stream := &timeoutStream{Stream: inner, timeout: 100 * time.Millisecond}
+
+	if err := stream.Load(context.Background(), nil); err == nil {
+		t.Fatal("expected the load to time out")
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the abandoned load was still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(inner.release)
+	<-closed
+	if inner.closedEarly {
+		t.Fatal("stream closed while a load was writing to it")
+	}
+}
+
+func TestTimeoutStreamCloseIsBounded(t *testing.T) {
+	inner := &slowStream{release: make(chan struct{})}
+	defer close(inner.release)
+	stream := &timeoutStream{Stream: inner, timeout: 20 * time.Millisecond}
+
+	if err := stream.Load(context.Background(), nil); err == nil {
+		t.Fatal("expected the load to time out")
+	}
+
+	// The abandoned load never returns; Close gives up after the stream timeout
+	closed := make(chan struct{})
+	go func() {
+		stream.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close hung on a load that ignores its context")
+	}
+}
+
+// fastStream records when its load finished
+type fastStream struct {
+	finished time.Time
+}
+
+func (f *fastStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	f.finished = time.Now()
+	return nil
+}
+
+func (f *fastStream) Close() error    { return nil }
+func (f *fastStream) GetType() string { return "fast" }
+
+func TestLoadSlowStreamDoesNotDelayOthers(t *testing.T) {
+	slow := &slowStream{release: make(chan struct{})}
+	defer close(slow.release)
+	fast := []*fastStream{{}, {}}
+	loader := &Loader{
+		transports: newTransportCache(),
+		config:     config.LoadConfig{FailurePolicy: config.FailurePolicyBestEffort},
+		streams: []Stream{
+			&timeoutStream{Stream: slow, timeout: 50 * time.Millisecond},
+			&timeoutStream{Stream: fast[0], timeout: 50 * time.Millisecond},
+			&timeoutStream{Stream: fast[1], timeout: 50 * time.Millisecond},
+		},
+	}
+
+	start := time.Now()
+	if err := loader.Load(context.Background(), gemResults(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch finishes at the slow stream's deadline, and the fast streams well inside theirs
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Load took %s with a 50ms stream timeout", elapsed)
+	}
+	for i, stream := range fast {
+		if stream.finished.IsZero() {
+			t.Fatalf("fast stream %d did not load", i)
+		}
+		if took := stream.finished.Sub(start); took >= 50*time.Millisecond {
+			t.Errorf("fast stream %d finished after %s, past its own deadline", i, took)
+		}
+	}
+}
+
+func TestRowTimestamp(t *testing.T) {
+	extractedAt := time.UnixMilli(5000)
+	tests := []struct {
+		name    string
+		row     []string
+		column  int
+		source  string
+		want    int64
+		wantUse bool
+	}{
+		{"column 0", []string{"1000", "x"}, 0, "", 1000, true},
+		{"column 0 with now", []string{"1000", "x"}, 0, "now", 1000, true},
+		{"no column with now", []string{"1000", "x"}, -1, "now", 5000, true},
+		{"no column", []string{"1000", "x"}, -1, "", 0, false},
+		{"non-numeric column with now", []string{"1000", "x"}, 1, "now", 5000, true},
+		{"non-numeric column", []string{"1000", "x"}, 1, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := config.PrometheusMetricConfig{Timestamp: tt.column, TimestampSource: tt.source}
+			got, ok := rowTimestamp(tt.row, metric, extractedAt)
+			if ok != tt.wantUse || got != tt.want {
+				t.Errorf("rowTimestamp = %d, %v; want %d, %v", got, ok, tt.want, tt.wantUse)
+			}
+		})
+	}
+}
+
+func TestWritesStdout(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []config.StreamConfig
+		want    bool
+	}{
+		{"no streams", nil, false},
+		{"stdout", []config.StreamConfig{{Type: "csv"}, {Type: "stdout"}}, true},
+		{"disabled stdout", []config.StreamConfig{{Type: "stdout", Config: map[string]interface{}{"enabled": false}}}, false},
+		{"other streams", []config.StreamConfig{{Type: "csv"}, {Type: "gem"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WritesStdout(config.LoadConfig{Streams: tt.streams}); got != tt.want {
+				t.Errorf("WritesStdout = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeWriteRequestV2RoundTrip(t *testing.T) {
+	timeSeries := []*prompb.TimeSeries{
+		{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "es_docs"}, {Name: "cluster", Value: "prod"}},
+			Samples: []prompb.Sample{{Value: 2, Timestamp: 2000}, {Value: 1, Timestamp: 1000}},
+		},
+		{
+			Labels:  []prompb.Label{{Name: "cluster", Value: "prod"}, {Name: "__name__", Value: "es_size"}},
+			Samples: []prompb.Sample{{Value: 3, Timestamp: 1000}},
+		},
+	}
+
+	data, err := encodeWriteRequestV2(timeSeries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req writev2.Request
+	if err := req.Unmarshal(data); err != nil {
+		t.Fatalf("unmarshal v2 request: %v", err)
+	}
+
+	// Shared strings are interned once
+	counts := make(map[string]int)
+	for _, symbol := range req.Symbols {
+		counts[symbol]++
+	}
+	if len(req.Symbols) == 0 || req.Symbols[0] != "" {
+		t.Fatalf("symbols = %q, want the empty string first", req.Symbols)
+	}
+	for _, symbol := range []string{"__name__", "cluster", "prod"} {
+		if counts[symbol] != 1 {
+			t.Errorf("symbol %q interned %d times, want once", symbol, counts[symbol])
+		}
+	}
+
+	want := []struct {
+		labels     string
+		timestamps []int64
+	}{
+		{`__name__="es_docs",cluster="prod"`, []int64{1000, 2000}},
+		{`__name__="es_size",cluster="prod"`, []int64{1000}},
+	}
+	if len(req.Timeseries) != len(want) {
+		t.Fatalf("decoded %d series, want %d", len(req.Timeseries), len(want))
+	}
+	for i, ts := range req.Timeseries {
+		var labels []string
+		for j := 0; j+1 < len(ts.LabelsRefs); j += 2 {
+			name, value := ts.LabelsRefs[j], ts.LabelsRefs[j+1]
+			if int(name) >= len(req.Symbols) || int(value) >= len(req.Symbols) {
+				t.Fatalf("series %d refers past the symbols table: %v", i, ts.LabelsRefs)
+			}
+			labels = append(labels, req.Symbols[name]+`="`+req.Symbols[value]+`"`)
+		}
+		if got := strings.Join(labels, ","); got != want[i].labels {
+			t.Errorf("series %d labels = %s, want %s", i, got, want[i].labels)
+		}
+		var timestamps []int64
+		for _, sample := range ts.Samples {
+			timestamps = append(timestamps, sample.Timestamp)
+		}
+		if !reflect.DeepEqual(timestamps, want[i].timestamps) {
+			t.Errorf("series %d timestamps = %v, want %v", i, timestamps, want[i].timestamps)
+		}
+	}
+}
+
+func TestPrometheusRemoteWriteV2Headers(t *testing.T) {
+	var contentType, version string
+	var series int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		version = r.Header.Get("X-Prometheus-Remote-Write-Version")
+		body, _ := io.ReadAll(r.Body)
+		decoded, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("snappy decode: %v", err)
+			return
+		}
+		var req writev2.Request
+		if err := req.Unmarshal(decoded); err != nil {
+			t.Errorf("unmarshal v2 request: %v", err)
+			return
+		}
+		series = len(req.Timeseries)
+	}))
+	defer server.Close()
+
+	stream, err := NewPrometheusRemoteWriteStream(map[string]interface{}{"endpoint": server.URL, "remote_write_version": "2.0"}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Load(context.Background(), gemResults(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "application/x-protobuf;proto=io.prometheus.write.v2.Request" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	if version != "2.0.0" {
+		t.Errorf("X-Prometheus-Remote-Write-Version = %q, want 2.0.0", version)
+	}
+	if series != 2 {
+		t.Errorf("decoded %d series, want 2", series)
+	}
+}
+
+func TestOTELStreamTraces(t *testing.T) {
+	var payload struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID           string `json:"traceId"`
+					SpanID            string `json:"spanId"`
+					ParentSpanID      string `json:"parentSpanId"`
+					Name              string `json:"name"`
+					StartTimeUnixNano string `json:"startTimeUnixNano"`
+					EndTimeUnixNano   string `json:"endTimeUnixNano"`
+					Attributes        []struct {
+						Key   string            `json:"key"`
+						Value map[string]string `json:"value"`
+					} `json:"attributes"`
+				} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("decode OTLP payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	stream, err := NewOTELStream(map[string]interface{}{
+		"endpoint": server.URL,
+		"signal":   "traces",
+		"span_fields": map[string]interface{}{
+			"trace_id":       "trace",
+			"span_id":        "span",
+			"parent_span_id": "parent",
+			"name":           "op",
+			"start_time":     "start",
+			"end_time":       "end",
+		},
+	}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []*transform.TransformedResult{{
+		Result:     &extract.Result{Source: "http://es:9200", Metadata: map[string]interface{}{"cluster_name": "apm"}},
+		CSVHeaders: []string{"trace", "span", "parent", "op", "start", "end", "service"},
+		CSVData: [][]string{
+			{"t1", "s1", "p1", "GET /", "1700000000000", "1700000000250", "web"},
+			{"t1", "", "", "no span id", "1700000000000", "", "web"},
+		},
+	}}
+	if err := stream.Load(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(payload.ResourceSpans) != 1 || len(payload.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload layout: %+v", payload)
+	}
+	spans := payload.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1 (rows without a span id are skipped)", len(spans))
+	}
+	span := spans[0]
+	if span.TraceID != "t1" || span.SpanID != "s1" || span.ParentSpanID != "p1" || span.Name != "GET /" {
+		t.Errorf("span ids and name = %+v", span)
+	}
+	if span.StartTimeUnixNano != "1700000000000000000" || span.EndTimeUnixNano != "1700000000250000000" {
+		t.Errorf("span times = %s..%s", span.StartTimeUnixNano, span.EndTimeUnixNano)
+	}
+	attributes := make(map[string]string)
+	for _, attribute := range span.Attributes {
+		attributes[attribute.Key] = attribute.Value["stringValue"]
+	}
+	want := map[string]string{"source": "http://es:9200", "cluster": "apm", "service": "web"}
+	if !reflect.DeepEqual(attributes, want) {
+		t.Errorf("attributes = %v, want %v", attributes, want)
+	}
+}
+
+func TestOTELStreamTracesRequiresSpanFields(t *testing.T) {
+	if _, err := NewOTELStream(map[string]interface{}{"endpoint": "http://otel", "signal": "traces"}, nil, false, nil); err == nil {
+		t.Fatal("expected an error without span_fields")
+	}
+}
+
+func TestCapturedHeadersLabelCSVMetrics(t *testing.T) {
+	metrics := []config.PrometheusMetricConfig{{
+		Name:              "doc_count",
+		UniqueFieldsIndex: []int{0},
+		Value:             1,
+		Timestamp:         2,
+		Labels:            []config.PrometheusLabelConfig{{LabelName: "host", IndexInCSVData: 0}},
+	}}
+	results := []*transform.TransformedResult{{
+		Result: &extract.Result{
+			Source:   "http://es:9200",
+			Metadata: map[string]interface{}{"headers": map[string]string{"X-Elastic-Product": "Elasticsearch"}},
+		},
+		CSVHeaders: []string{"host", "count", "ts"},
+		CSVData:    [][]string{{"web-1", "42", "1700000000000"}},
+	}}
+	want := map[string]string{"__name__": "doc_count", "host": "web-1", "x_elastic_product": "Elasticsearch"}
+
+	gem, err := NewGEMStream(map[string]interface{}{"endpoint": "http://gem"}, nil, false, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := gem.convertToPrometheusSamples(results)
+	if len(samples) != 1 {
+		t.Fatalf("gem: got %d series, want 1", len(samples))
+	}
+	if labels := samples[0]["labels"].([]map[string]string)[0]; !reflect.DeepEqual(labels, want) {
+		t.Errorf("gem labels = %v, want %v", labels, want)
+	}
+
+	prom, err := NewPrometheusRemoteWriteStream(map[string]interface{}{"endpoint": "http://prom"}, nil, false, metrics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := prom.convertToPrometheusTimeSeries(results)
+	if len(series) != 1 {
+		t.Fatalf("prometheus: got %d series, want 1", len(series))
+	}
+	labels := make(map[string]string)
+	for _, label := range series[0].Labels {
+		labels[label.Name] = label.Value
+	}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("prometheus labels = %v, want %v", labels, want)
+	}
+}
+
+func TestMetricFamily(t *testing.T) {
+	metadata := map[string]interface{}{"metric_families": map[string]string{
+		"cpu":          "node_cpu",
+		"cpu.per_core": "node_core_cpu",
+		"memory":       "node_memory",
+	}}
+
+	name, labels := metricFamily(metadata, "cpu.user")
+	if name != "node_cpu" || !reflect.DeepEqual(labels, []labelPair{{name: "field", value: "user"}}) {
+		t.Errorf("cpu.user = %s %v, want node_cpu with field user", name, labels)
+	}
+
+	// The longest matching prefix wins, and array indices stay in the field
+	name, labels = metricFamily(metadata, "cpu.per_core[1]")
+	if name != "node_core_cpu" || !reflect.DeepEqual(labels, []labelPair{{name: "field", value: "[1]"}}) {
+		t.Errorf("cpu.per_core[1] = %s %v, want node_core_cpu with field [1]", name, labels)
+	}
+
+	if name, labels = metricFamily(metadata, "memory"); name != "node_memory" || labels != nil {
+		t.Errorf("memory = %s %v, want node_memory without a field", name, labels)
+	}
+
+	// Keys outside every family, and results without families, keep their own name
+	if name, labels = metricFamily(metadata, "memoryless"); name != "memoryless" || labels != nil {
+		t.Errorf("memoryless = %s %v, want its own name", name, labels)
+	}
+	if name, labels = metricFamily(nil, "cpu.user"); name != "cpu.user" || labels != nil {
+		t.Errorf("cpu.user without families = %s %v, want its own name", name, labels)
+	}
+}
+
+func TestCreateStreamMetricPrefix(t *testing.T) {
+	loadCfg := config.LoadConfig{
+		MetricPrefix: "myorg_es_",
+		Metrics: []config.PrometheusMetricConfig{
+			{Name: "doc_count", Value: 1, Timestamp: config.NoTimestampColumn, TimestampSource: config.TimestampSourceNow},
+			{Name: "myorg_es_bytes", Value: 1, Timestamp: config.NoTimestampColumn, TimestampSource: config.TimestampSourceNow},
+		},
+	}
+	results := []*transform.TransformedResult{{
+		Result:     &extract.Result{Source: "http://es:9200", Timestamp: time.Unix(1700000000, 0)},
+		CSVHeaders: []string{"host", "count"},
+		CSVData:    [][]string{{"web-1", "42"}},
+	}}
+
+	names := func(t *testing.T, streamConfig map[string]interface{}) []string {
+		t.Helper()
+		stream, err := createStream(config.StreamConfig{Type: "prometheus_remote_write", Config: streamConfig}, loadCfg, newTransportCache(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		prom := stream.(*PrometheusRemoteWriteStream)
+		// Applying the prefix again, as a config reload would, must not stack it
+		prom.setMetricPrefix(prom.metricPrefix)
+
+		var got []string
+		for _, series := range prom.convertToPrometheusTimeSeries(results) {
+			got = append(got, series.Labels[0].Value)
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	if got, want := names(t, map[string]interface{}{"endpoint": "http://prom"}), []string{"myorg_es_bytes", "myorg_es_doc_count"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("load-level prefix: names = %v, want %v", got, want)
+	}
+	if got, want := names(t, map[string]interface{}{"endpoint": "http://prom", "metric_prefix": "team_"}), []string{"team_doc_count", "team_myorg_es_bytes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stream prefix: names = %v, want %v", got, want)
+	}
+	if loadCfg.Metrics[0].Name != "doc_count" {
+		t.Errorf("shared metric config was modified: %s", loadCfg.Metrics[0].Name)
+	}
+
+	if _, err := createStream(config.StreamConfig{Type: "gem", Config: map[string]interface{}{"endpoint": "http://gem", "metric_prefix": "9bad"}}, loadCfg, newTransportCache(), nil); err == nil {
+		t.Error("expected an error for an invalid metric_prefix")
+	}
+}
+
+func TestRetryPolicyHonorsRetryAfter(t *testing.T) {
+	var requests int
+	var mutex sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requests++
+		first := requests == 1
+		mutex.Unlock()
+		if first {
+			w.Header().Set("Retry-After", "600")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	// Retry-After is capped by max_retry_after; the default backoff would wait a second
+	stream, err := NewGEMStream(map[string]interface{}{"endpoint": server.URL, "max_retries": 1, "max_retry_after": "50ms"}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	if err := stream.Load(context.Background(), gemResults(1)); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(started)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if requests != 2 {
+		t.Errorf("sent %d requests, want the throttled one retried once", requests)
+	}
+	if elapsed < 50*time.Millisecond || elapsed >= time.Second {
+		t.Errorf("retried after %v, want the capped Retry-After of 50ms", elapsed)
+	}
+}
+
+func TestOnlyChanged(t *testing.T) {
+	metrics := []config.PrometheusMetricConfig{{
+		Name:              "heap",
+		UniqueFieldsIndex: []int{0},
+		Value:             1,
+		Timestamp:         2,
+		Labels:            []config.PrometheusLabelConfig{{LabelName: "host", IndexInCSVData: 0}},
+	}}
+	results := func(rows ...[]string) []*transform.TransformedResult {
+		return []*transform.TransformedResult{{
+			Result:     &extract.Result{Source: "http://es:9200"},
+			CSVHeaders: []string{"host", "heap", "ts"},
+			CSVData:    rows,
+		}}
+	}
+
+	for _, streamType := range []string{"gem", "prometheus_remote_write"} {
+		t.Run(streamType, func(t *testing.T) {
+			var mutex sync.Mutex
+			var sent map[string][]float64 // Sample values per host in the last request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mutex.Lock()
+				defer mutex.Unlock()
+				for _, ts := range decodeWriteRequest(t, body, r.Header.Get("Content-Encoding")).Timeseries {
+					var host string
+					for _, label := range ts.Labels {
+						if label.Name == "host" {
+							host = label.Value
+						}
+					}
+					for _, sample := range ts.Samples {
+						sent[host] = append(sent[host], sample.Value)
+					}
+				}
+			}))
+			defer server.Close()
+
+			stream, err := newStream(config.StreamConfig{Type: streamType, Config: map[string]interface{}{
+				"endpoint":         server.URL,
+				"only_changed":     true,
+				"only_changed_ttl": "100ms",
+			}}, metrics)
+			if err != nil {
+				t.Fatal(err)
+			}
+			load := func(rows ...[]string) map[string][]float64 {
+				t.Helper()
+				mutex.Lock()
+				sent = make(map[string][]float64)
+				mutex.Unlock()
+				if err := stream.Load(context.Background(), results(rows...)); err != nil {
+					t.Fatal(err)
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				return sent
+			}
+
+			// The first run sends every series, including samples repeating a value
+			got := load([]string{"a", "5", "1000"}, []string{"a", "5", "2000"}, []string{"b", "1", "1000"})
+			if want := map[string][]float64{"a": {5, 5}, "b": {1}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("first run sent %v, want %v", got, want)
+			}
+
+			// An unchanged latest value skips the series; a changed one sends all its samples
+			got = load([]string{"a", "5", "3000"}, []string{"a", "5", "4000"}, []string{"b", "1", "2000"}, []string{"b", "2", "3000"})
+			if want := map[string][]float64{"b": {1, 2}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("second run sent %v, want %v", got, want)
+			}
+
+			// Identical values are not sent again until the TTL expires
+			got = load([]string{"a", "5", "5000"}, []string{"b", "2", "4000"})
+			if len(got) != 0 {
+				t.Errorf("unchanged run sent %v, want nothing", got)
+			}
+			time.Sleep(150 * time.Millisecond)
+			got = load([]string{"a", "5", "6000"}, []string{"b", "2", "5000"})
+			if want := map[string][]float64{"a": {5}, "b": {2}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("run after the TTL sent %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCreateStreamMaxConnsPerHost(t *testing.T) {
+	var mutex sync.Mutex
+	var open, peak int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		switch state {
+		case http.StateNew:
+			open++
+			if open > peak {
+				peak = open
+			}
+		case http.StateClosed, http.StateHijacked:
+			open--
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	stream, err := createStream(config.StreamConfig{Type: "gem", Config: map[string]interface{}{"endpoint": server.URL, "max_conns_per_host": 2}}, config.LoadConfig{}, newTransportCache(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stream.Load(context.Background(), gemResults(1)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if peak > 2 {
+		t.Errorf("%d connections open at once, want at most 2", peak)
+	}
+}
+
+func TestDebugStreamCompactGzip(t *testing.T) {
+	dir := t.TempDir()
+	stream, err := NewDebugStream(map[string]interface{}{
+		"path":     filepath.Join(dir, "debug"),
+		"pretty":   false,
+		"compress": true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Load(context.Background(), gemResults(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "debug_load_*.json.gz"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("debug files = %v, %v", files, err)
+	}
+	file, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compact output is a single line of valid JSON
+	if bytes.ContainsRune(data, '\n') {
+		t.Errorf("compact output contains newlines: %s", data)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["results_count"] != 1.0 {
+		t.Errorf("results_count = %v, want 1", decoded["results_count"])
+	}
+}
+
+func TestDebugStreamEnabledFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DEBUG_DIR", dir)
+	cfg := config.LoadConfig{Streams: []config.StreamConfig{{
+		Type:   "debug",
+		Config: map[string]interface{}{"enabled": "${DEBUG_ENABLED}", "path": "${DEBUG_DIR}/out"},
+	}}}
+
+	t.Setenv("DEBUG_ENABLED", "false")
+	loader, err := NewLoader(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loader.streams) != 0 {
+		t.Errorf("%d streams with DEBUG_ENABLED=false, want 0", len(loader.streams))
+	}
+
+	t.Setenv("DEBUG_ENABLED", "true")
+	loader, err = NewLoader(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+	if err := loader.Load(context.Background(), gemResults(1)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "out_load_*.json")); len(files) != 1 {
+		t.Errorf("debug files with DEBUG_ENABLED=true = %v, want one", files)
+	}
+
+	t.Setenv("DEBUG_ENABLED", "maybe")
+	if _, err := NewLoader(cfg, nil); err == nil {
+		t.Error("expected an error for an unparsable enabled value")
+	}
+}
+
+func TestPrometheusExpositionLines(t *testing.T) {
+	stream, err := NewPrometheusStream(map[string]interface{}{"endpoint": "http://pushgateway:9091"}, map[string]string{"env": "prod"}, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.UnixMilli(1704067200000)
+
+	single := []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "es1", Timestamp: at},
+		TransformedData: map[string]interface{}{"doc_count": 42.0},
+	}}
+	if got, want := stream.convertToPrometheusFormat(single), `doc_count{env="prod",source="es1"} 42 1704067200000`+"\n"; got != want {
+		t.Errorf("single label set:\n got %q\nwant %q", got, want)
+	}
+
+	multi := []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "es1", Timestamp: at, Metadata: map[string]interface{}{"cluster_name": "east"}},
+		TransformedData: map[string]interface{}{"latency": 0.25},
+	}}
+	if got, want := stream.convertToPrometheusFormat(multi), `latency{cluster="east",env="prod",source="es1"} 0.25 1704067200000`+"\n"; got != want {
+		t.Errorf("multiple labels:\n got %q\nwant %q", got, want)
+	}
+
+	// Backslashes, quotes and newlines in label values are escaped; special values are spelled out
+	escaped := []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "C:\\es \"main\"\nnode", Timestamp: at},
+		TransformedData: map[string]interface{}{"errors": math.Inf(1)},
+	}}
+	if got, want := stream.convertToPrometheusFormat(escaped), `errors{env="prod",source="C:\\es \"main\"\nnode"} +Inf 1704067200000`+"\n"; got != want {
+		t.Errorf("escaped values:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestGEMStreamMaxSamplesPerRequest(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		samples := 0
+		for _, ts := range decodeWriteRequest(t, body, r.Header.Get("Content-Encoding")).Timeseries {
+			samples += len(ts.Samples)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		sizes = append(sizes, samples)
+		if len(sizes) == 2 {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	stream, err := NewGEMStream(map[string]interface{}{"endpoint": server.URL, "max_samples_per_request": 3}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = stream.Load(context.Background(), gemResults(7))
+
+	// A failed batch is reported without dropping the batches after it
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []int{3, 3, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("request sample counts = %v, want %v", sizes, want)
+	}
+	if err == nil || !strings.Contains(err.Error(), "batch 2 of 3") {
+		t.Errorf("Load error = %v, want batch 2 of 3 reported", err)
+	}
+
+	if _, err := NewGEMStream(map[string]interface{}{"endpoint": server.URL, "max_samples_per_request": -1}, nil, false, nil); err == nil {
+		t.Error("negative max_samples_per_request accepted")
+	}
+}
+
+func TestPrometheusRemoteWriteMaxSamplesPerRequest(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		samples := 0
+		for _, ts := range decodeWriteRequest(t, body, r.Header.Get("Content-Encoding")).Timeseries {
+			samples += len(ts.Samples)
+		}
+		mu.Lock()
+		sizes = append(sizes, samples)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	stream, err := NewPrometheusRemoteWriteStream(map[string]interface{}{"endpoint": server.URL, "max_samples_per_request": 2}, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Load(context.Background(), gemResults(5)); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []int{2, 2, 1}; !reflect.DeepEqual(sizes, want) {
+		t.Errorf("request sample counts = %v, want %v", sizes, want)
+	}
+}
+
+func TestChunkTimeSeries(t *testing.T) {
+	labels := []prompb.Label{{Name: "__name__", Value: "hits"}}
+	series := []*prompb.TimeSeries{
+		{Labels: labels, Samples: []prompb.Sample{{Value: 1}, {Value: 2}, {Value: 3}, {Value: 4}, {Value: 5}}},
+		{Labels: labels, Samples: []prompb.Sample{{Value: 6}}},
+	}
+
+	// A series with more samples than the limit is split across requests under the same labels
+	batches := chunkTimeSeries(series, 2)
+	var counts [][]int
+	for _, batch := range batches {
+		var batchCounts []int
+		for _, ts := range batch {
+			if !reflect.DeepEqual(ts.Labels, labels) {
+				t.Errorf("split series labels = %v, want %v", ts.Labels, labels)
+			}
+			batchCounts = append(batchCounts, len(ts.Samples))
+		}
+		counts = append(counts, batchCounts)
+	}
+	if want := [][]int{{2}, {2}, {1, 1}}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("samples per series per batch = %v, want %v", counts, want)
+	}
+
+	// No limit sends everything at once
+	if batches := chunkTimeSeries(series, 0); len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("unlimited batches = %d, want one batch of both series", len(batches))
+	}
+}
+
+// failingStream is a stream whose loads always fail
+type failingStream struct{}
+
+func (f *failingStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	return errors.New("sink unavailable")
+}
+
+func (f *failingStream) Close() error    { return nil }
+func (f *failingStream) GetType() string { return "failing" }
+
+func TestLoadFailurePolicy(t *testing.T) {
+	load := func(policy string, streams ...Stream) error {
+		loader := &Loader{
+			transports: newTransportCache(),
+			config:     config.LoadConfig{FailurePolicy: policy},
+			streams:    streams,
+		}
+		return loader.Load(context.Background(), gemResults(1))
+	}
+
+	// all (the default) fails when any stream fails, naming the failed stream
+	for _, policy := range []string{"", config.FailurePolicyAll} {
+		if err := load(policy, &fastStream{}, &failingStream{}); err == nil || !strings.Contains(err.Error(), "stream failing: sink unavailable") {
+			t.Errorf("policy %q with one failed stream: error = %v", policy, err)
+		}
+	}
+
+	// any fails only when no stream succeeds
+	if err := load(config.FailurePolicyAny, &fastStream{}, &failingStream{}); err != nil {
+		t.Errorf("policy any with one failed stream: %v", err)
+	}
+	if err := load(config.FailurePolicyAny, &failingStream{}, &failingStream{}); err == nil || !strings.Contains(err.Error(), "stream failing") {
+		t.Errorf("policy any with every stream failed: error = %v", err)
+	}
+
+	// best_effort never fails the run
+	if err := load(config.FailurePolicyBestEffort, &failingStream{}, &failingStream{}); err != nil {
+		t.Errorf("policy best_effort with every stream failed: %v", err)
+	}
+
+	for _, policy := range []string{"", config.FailurePolicyAll, config.FailurePolicyAny, config.FailurePolicyBestEffort} {
+		if err := load(policy, &fastStream{}, &fastStream{}); err != nil {
+			t.Errorf("policy %q with no failures: %v", policy, err)
+		}
+	}
+}
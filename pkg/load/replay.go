@@ -0,0 +1,60 @@
+package load
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"elasticetl/pkg/transform"
+)
+
+// ReadTransformedResults reads transformed results captured by a json debug stream, either the
+// debug file itself (with a "results" array) or a bare array of results. Gzipped files are
+// decompressed
+func ReadTransformedResults(path string) ([]*transform.TransformedResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+		data, err = io.ReadAll(gz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+		}
+	}
+
+	var results []*transform.TransformedResult
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &results); err != nil {
+			return nil, fmt.Errorf("failed to parse transformed results in %s: %w", path, err)
+		}
+	} else {
+		var debugFile struct {
+			Results []*transform.TransformedResult `json:"results"`
+		}
+		if err := json.Unmarshal(data, &debugFile); err != nil {
+			return nil, fmt.Errorf("failed to parse debug file %s: %w", path, err)
+		}
+		results = debugFile.Results
+	}
+
+	// Streams read metadata, so make sure every result has it
+	for _, result := range results {
+		if result == nil || result.Result == nil {
+			return nil, fmt.Errorf("%s contains a result without source data", path)
+		}
+		if result.Metadata == nil {
+			result.Metadata = make(map[string]interface{})
+		}
+	}
+
+	return results, nil
+}
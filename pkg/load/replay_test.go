@@ -0,0 +1,80 @@
+package load
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+)
+
+func TestReadTransformedResultsRoundTrip(t *testing.T) {
+	recorded := []*transform.TransformedResult{{
+		Result: &extract.Result{
+			Source:    "http://es:9200",
+			Timestamp: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+			Metadata:  map[string]interface{}{"cluster_name": "prod"},
+		},
+		TransformedData: map[string]interface{}{"docs.count": 42.0, "status": "green"},
+		CSVHeaders:      []string{"host", "heap"},
+		CSVData:         [][]string{{"web-1", "0.5"}},
+	}}
+
+	for _, compress := range []bool{false, true} {
+		dir := t.TempDir()
+		recorder, err := NewDebugStream(map[string]interface{}{"path": filepath.Join(dir, "capture"), "compress": compress}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := recorder.Load(context.Background(), recorded); err != nil {
+			t.Fatal(err)
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "capture_load_*"))
+		if err != nil || len(files) != 1 {
+			t.Fatalf("compress %v: recorded files %v, %v", compress, files, err)
+		}
+
+		results, err := ReadTransformedResults(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 {
+			t.Fatalf("compress %v: read %d results, want 1", compress, len(results))
+		}
+		got, want := results[0], recorded[0]
+		if got.Source != want.Source || !got.Timestamp.Equal(want.Timestamp) || got.Metadata["cluster_name"] != "prod" {
+			t.Errorf("compress %v: result = %+v, want %+v", compress, got.Result, want.Result)
+		}
+		if !reflect.DeepEqual(got.TransformedData, want.TransformedData) {
+			t.Errorf("compress %v: transformed data = %v, want %v", compress, got.TransformedData, want.TransformedData)
+		}
+		if !reflect.DeepEqual(got.CSVHeaders, want.CSVHeaders) || !reflect.DeepEqual(got.CSVData, want.CSVData) {
+			t.Errorf("compress %v: CSV = %v %v, want %v %v", compress, got.CSVHeaders, got.CSVData, want.CSVHeaders, want.CSVData)
+		}
+	}
+}
+
+func TestReadTransformedResultsBareArray(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	if err := os.WriteFile(path, []byte(`[{"source":"http://es:9200","transformed_data":{"up":1}}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := ReadTransformedResults(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Source != "http://es:9200" || results[0].Metadata == nil {
+		t.Errorf("results = %+v", results)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"transformed_data":{"up":1}}, null]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadTransformedResults(path); err == nil {
+		t.Error("expected an error for a result without source data")
+	}
+}
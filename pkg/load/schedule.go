@@ -0,0 +1,193 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"elasticetl/pkg/transform"
+	"elasticetl/pkg/utils"
+)
+
+// defaultScheduleMaxBuffered caps the results held outside the schedule when buffering
+const defaultScheduleMaxBuffered = 10000
+
+// scheduleDays maps schedule day names to weekdays
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// timeWindow is a daily window in minutes since midnight. A window whose end is not after its
+// start runs past midnight
+type timeWindow struct {
+	start, end int
+}
+
+// contains reports whether minute (since midnight) falls inside the window, end exclusive
+func (w timeWindow) contains(minute int) bool {
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// scheduledStream only loads to the wrapped stream inside its time windows. Results arriving
+// outside the windows are dropped, or buffered and sent with the first load inside a window
+type scheduledStream struct {
+	Stream
+	windows     []timeWindow
+	days        map[time.Weekday]bool // Empty allows every day
+	location    *time.Location
+	buffer      bool
+	maxBuffered int
+	now         func() time.Time
+
+	mutex   sync.Mutex
+	pending []*transform.TransformedResult
+}
+
+// parseSchedule reads the schedule object (windows, days, timezone, outside, max_buffered)
+// from stream config and wraps stream with it. Streams without a schedule are returned as is
+func parseSchedule(stream Stream, streamConfig map[string]interface{}) (Stream, error) {
+	raw, ok := streamConfig["schedule"]
+	if !ok {
+		return stream, nil
+	}
+	scheduleMap, ok := safeMapStringInterface(raw)
+	if !ok {
+		return nil, fmt.Errorf("schedule must be an object")
+	}
+
+	scheduled := &scheduledStream{
+		Stream:      stream,
+		days:        make(map[time.Weekday]bool),
+		location:    time.Local,
+		maxBuffered: defaultScheduleMaxBuffered,
+		now:         time.Now,
+	}
+
+	windows, _ := scheduleMap["windows"].([]interface{})
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("schedule.windows is required")
+	}
+	for _, w := range windows {
+		s, _ := safeString(w)
+		window, err := parseTimeWindow(s)
+		if err != nil {
+			return nil, err
+		}
+		scheduled.windows = append(scheduled.windows, window)
+	}
+
+	if days, ok := scheduleMap["days"].([]interface{}); ok {
+		for _, d := range days {
+			s, _ := safeString(d)
+			day, ok := scheduleDays[strings.ToLower(s)]
+			if !ok {
+				return nil, fmt.Errorf("invalid schedule day %q (use sun, mon, tue, wed, thu, fri or sat)", s)
+			}
+			scheduled.days[day] = true
+		}
+	}
+
+	if tz, ok := safeString(scheduleMap["timezone"]); ok && tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule timezone %q: %w", tz, err)
+		}
+		scheduled.location = location
+	}
+
+	switch outside, _ := safeString(scheduleMap["outside"]); outside {
+	case "", "drop":
+	case "buffer":
+		scheduled.buffer = true
+	default:
+		return nil, fmt.Errorf("invalid schedule outside %q (must be drop or buffer)", outside)
+	}
+
+	if rawMax, ok := scheduleMap["max_buffered"]; ok {
+		n, ok := utils.SafeInt(rawMax)
+		if !ok || n <= 0 {
+			return nil, fmt.Errorf("schedule.max_buffered must be a positive integer")
+		}
+		scheduled.maxBuffered = n
+	}
+
+	return scheduled, nil
+}
+
+// parseTimeWindow parses a "HH:MM-HH:MM" window
+func parseTimeWindow(s string) (timeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return timeWindow{}, fmt.Errorf("invalid schedule window %q (want HH:MM-HH:MM)", s)
+	}
+	var minutes [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return timeWindow{}, fmt.Errorf("invalid schedule window %q (want HH:MM-HH:MM)", s)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+	return timeWindow{start: minutes[0], end: minutes[1]}, nil
+}
+
+// open reports whether t falls inside one of the windows on an allowed day
+func (s *scheduledStream) open(t time.Time) bool {
+	t = t.In(s.location)
+	if len(s.days) > 0 && !s.days[t.Weekday()] {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, window := range s.windows {
+		if window.contains(minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load loads inside the schedule, sending any buffered results first. Outside it the results
+// are buffered or dropped and no error is returned
+func (s *scheduledStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.open(s.now()) {
+		if s.buffer {
+			s.pending = s.keepNewest(append(s.pending, results...))
+		}
+		return nil
+	}
+
+	batch := results
+	if len(s.pending) > 0 {
+		batch = append(s.pending, results...)
+		s.pending = nil
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := s.Stream.Load(ctx, batch); err != nil {
+		// Keep buffered results for the next attempt rather than losing them with this batch
+		if s.buffer {
+			s.pending = s.keepNewest(batch)
+		}
+		return err
+	}
+	return nil
+}
+
+// keepNewest drops the oldest results beyond max_buffered
+func (s *scheduledStream) keepNewest(results []*transform.TransformedResult) []*transform.TransformedResult {
+	if len(results) > s.maxBuffered {
+		results = results[len(results)-s.maxBuffered:]
+	}
+	return results
+}
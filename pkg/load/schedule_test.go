@@ -0,0 +1,119 @@
+package load
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/transform"
+)
+
+// recordingStream records the size of every batch loaded into it
+type recordingStream struct {
+	batches []int
+}
+
+func (r *recordingStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	r.batches = append(r.batches, len(results))
+	return nil
+}
+
+func (r *recordingStream) Close() error    { return nil }
+func (r *recordingStream) GetType() string { return "recording" }
+
+func TestTimeWindowContains(t *testing.T) {
+	day, err := parseTimeWindow("09:00-17:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The start minute is inside the window and the end minute is not
+	if !day.contains(9*60) || !day.contains(16*60+59) || day.contains(17*60) || day.contains(8*60+59) {
+		t.Error("09:00-17:00 does not cover 09:00 up to but excluding 17:00")
+	}
+
+	night, err := parseTimeWindow("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A window ending before it starts wraps past midnight
+	if !night.contains(23*60+30) || !night.contains(5*60+59) || night.contains(12*60) {
+		t.Error("22:00-06:00 does not wrap past midnight")
+	}
+
+	if _, err := parseTimeWindow("9-5"); err == nil {
+		t.Error("expected an error for a malformed window")
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	window := []interface{}{"09:00-17:00"}
+	for _, schedule := range []interface{}{
+		"09:00-17:00",
+		map[string]interface{}{},
+		map[string]interface{}{"windows": []interface{}{"9-5"}},
+		map[string]interface{}{"windows": window, "days": []interface{}{"someday"}},
+		map[string]interface{}{"windows": window, "timezone": "Nowhere/Land"},
+		map[string]interface{}{"windows": window, "outside": "queue"},
+		map[string]interface{}{"windows": window, "max_buffered": 0},
+	} {
+		if _, err := parseSchedule(&recordingStream{}, map[string]interface{}{"schedule": schedule}); err == nil {
+			t.Errorf("parseSchedule(%v) succeeded, want an error", schedule)
+		}
+	}
+
+	// Without a schedule the stream is returned as is
+	inner := &recordingStream{}
+	if stream, err := parseSchedule(inner, map[string]interface{}{}); err != nil || stream != inner {
+		t.Errorf("parseSchedule without a schedule = %v, %v; want the stream unwrapped", stream, err)
+	}
+}
+
+// scheduledLoads loads one result at each time through a stream scheduled for 09:00-17:00
+// UTC plus extra settings, returning the batch sizes that reached the wrapped stream
+func scheduledLoads(t *testing.T, extra map[string]interface{}, times ...time.Time) []int {
+	t.Helper()
+	schedule := map[string]interface{}{"windows": []interface{}{"09:00-17:00"}, "timezone": "UTC"}
+	for key, value := range extra {
+		schedule[key] = value
+	}
+	inner := &recordingStream{}
+	stream, err := parseSchedule(inner, map[string]interface{}{"schedule": schedule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled := stream.(*scheduledStream)
+
+	for _, at := range times {
+		scheduled.now = func() time.Time { return at }
+		if err := scheduled.Load(context.Background(), gemResults(1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return inner.batches
+}
+
+func TestScheduledStreamLoad(t *testing.T) {
+	// Wednesday 2024-01-03, before and inside the window
+	closed := time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)
+	open := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+
+	// Batches outside the window are dropped by default
+	if got := scheduledLoads(t, nil, closed, open); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("drop outside: batches %v, want [1]", got)
+	}
+
+	// Buffered batches are sent with the first batch inside the window
+	if got := scheduledLoads(t, map[string]interface{}{"outside": "buffer"}, closed, closed, open); !reflect.DeepEqual(got, []int{3}) {
+		t.Errorf("buffer outside: batches %v, want [3]", got)
+	}
+
+	// max_buffered keeps only the newest batches
+	if got := scheduledLoads(t, map[string]interface{}{"outside": "buffer", "max_buffered": 1}, closed, closed, open); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("buffer bounded: batches %v, want [2]", got)
+	}
+
+	if got := scheduledLoads(t, map[string]interface{}{"days": []interface{}{"mon"}}, open); len(got) != 0 {
+		t.Errorf("day not allowed: batches %v, want none", got)
+	}
+}
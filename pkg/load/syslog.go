@@ -0,0 +1,155 @@
+//go:build !windows && !plan9
+
+package load
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"strings"
+	"sync"
+
+	"elasticetl/pkg/transform"
+)
+
+// syslogFacilities maps facility names to their syslog priority bits
+var syslogFacilities = map[string]syslog.Priority{
+	"kern": syslog.LOG_KERN, "user": syslog.LOG_USER, "mail": syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON, "auth": syslog.LOG_AUTH, "syslog": syslog.LOG_SYSLOG,
+	"lpr": syslog.LOG_LPR, "news": syslog.LOG_NEWS, "uucp": syslog.LOG_UUCP,
+	"cron": syslog.LOG_CRON, "authpriv": syslog.LOG_AUTHPRIV, "ftp": syslog.LOG_FTP,
+	"local0": syslog.LOG_LOCAL0, "local1": syslog.LOG_LOCAL1, "local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3, "local4": syslog.LOG_LOCAL4, "local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6, "local7": syslog.LOG_LOCAL7,
+}
+
+// syslogSeverities maps severity names to their syslog priority bits
+var syslogSeverities = map[string]syslog.Priority{
+	"emerg": syslog.LOG_EMERG, "alert": syslog.LOG_ALERT, "crit": syslog.LOG_CRIT,
+	"err": syslog.LOG_ERR, "warning": syslog.LOG_WARNING, "notice": syslog.LOG_NOTICE,
+	"info": syslog.LOG_INFO, "debug": syslog.LOG_DEBUG,
+}
+
+// SyslogStream writes results to a local or remote syslog daemon
+type SyslogStream struct {
+	format string // "json" (default): one message per result, or "csv": one message per CSV row
+	writer *syslog.Writer
+	mutex  sync.Mutex
+}
+
+// NewSyslogStream creates a new syslog stream. With no network the local syslog daemon is used;
+// otherwise network (udp, tcp) and address name the remote daemon
+func NewSyslogStream(config map[string]interface{}) (*SyslogStream, error) {
+	format := "json"
+	if f, ok := safeString(config["format"]); ok && f != "" {
+		format = f
+	}
+	switch format {
+	case "json", "csv":
+	default:
+		return nil, fmt.Errorf("unsupported syslog format: %s (must be json or csv)", format)
+	}
+
+	facility := syslog.LOG_DAEMON
+	if f, ok := safeString(config["facility"]); ok && f != "" {
+		parsed, exists := syslogFacilities[strings.ToLower(f)]
+		if !exists {
+			return nil, fmt.Errorf("unsupported syslog facility: %s", f)
+		}
+		facility = parsed
+	}
+
+	severity := syslog.LOG_INFO
+	if s, ok := safeString(config["severity"]); ok && s != "" {
+		parsed, exists := syslogSeverities[strings.ToLower(s)]
+		if !exists {
+			return nil, fmt.Errorf("unsupported syslog severity: %s", s)
+		}
+		severity = parsed
+	}
+
+	tag := "elasticetl"
+	if t, ok := safeString(config["tag"]); ok && t != "" {
+		tag = t
+	}
+
+	network, _ := safeString(config["network"])
+	address, _ := safeString(config["address"])
+	if network != "" && address == "" {
+		return nil, fmt.Errorf("syslog stream with network %s requires 'address' configuration", network)
+	}
+
+	writer, err := syslog.Dial(network, address, facility|severity, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	return &SyslogStream{
+		format: format,
+		writer: writer,
+	}, nil
+}
+
+// Load writes one syslog message per result (json) or per CSV row (csv)
+func (s *SyslogStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, result := range results {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if s.format == "csv" {
+			for _, row := range result.CSVData {
+				line, err := csvLine(row)
+				if err != nil {
+					return fmt.Errorf("failed to format CSV row: %w", err)
+				}
+				if _, err := s.writer.Write([]byte(line)); err != nil {
+					return fmt.Errorf("failed to write to syslog: %w", err)
+				}
+			}
+			continue
+		}
+
+		message, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		if _, err := s.writer.Write(message); err != nil {
+			return fmt.Errorf("failed to write to syslog: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// csvLine formats row as a single CSV line without the trailing newline
+func csvLine(row []string) (string, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(row); err != nil {
+		return "", err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+// Close closes the syslog connection
+func (s *SyslogStream) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.writer.Close()
+}
+
+// GetType returns the stream type
+func (s *SyslogStream) GetType() string {
+	return "syslog"
+}
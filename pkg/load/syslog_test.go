@@ -0,0 +1,119 @@
+//go:build !windows && !plan9
+
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/extract"
+	"elasticetl/pkg/transform"
+)
+
+// syslogListener receives datagrams on a local UDP socket and returns each message's priority
+// and body
+func syslogListener(t *testing.T) (net.PacketConn, func() (string, string)) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	receive := func() (string, string) {
+		t.Helper()
+		buf := make([]byte, 64*1024)
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packet := strings.TrimRight(string(buf[:n]), "\n")
+		priority := packet[:strings.Index(packet, ">")+1]
+		body := packet[strings.Index(packet, ": ")+2:]
+		return priority, body
+	}
+	return conn, receive
+}
+
+func TestSyslogStreamJSON(t *testing.T) {
+	conn, receive := syslogListener(t)
+	stream, err := NewSyslogStream(map[string]interface{}{
+		"network":  "udp",
+		"address":  conn.LocalAddr().String(),
+		"facility": "local0",
+		"severity": "warning",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	results := []*transform.TransformedResult{{
+		Result:          &extract.Result{Source: "http://es:9200", Metadata: map[string]interface{}{}},
+		TransformedData: map[string]interface{}{"up": 1.0},
+	}}
+	if err := stream.Load(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+
+	// local0 (16) * 8 + warning (4)
+	priority, body := receive()
+	if priority != "<132>" {
+		t.Errorf("priority = %s, want <132>", priority)
+	}
+	var message map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &message); err != nil {
+		t.Fatalf("message %q is not JSON: %v", body, err)
+	}
+	if message["source"] != "http://es:9200" {
+		t.Errorf("message source = %v, want http://es:9200", message["source"])
+	}
+}
+
+func TestSyslogStreamCSV(t *testing.T) {
+	conn, receive := syslogListener(t)
+	stream, err := NewSyslogStream(map[string]interface{}{
+		"network": "udp",
+		"address": conn.LocalAddr().String(),
+		"format":  "csv",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	results := []*transform.TransformedResult{{
+		Result:     &extract.Result{Metadata: map[string]interface{}{}},
+		CSVHeaders: []string{"host", "note"},
+		CSVData:    [][]string{{"web-1", "a,b"}, {"web-2", "ok"}},
+	}}
+	if err := stream.Load(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+
+	// One message per row at the default daemon (3) * 8 + info (6)
+	for _, want := range []string{`web-1,"a,b"`, "web-2,ok"} {
+		priority, body := receive()
+		if priority != "<30>" || body != want {
+			t.Errorf("message = %s %q, want <30> %q", priority, body, want)
+		}
+	}
+}
+
+func TestNewSyslogStreamErrors(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"format": "xml"},
+		{"facility": "nope"},
+		{"severity": "loud"},
+		{"network": "udp"},
+	} {
+		if _, err := NewSyslogStream(cfg); err == nil {
+			t.Errorf("NewSyslogStream(%v) succeeded, want an error", cfg)
+		}
+	}
+}
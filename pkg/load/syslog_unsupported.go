@@ -0,0 +1,33 @@
+//go:build windows || plan9
+
+package load
+
+import (
+	"context"
+	"fmt"
+
+	"elasticetl/pkg/transform"
+)
+
+// SyslogStream is unavailable on platforms without log/syslog
+type SyslogStream struct{}
+
+// NewSyslogStream reports that syslog is unsupported on this platform
+func NewSyslogStream(config map[string]interface{}) (*SyslogStream, error) {
+	return nil, fmt.Errorf("syslog stream is not supported on this platform")
+}
+
+// Load is never reached since NewSyslogStream always fails
+func (s *SyslogStream) Load(ctx context.Context, results []*transform.TransformedResult) error {
+	return fmt.Errorf("syslog stream is not supported on this platform")
+}
+
+// Close does nothing
+func (s *SyslogStream) Close() error {
+	return nil
+}
+
+// GetType returns the stream type
+func (s *SyslogStream) GetType() string {
+	return "syslog"
+}
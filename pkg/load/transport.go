@@ -0,0 +1,65 @@
+package load
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// transportKey identifies transports that can be shared: same endpoint host and settings
+type transportKey struct {
+	host            string // scheme://host[:port]
+	insecureTLS     bool
+	maxConnsPerHost int
+}
+
+// transportCache shares HTTP transports, and so their connection pools, between a loader's
+// streams sending to the same host. Each stream keeps its own client, so client timeouts
+// still apply per stream
+type transportCache struct {
+	mutex      sync.Mutex
+	transports map[transportKey]*http.Transport
+}
+
+// newTransportCache creates an empty transport cache
+func newTransportCache() *transportCache {
+	return &transportCache{transports: make(map[transportKey]*http.Transport)}
+}
+
+// share replaces client's transport with the cached one for the endpoint's host and client's
+// transport settings, caching client's own transport if none is cached yet
+func (c *transportCache) share(client *http.Client, endpoint string) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		return
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return
+	}
+
+	key := transportKey{
+		host:            u.Scheme + "://" + u.Host,
+		insecureTLS:     transport.TLSClientConfig != nil && transport.TLSClientConfig.InsecureSkipVerify,
+		maxConnsPerHost: transport.MaxConnsPerHost,
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if shared, ok := c.transports[key]; ok {
+		client.Transport = shared
+		return
+	}
+	c.transports[key] = transport
+}
+
+// closeIdleConnections closes the idle connections of every cached transport
+func (c *transportCache) closeIdleConnections() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, transport := range c.transports {
+		transport.CloseIdleConnections()
+	}
+}
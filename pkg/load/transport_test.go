@@ -0,0 +1,47 @@
+package load
+
+import (
+	"net/http"
+	"testing"
+
+	"elasticetl/pkg/config"
+)
+
+func TestTransportCacheSharesTransports(t *testing.T) {
+	transports := newTransportCache()
+	client := func(cfg config.StreamConfig) *http.Client {
+		t.Helper()
+		stream, err := createStream(cfg, config.LoadConfig{}, transports, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch s := stream.(type) {
+		case *GEMStream:
+			return s.httpClient
+		case *PrometheusRemoteWriteStream:
+			return s.httpClient
+		default:
+			t.Fatalf("unexpected stream %T", stream)
+			return nil
+		}
+	}
+
+	gem := client(config.StreamConfig{Type: "gem", Config: map[string]interface{}{"endpoint": "https://metrics:9009/api/v1/push"}})
+	remoteWrite := client(config.StreamConfig{Type: "prometheus_remote_write", Config: map[string]interface{}{"endpoint": "https://metrics:9009/api/prom/push"}})
+	insecure := client(config.StreamConfig{Type: "gem", InsecureTLS: true, Config: map[string]interface{}{"endpoint": "https://metrics:9009/api/v1/push"}})
+	otherHost := client(config.StreamConfig{Type: "gem", Config: map[string]interface{}{"endpoint": "https://other:9009/api/v1/push"}})
+
+	// Same host and TLS settings share one transport, and so one connection pool
+	if gem.Transport != remoteWrite.Transport {
+		t.Error("streams to the same host with the same TLS settings have different transports")
+	}
+	if gem == remoteWrite {
+		t.Error("streams share a client, so their timeouts are no longer their own")
+	}
+	if insecure.Transport == gem.Transport {
+		t.Error("insecure_tls stream shares a transport that verifies certificates")
+	}
+	if otherHost.Transport == gem.Transport {
+		t.Error("streams to different hosts share a transport")
+	}
+}
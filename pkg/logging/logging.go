@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"elasticetl/pkg/config"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+var (
+	mutex      sync.Mutex
+	fileLogger *lumberjack.Logger
+)
+
+// Apply directs the standard logger to the configured output. When output is "file" log lines
+// are written to cfg.File and rotated by size and age; otherwise they go to console. Calling
+// Apply again (e.g. on config reload) replaces the previous output and closes any old log file.
+func Apply(cfg config.LoggingConfig, console io.Writer) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if cfg.Output != "file" {
+		log.SetOutput(console)
+		return closeFileLogger()
+	}
+
+	if cfg.File == "" {
+		return fmt.Errorf("logging output 'file' requires a file path")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	logger := &lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAgeDays,
+		Compress:   cfg.Compress,
+	}
+
+	log.SetOutput(logger)
+	if err := closeFileLogger(); err != nil {
+		log.Printf("Failed to close previous log file: %v", err)
+	}
+	fileLogger = logger
+
+	return nil
+}
+
+// Close closes the active log file, if any
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return closeFileLogger()
+}
+
+// closeFileLogger closes the active log file; callers must hold the mutex
+func closeFileLogger() error {
+	if fileLogger == nil {
+		return nil
+	}
+
+	err := fileLogger.Close()
+	fileLogger = nil
+	return err
+}
+
+// Logger writes through the standard logger, tagging each line with the pipeline it belongs
+// to so output from concurrently running pipelines can be told apart. A nil Logger writes
+// untagged lines
+type Logger struct {
+	prefix string
+}
+
+// ForPipeline returns a logger tagging lines with pipeline=name
+func ForPipeline(name string) *Logger {
+	return &Logger{prefix: "pipeline=" + name + " "}
+}
+
+// Printf logs a formatted line
+func (l *Logger) Printf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if l != nil {
+		message = l.prefix + message
+	}
+	log.Output(2, message)
+}
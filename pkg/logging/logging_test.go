@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"elasticetl/pkg/config"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		file    string
+		wantErr bool
+	}{
+		{"console", "stdout", "", false},
+		{"file in a new directory", "file", "logs/etl.log", false},
+		{"file without a path", "file", "", true},
+	}
+
+	defer log.SetOutput(os.Stderr)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var console bytes.Buffer
+			cfg := config.LoggingConfig{Output: tt.output}
+			if tt.file != "" {
+				cfg.File = filepath.Join(t.TempDir(), tt.file)
+			}
+			err := Apply(cfg, &console)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			log.Printf("hello")
+			if err := Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			written := console.String()
+			if cfg.File != "" {
+				if written != "" {
+					t.Errorf("console received file output: %s", written)
+				}
+				data, err := os.ReadFile(cfg.File)
+				if err != nil {
+					t.Fatal(err)
+				}
+				written = string(data)
+			}
+			if !strings.Contains(written, "hello") {
+				t.Errorf("log output %q lacks the line", written)
+			}
+		})
+	}
+}
+
+func TestApplyReloadLeavesFile(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	path := filepath.Join(t.TempDir(), "etl.log")
+	var console bytes.Buffer
+
+	if err := Apply(config.LoggingConfig{Output: "file", File: path}, &console); err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("to file")
+
+	// Reloading with console output closes the file and stops writing to it
+	if err := Apply(config.LoggingConfig{Output: "stdout"}, &console); err != nil {
+		t.Fatal(err)
+	}
+	log.Printf("to console")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "to file") || strings.Contains(string(data), "to console") {
+		t.Errorf("log file = %q", data)
+	}
+	if !strings.Contains(console.String(), "to console") {
+		t.Errorf("console = %q", console.String())
+	}
+}
+
+func TestForPipeline(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	// Pipelines logging at once each keep their own tag on every line
+	var wg sync.WaitGroup
+	for _, name := range []string{"orders", "users"} {
+		wg.Add(1)
+		go func(logger *Logger) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				logger.Printf("run %d", i)
+			}
+		}(ForPipeline(name))
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 100 {
+		t.Fatalf("got %d lines, want 100", len(lines))
+	}
+	counts := make(map[string]int)
+	for _, line := range lines {
+		switch {
+		case strings.Contains(line, "pipeline=orders run "):
+			counts["orders"]++
+		case strings.Contains(line, "pipeline=users run "):
+			counts["users"]++
+		default:
+			t.Errorf("line %q lacks its pipeline", line)
+		}
+	}
+	if counts["orders"] != 50 || counts["users"] != 50 {
+		t.Errorf("lines per pipeline = %v, want 50 each", counts)
+	}
+}
+
+func TestNilLoggerPrintf(t *testing.T) {
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	var logger *Logger
+	logger.Printf("untagged %d", 1)
+	if !strings.Contains(out.String(), "untagged 1") || strings.Contains(out.String(), "pipeline=") {
+		t.Errorf("nil logger wrote %q", out.String())
+	}
+}
@@ -4,32 +4,53 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"runtime"
 	"sync"
 	"time"
 
 	"elasticetl/pkg/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 // PipelineMetrics represents metrics for a single pipeline
 type PipelineMetrics struct {
-	Name               string        `json:"name"`
-	Enabled            bool          `json:"enabled"`
-	LastRun            time.Time     `json:"last_run"`
-	LastDuration       time.Duration `json:"last_duration"`
-	TotalRuns          int64         `json:"total_runs"`
-	SuccessfulRuns     int64         `json:"successful_runs"`
-	FailedRuns         int64         `json:"failed_runs"`
-	EntriesProcessed   int64         `json:"entries_processed"`
-	BytesProcessed     int64         `json:"bytes_processed"`
-	MemoryUsageMB      float64       `json:"memory_usage_mb"`
-	CPUUsagePercent    float64       `json:"cpu_usage_percent"`
-	ActiveGoroutines   int           `json:"active_goroutines"`
-	ErrorRate          float64       `json:"error_rate"`
-	AverageProcessTime time.Duration `json:"average_process_time"`
-	LastError          string        `json:"last_error,omitempty"`
-	LastErrorTime      time.Time     `json:"last_error_time,omitempty"`
+	Name               string            `json:"name"`
+	Enabled            bool              `json:"enabled"`
+	LastRun            time.Time         `json:"last_run"`
+	LastDuration       time.Duration     `json:"last_duration"`
+	TotalRuns          int64             `json:"total_runs"`
+	SuccessfulRuns     int64             `json:"successful_runs"`
+	FailedRuns         int64             `json:"failed_runs"`
+	EntriesProcessed   int64             `json:"entries_processed"`
+	BytesProcessed     int64             `json:"bytes_processed"`
+	EntriesDelivered   int64             `json:"entries_delivered"` // Excludes runs loaded only to the debug stream
+	BytesDelivered     int64             `json:"bytes_delivered"`
+	MemoryUsageMB      float64           `json:"memory_usage_mb"`
+	CPUUsagePercent    float64           `json:"cpu_usage_percent"`
+	ActiveGoroutines   int               `json:"active_goroutines"`
+	ErrorRate          float64           `json:"error_rate"`
+	AverageProcessTime time.Duration     `json:"average_process_time"`
+	LastError          string            `json:"last_error,omitempty"`
+	LastErrorTime      time.Time         `json:"last_error_time,omitempty"`
+	LoadQueueDepth     int               `json:"load_queue_depth"`
+	DroppedBatches     int64             `json:"dropped_batches"`
+	DroppedSeries      int64             `json:"dropped_series"`
+	LastTransform      TransformStats    `json:"last_transform"`
+	TransformTotals    TransformStats    `json:"transform_totals"`
+	Labels             map[string]string `json:"labels,omitempty"`
+}
+
+// TransformStats counts what a pipeline's transform did
+type TransformStats struct {
+	InputRecords    int64 `json:"input_records"`
+	OutputRecords   int64 `json:"output_records"`
+	RowsGenerated   int64 `json:"rows_generated"`
+	FieldsDropped   int64 `json:"fields_dropped"`
+	FieldsConverted int64 `json:"fields_converted"`
 }
 
 // SystemMetrics represents overall system metrics
@@ -42,6 +63,14 @@ type SystemMetrics struct {
 	TotalPipelines   int           `json:"total_pipelines"`
 	Uptime           time.Duration `json:"uptime"`
 	LastConfigReload time.Time     `json:"last_config_reload"`
+
+	ConfigReloadsTotal        int64     `json:"config_reloads_total"`
+	ConfigReloadFailuresTotal int64     `json:"config_reload_failures_total"`
+	LastConfigReloadError     string    `json:"last_config_reload_error,omitempty"`
+	LastConfigReloadErrorTime time.Time `json:"last_config_reload_error_time,omitempty"`
+
+	HeartbeatsTotal int64     `json:"heartbeats_total"`
+	LastHeartbeat   time.Time `json:"last_heartbeat,omitempty"`
 }
 
 // Collector handles metrics collection and reporting
@@ -52,6 +81,14 @@ type Collector struct {
 	mutex           sync.RWMutex
 	startTime       time.Time
 	httpServer      *http.Server
+	grpcServer      *grpc.Server
+	healthServer    *health.Server
+	closing         bool
+	lastResponse    LastResponseFunc
+	pipelineList    PipelineListFunc
+	heartbeatStop   chan struct{}
+
+	endpointLatencies map[endpointKey]*EndpointLatency
 }
 
 // NewCollector creates a new metrics collector
@@ -61,11 +98,17 @@ func NewCollector(cfg config.MetricsConfig) *Collector {
 		pipelineMetrics: make(map[string]*PipelineMetrics),
 		systemMetrics:   &SystemMetrics{},
 		startTime:       time.Now(),
+
+		endpointLatencies: make(map[endpointKey]*EndpointLatency),
 	}
 
 	if cfg.Enabled {
 		collector.startHTTPServer()
-		go collector.collectSystemMetrics()
+		if cfg.GRPCHealth.Enabled {
+			collector.startGRPCHealthServer()
+		}
+		go collector.collectSystemMetrics(cfg.Interval)
+		collector.startHeartbeat()
 	}
 
 	return collector
@@ -126,6 +169,25 @@ func (c *Collector) RecordPipelineSuccess(pipelineName string, duration time.Dur
 	}
 }
 
+// RecordDelivered records entries and bytes of a successful run that reached a real sink, not
+// just the debug stream
+func (c *Collector) RecordDelivered(pipelineName string, entriesDelivered int64, bytesDelivered int64) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		return
+	}
+
+	metrics.EntriesDelivered += entriesDelivered
+	metrics.BytesDelivered += bytesDelivered
+}
+
 // RecordPipelineFailure records a failed pipeline execution
 func (c *Collector) RecordPipelineFailure(pipelineName string, duration time.Duration, err error) {
 	if !c.config.Enabled {
@@ -151,6 +213,79 @@ func (c *Collector) RecordPipelineFailure(pipelineName string, duration time.Dur
 	}
 }
 
+// RecordDroppedBatch records a transformed batch dropped because the load queue was full
+func (c *Collector) RecordDroppedBatch(pipelineName string) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		return
+	}
+
+	metrics.DroppedBatches++
+}
+
+// RecordDroppedSeries records series dropped by a pipeline's max_series_per_run budget
+func (c *Collector) RecordDroppedSeries(pipelineName string, dropped int) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		return
+	}
+
+	metrics.DroppedSeries += int64(dropped)
+}
+
+// RecordTransformStats records the transform stats of a pipeline run
+func (c *Collector) RecordTransformStats(pipelineName string, stats TransformStats) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		return
+	}
+
+	metrics.LastTransform = stats
+	metrics.TransformTotals.InputRecords += stats.InputRecords
+	metrics.TransformTotals.OutputRecords += stats.OutputRecords
+	metrics.TransformTotals.RowsGenerated += stats.RowsGenerated
+	metrics.TransformTotals.FieldsDropped += stats.FieldsDropped
+	metrics.TransformTotals.FieldsConverted += stats.FieldsConverted
+}
+
+// UpdateLoadQueueDepth records the number of batches waiting in a pipeline's load queue
+func (c *Collector) UpdateLoadQueueDepth(pipelineName string, depth int) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		return
+	}
+
+	metrics.LoadQueueDepth = depth
+}
+
 // UpdatePipelineStatus updates the enabled status of a pipeline
 func (c *Collector) UpdatePipelineStatus(pipelineName string, enabled bool) {
 	if !c.config.Enabled {
@@ -170,6 +305,43 @@ func (c *Collector) UpdatePipelineStatus(pipelineName string, enabled bool) {
 	} else {
 		metrics.Enabled = enabled
 	}
+
+	c.updateHealthStatus()
+}
+
+// SetPipelineLabels sets the constant labels attached to a pipeline's series
+func (c *Collector) SetPipelineLabels(pipelineName string, labels map[string]string) {
+	if !c.config.Enabled {
+		return
+	}
+
+	// Keep a private copy; the map is replaced rather than modified so copies stay consistent
+	labelsCopy := make(map[string]string, len(labels))
+	for name, value := range labels {
+		labelsCopy[name] = value
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	metrics, exists := c.pipelineMetrics[pipelineName]
+	if !exists {
+		metrics = &PipelineMetrics{
+			Name: pipelineName,
+		}
+		c.pipelineMetrics[pipelineName] = metrics
+	}
+	metrics.Labels = labelsCopy
+}
+
+// RemovePipelineMetrics discards the metrics of a pipeline that no longer exists
+func (c *Collector) RemovePipelineMetrics(pipelineName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.pipelineMetrics, pipelineName)
+	c.removeEndpointLatencies(pipelineName)
+	c.updateHealthStatus()
 }
 
 // RecordConfigReload records a configuration reload event
@@ -182,6 +354,21 @@ func (c *Collector) RecordConfigReload() {
 	defer c.mutex.Unlock()
 
 	c.systemMetrics.LastConfigReload = time.Now()
+	c.systemMetrics.ConfigReloadsTotal++
+}
+
+// RecordConfigReloadFailure records a configuration reload that could not be applied
+func (c *Collector) RecordConfigReloadFailure(err error) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.systemMetrics.ConfigReloadFailuresTotal++
+	c.systemMetrics.LastConfigReloadError = err.Error()
+	c.systemMetrics.LastConfigReloadErrorTime = time.Now()
 }
 
 // GetPipelineMetrics returns metrics for a specific pipeline
@@ -233,9 +420,10 @@ func (c *Collector) GetSystemMetrics() *SystemMetrics {
 	return &metricsCopy
 }
 
-// collectSystemMetrics periodically collects system-level metrics
-func (c *Collector) collectSystemMetrics() {
-	ticker := time.NewTicker(c.config.Interval)
+// collectSystemMetrics periodically collects system-level metrics; the interval is passed in
+// so the goroutine never reads the config UpdateConfig replaces
+func (c *Collector) collectSystemMetrics(interval time.Duration) {
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for range ticker.C {
@@ -243,6 +431,48 @@ func (c *Collector) collectSystemMetrics() {
 	}
 }
 
+// startHeartbeat starts advancing the heartbeat counter every heartbeat_interval;
+// callers must hold the collector mutex or own the collector exclusively
+func (c *Collector) startHeartbeat() {
+	if c.config.HeartbeatInterval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	c.heartbeatStop = stop
+
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.RecordHeartbeat()
+			case <-stop:
+				return
+			}
+		}
+	}(c.config.HeartbeatInterval)
+}
+
+// stopHeartbeat stops the heartbeat goroutine; callers must hold the collector mutex
+func (c *Collector) stopHeartbeat() {
+	if c.heartbeatStop != nil {
+		close(c.heartbeatStop)
+		c.heartbeatStop = nil
+	}
+}
+
+// RecordHeartbeat advances the heartbeat counter
+func (c *Collector) RecordHeartbeat() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.systemMetrics.HeartbeatsTotal++
+	c.systemMetrics.LastHeartbeat = time.Now()
+}
+
 // updateSystemMetrics updates system-level metrics
 func (c *Collector) updateSystemMetrics() {
 	var memStats runtime.MemStats
@@ -268,6 +498,10 @@ func (c *Collector) startHTTPServer() {
 	mux.HandleFunc(c.config.Path, c.handleMetricsRequest)
 	mux.HandleFunc(c.config.Path+"/pipeline/", c.handlePipelineMetricsRequest)
 	mux.HandleFunc(c.config.Path+"/system", c.handleSystemMetricsRequest)
+	mux.HandleFunc(c.config.Path+"/prometheus", c.handlePrometheusRequest)
+	mux.HandleFunc("/readyz", c.handleReadyRequest)
+	mux.HandleFunc("GET /pipelines", c.handlePipelinesRequest)
+	mux.HandleFunc("GET /debug/last-response/{pipeline}/{index}", c.handleLastResponseRequest)
 
 	c.httpServer = &http.Server{
 		Addr:    fmt.Sprintf(":%d", c.config.Port),
@@ -276,7 +510,7 @@ func (c *Collector) startHTTPServer() {
 
 	go func() {
 		if err := c.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Printf("Metrics server error: %v\n", err)
+			log.Printf("Metrics server error: %v", err)
 		}
 	}()
 }
@@ -288,6 +522,7 @@ func (c *Collector) handleMetricsRequest(w http.ResponseWriter, r *http.Request)
 	response := map[string]interface{}{
 		"system":    c.GetSystemMetrics(),
 		"pipelines": c.GetAllPipelineMetrics(),
+		"endpoints": c.GetEndpointLatencies(),
 	}
 
 	if err := writeJSONResponse(w, response); err != nil {
@@ -333,10 +568,24 @@ func writeJSONResponse(w http.ResponseWriter, data interface{}) error {
 	return encoder.Encode(data)
 }
 
+// defaultShutdownTimeout bounds how long Close waits for in-flight metrics requests
+const defaultShutdownTimeout = 5 * time.Second
+
 // Close stops the metrics collector
 func (c *Collector) Close() error {
+	return c.Shutdown(defaultShutdownTimeout)
+}
+
+// Shutdown stops the metrics collector, waiting up to timeout for in-flight requests
+func (c *Collector) Shutdown(timeout time.Duration) error {
+	c.mutex.Lock()
+	c.closing = true
+	c.stopGRPCHealthServer()
+	c.stopHeartbeat()
+	c.mutex.Unlock()
+
 	if c.httpServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		return c.httpServer.Shutdown(ctx)
 	}
@@ -352,7 +601,11 @@ func (c *Collector) UpdateConfig(cfg config.MetricsConfig) error {
 	if !c.config.Enabled && cfg.Enabled {
 		c.config = cfg
 		c.startHTTPServer()
-		go c.collectSystemMetrics()
+		if cfg.GRPCHealth.Enabled {
+			c.startGRPCHealthServer()
+		}
+		go c.collectSystemMetrics(cfg.Interval)
+		c.startHeartbeat()
 		return nil
 	}
 
@@ -363,6 +616,27 @@ func (c *Collector) UpdateConfig(cfg config.MetricsConfig) error {
 			defer cancel()
 			c.httpServer.Shutdown(ctx)
 		}
+		c.stopGRPCHealthServer()
+		c.stopHeartbeat()
+	}
+
+	// Apply heartbeat and gRPC health server changes while metrics stay enabled
+	if c.config.Enabled && cfg.Enabled {
+		restartHeartbeat := cfg.HeartbeatInterval != c.config.HeartbeatInterval
+		// A new port needs a new listener, so the running server is replaced
+		restartGRPCHealth := cfg.GRPCHealth.Port != c.config.GRPCHealth.Port
+		c.config = cfg
+		if restartHeartbeat {
+			c.stopHeartbeat()
+			c.startHeartbeat()
+		}
+		if cfg.GRPCHealth.Enabled && (c.grpcServer == nil || restartGRPCHealth) {
+			c.stopGRPCHealthServer()
+			c.startGRPCHealthServer()
+		} else if !cfg.GRPCHealth.Enabled {
+			c.stopGRPCHealthServer()
+		}
+		return nil
 	}
 
 	c.config = cfg
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestConfigReloadCounters(t *testing.T) {
+	// Port 0 lets the metrics server bind any free port
+	collector := NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute})
+	defer collector.Close()
+
+	collector.RecordConfigReload()
+	collector.RecordConfigReload()
+	collector.RecordConfigReloadFailure(errors.New("interval must be positive"))
+
+	system := collector.GetSystemMetrics()
+	if system.ConfigReloadsTotal != 2 || system.ConfigReloadFailuresTotal != 1 {
+		t.Errorf("reloads = %d, failures = %d, want 2 and 1", system.ConfigReloadsTotal, system.ConfigReloadFailuresTotal)
+	}
+	if system.LastConfigReloadError != "interval must be positive" || system.LastConfigReloadErrorTime.IsZero() {
+		t.Errorf("last reload error = %q at %v", system.LastConfigReloadError, system.LastConfigReloadErrorTime)
+	}
+
+	var output strings.Builder
+	collector.WritePrometheus(&output)
+	for _, line := range []string{"elasticetl_config_reloads_total 2\n", "elasticetl_config_reload_failures_total 1\n"} {
+		if !strings.Contains(output.String(), line) {
+			t.Errorf("exposition output lacks %q", line)
+		}
+	}
+}
+
+func TestHeartbeatAdvances(t *testing.T) {
+	// Port 0 lets the metrics server bind any free port
+	collector := NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute, HeartbeatInterval: 10 * time.Millisecond})
+	defer collector.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for collector.GetSystemMetrics().HeartbeatsTotal < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("heartbeats stuck at %d with no pipeline running", collector.GetSystemMetrics().HeartbeatsTotal)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if collector.GetSystemMetrics().LastHeartbeat.IsZero() {
+		t.Error("LastHeartbeat not recorded")
+	}
+
+	// Disabling the heartbeat on reload stops the counter
+	if err := collector.UpdateConfig(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond) // Let a tick already in flight land
+	stopped := collector.GetSystemMetrics().HeartbeatsTotal
+	time.Sleep(50 * time.Millisecond)
+	if got := collector.GetSystemMetrics().HeartbeatsTotal; got != stopped {
+		t.Errorf("heartbeats advanced from %d to %d after being disabled", stopped, got)
+	}
+}
+
+func TestShutdownBoundsInFlightRequests(t *testing.T) {
+	port := freePort(t)
+	collector := NewCollector(config.MetricsConfig{Enabled: true, Port: port, Path: "/metrics", Interval: time.Minute, DebugToken: "secret"})
+
+	// A debug request that stays in flight until the test ends
+	release := make(chan struct{})
+	defer close(release)
+	inFlight := make(chan struct{}, 1)
+	collector.SetLastResponseProvider(func(string, int) (*LastResponse, bool) {
+		inFlight <- struct{}{}
+		<-release
+		return nil, false
+	})
+
+	go func() {
+		for i := 0; i < 100; i++ {
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://127.0.0.1:%d/debug/last-response/p/0", port), nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			if resp, err := http.DefaultClient.Do(req); err == nil {
+				resp.Body.Close()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	select {
+	case <-inFlight:
+	case <-time.After(5 * time.Second):
+		t.Fatal("debug request never reached the server")
+	}
+
+	started := time.Now()
+	if err := collector.Shutdown(50 * time.Millisecond); err == nil {
+		t.Error("Shutdown returned no error with a request still in flight")
+	}
+	if elapsed := time.Since(started); elapsed >= time.Second {
+		t.Errorf("Shutdown took %v, want it bounded by the 50ms timeout", elapsed)
+	}
+}
+
+func TestRecordTransformStats(t *testing.T) {
+	collector := newTestCollector(t)
+	collector.RecordPipelineStart("ingest")
+	collector.RecordTransformStats("ingest", TransformStats{InputRecords: 2, OutputRecords: 2, RowsGenerated: 4, FieldsDropped: 1, FieldsConverted: 2})
+	collector.RecordTransformStats("ingest", TransformStats{InputRecords: 1, OutputRecords: 1, RowsGenerated: 1})
+
+	// The last run is kept as is and added to the pipeline's totals
+	metrics := collector.GetPipelineMetrics("ingest")
+	if want := (TransformStats{InputRecords: 1, OutputRecords: 1, RowsGenerated: 1}); metrics.LastTransform != want {
+		t.Errorf("last transform = %+v, want %+v", metrics.LastTransform, want)
+	}
+	if want := (TransformStats{InputRecords: 3, OutputRecords: 3, RowsGenerated: 5, FieldsDropped: 1, FieldsConverted: 2}); metrics.TransformTotals != want {
+		t.Errorf("transform totals = %+v, want %+v", metrics.TransformTotals, want)
+	}
+
+	output := exposition(collector)
+	for _, line := range []string{
+		`elasticetl_pipeline_transform_rows_generated_total{pipeline="ingest"} 5`,
+		`elasticetl_pipeline_transform_fields_dropped_total{pipeline="ingest"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("exposition output lacks %s", line)
+		}
+	}
+}
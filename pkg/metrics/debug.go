@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LastResponse is a raw endpoint response exposed on the debug endpoint
+type LastResponse struct {
+	StatusCode int
+	Body       []byte
+	Truncated  bool
+	CapturedAt time.Time
+}
+
+// LastResponseFunc looks up the latest response captured for a pipeline endpoint
+type LastResponseFunc func(pipeline string, index int) (*LastResponse, bool)
+
+// SetLastResponseProvider sets the lookup used by /debug/last-response/{pipeline}/{index}
+func (c *Collector) SetLastResponseProvider(provider LastResponseFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.lastResponse = provider
+}
+
+// authorizeDebug checks the request's bearer token against debug_token. Debug endpoints
+// are unavailable when no token is configured
+func (c *Collector) authorizeDebug(w http.ResponseWriter, r *http.Request) bool {
+	c.mutex.RLock()
+	token := os.ExpandEnv(c.config.DebugToken)
+	c.mutex.RUnlock()
+
+	if token == "" {
+		http.NotFound(w, r)
+		return false
+	}
+
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// handleLastResponseRequest serves the last raw response captured for a pipeline endpoint
+func (c *Collector) handleLastResponseRequest(w http.ResponseWriter, r *http.Request) {
+	if !c.authorizeDebug(w, r) {
+		return
+	}
+
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		http.Error(w, "Endpoint index must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+
+	c.mutex.RLock()
+	provider := c.lastResponse
+	c.mutex.RUnlock()
+
+	if provider == nil {
+		http.Error(w, "No response captured", http.StatusNotFound)
+		return
+	}
+
+	response, ok := provider(r.PathValue("pipeline"), index)
+	if !ok {
+		http.Error(w, "No response captured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Upstream-Status", strconv.Itoa(response.StatusCode))
+	w.Header().Set("X-Captured-At", response.CapturedAt.Format(time.RFC3339))
+	w.Header().Set("X-Truncated", fmt.Sprintf("%t", response.Truncated))
+	w.Write(response.Body)
+}
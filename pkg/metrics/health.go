@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// IsReady reports whether ElasticETL is ready, i.e. it is not shutting down and
+// at least one pipeline is enabled
+func (c *Collector) IsReady() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.isReady()
+}
+
+// isReady implements IsReady; callers must hold the collector mutex
+func (c *Collector) isReady() bool {
+	if c.closing {
+		return false
+	}
+
+	for _, metrics := range c.pipelineMetrics {
+		if metrics.Enabled {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleReadyRequest handles readiness probes
+func (c *Collector) handleReadyRequest(w http.ResponseWriter, r *http.Request) {
+	if !c.IsReady() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ready")
+}
+
+// startGRPCHealthServer starts the grpc.health.v1.Health server on the configured port
+func (c *Collector) startGRPCHealthServer() {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", c.config.GRPCHealth.Port))
+	if err != nil {
+		log.Printf("gRPC health server error: %v", err)
+		return
+	}
+
+	c.healthServer = health.NewServer()
+	c.grpcServer = grpc.NewServer()
+	healthpb.RegisterHealthServer(c.grpcServer, c.healthServer)
+	c.updateHealthStatus()
+
+	go func(server *grpc.Server) {
+		if err := server.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			log.Printf("gRPC health server error: %v", err)
+		}
+	}(c.grpcServer)
+}
+
+// updateHealthStatus publishes the readiness state to the gRPC health server;
+// callers must hold the collector mutex
+func (c *Collector) updateHealthStatus() {
+	if c.healthServer == nil {
+		return
+	}
+
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if c.isReady() {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	c.healthServer.SetServingStatus("", status)
+}
+
+// stopGRPCHealthServer reports NOT_SERVING to watchers and stops the gRPC health server
+func (c *Collector) stopGRPCHealthServer() {
+	if c.grpcServer == nil {
+		return
+	}
+
+	c.healthServer.Shutdown()
+	c.grpcServer.Stop()
+	c.healthServer = nil
+	c.grpcServer = nil
+}
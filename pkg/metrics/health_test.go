@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// freePort returns a TCP port that was free when checked
+func freePort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// checkHealth reports whether a gRPC health server answers on port
+func checkHealth(port int) error {
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestUpdateConfigGRPCHealth(t *testing.T) {
+	oldPort, newPort := freePort(t), freePort(t)
+	tests := []struct {
+		name     string
+		health   config.GRPCHealthConfig
+		wantPort int // 0 means no server should answer
+	}{
+		{"unchanged", config.GRPCHealthConfig{Enabled: true, Port: oldPort}, oldPort},
+		{"port changed", config.GRPCHealthConfig{Enabled: true, Port: newPort}, newPort},
+		{"disabled", config.GRPCHealthConfig{Enabled: false, Port: oldPort}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.MetricsConfig{Enabled: true, Path: "/metrics", Interval: time.Minute, GRPCHealth: config.GRPCHealthConfig{Enabled: true, Port: oldPort}}
+			collector := NewCollector(cfg)
+			defer collector.Close()
+
+			cfg.GRPCHealth = tt.health
+			if err := collector.UpdateConfig(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, port := range []int{oldPort, newPort} {
+				err := checkHealth(port)
+				if port == tt.wantPort && err != nil {
+					t.Errorf("no health server on port %d: %v", port, err)
+				} else if port != tt.wantPort && err == nil {
+					t.Errorf("health server still answering on port %d", port)
+				}
+			}
+		})
+	}
+}
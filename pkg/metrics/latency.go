@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the endpoint latency histogram buckets
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// EndpointLatency is the request latency histogram of a single pipeline endpoint
+type EndpointLatency struct {
+	Pipeline    string    `json:"pipeline"`
+	ClusterName string    `json:"cluster_name"`
+	URL         string    `json:"url"`
+	Buckets     []float64 `json:"buckets"`       // Upper bounds in seconds
+	Counts      []int64   `json:"bucket_counts"` // Cumulative observations per bucket
+	Count       int64     `json:"count"`
+	SumSeconds  float64   `json:"sum_seconds"`
+}
+
+// endpointKey identifies an endpoint's latency histogram
+type endpointKey struct {
+	pipeline    string
+	clusterName string
+	url         string
+}
+
+// endpointLabel returns rawURL without userinfo or query, which may carry credentials, for
+// use as a label
+func endpointLabel(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "invalid"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+// ObserveEndpointLatency records the duration of an extraction request to one endpoint
+func (c *Collector) ObserveEndpointLatency(pipelineName, clusterName, rawURL string, duration time.Duration) {
+	if !c.config.Enabled {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	url := endpointLabel(rawURL)
+	key := endpointKey{pipeline: pipelineName, clusterName: clusterName, url: url}
+	histogram, exists := c.endpointLatencies[key]
+	if !exists {
+		histogram = &EndpointLatency{
+			Pipeline:    pipelineName,
+			ClusterName: clusterName,
+			URL:         url,
+			Buckets:     latencyBuckets,
+			Counts:      make([]int64, len(latencyBuckets)),
+		}
+		c.endpointLatencies[key] = histogram
+	}
+
+	seconds := duration.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			histogram.Counts[i]++
+		}
+	}
+	histogram.Count++
+	histogram.SumSeconds += seconds
+}
+
+// GetEndpointLatencies returns the latency histograms of all endpoints ordered by pipeline,
+// cluster and URL
+func (c *Collector) GetEndpointLatencies() []EndpointLatency {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	latencies := make([]EndpointLatency, 0, len(c.endpointLatencies))
+	for _, histogram := range c.endpointLatencies {
+		latency := *histogram
+		latency.Counts = append([]int64(nil), histogram.Counts...)
+		latencies = append(latencies, latency)
+	}
+
+	sort.Slice(latencies, func(i, j int) bool {
+		a, b := latencies[i], latencies[j]
+		if a.Pipeline != b.Pipeline {
+			return a.Pipeline < b.Pipeline
+		}
+		if a.ClusterName != b.ClusterName {
+			return a.ClusterName < b.ClusterName
+		}
+		return a.URL < b.URL
+	})
+
+	return latencies
+}
+
+// removeEndpointLatencies discards a pipeline's histograms; callers must hold the collector mutex
+func (c *Collector) removeEndpointLatencies(pipelineName string) {
+	for key := range c.endpointLatencies {
+		if key.pipeline == pipelineName {
+			delete(c.endpointLatencies, key)
+		}
+	}
+}
+
+// PruneEndpointLatencies discards a pipeline's histograms for endpoints other than the given
+// clusters and their URLs, so reconfigured pipelines stop exporting series for endpoints they
+// no longer query
+func (c *Collector) PruneEndpointLatencies(pipelineName string, clusterNames, urls []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	configured := make(map[endpointKey]bool, len(urls))
+	for i, rawURL := range urls {
+		key := endpointKey{pipeline: pipelineName, url: endpointLabel(rawURL)}
+		if i < len(clusterNames) {
+			key.clusterName = clusterNames[i]
+		}
+		configured[key] = true
+	}
+	for key := range c.endpointLatencies {
+		if key.pipeline == pipelineName && !configured[key] {
+			delete(c.endpointLatencies, key)
+		}
+	}
+}
+
+// writeLatencyHistograms writes the endpoint latency histograms in the Prometheus text format
+func writeLatencyHistograms(w io.Writer, latencies []EndpointLatency, pipelines map[string]*PipelineMetrics) {
+	const name = "elasticetl_extract_request_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Extraction request latency per endpoint.\n# TYPE %s histogram\n", name, name)
+
+	for _, latency := range latencies {
+		labels := map[string]string{"pipeline": latency.Pipeline}
+		if m, ok := pipelines[latency.Pipeline]; ok {
+			labels = pipelineLabels(m)
+		}
+		labels["cluster"] = latency.ClusterName
+		labels["url"] = latency.URL
+
+		for i, bound := range latency.Buckets {
+			labels["le"] = formatValue(bound)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels), latency.Counts[i])
+		}
+		labels["le"] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(labels), latency.Count)
+		delete(labels, "le")
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, formatLabels(labels), formatValue(latency.SumSeconds))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(labels), latency.Count)
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"plain", "https://es:9200", "https://es:9200"},
+		{"userinfo", "https://elastic:changeme@es:9200/logs-*/_search", "https://es:9200/logs-*/_search"},
+		{"query", "http://es:9200/_search?api_key=secret", "http://es:9200/_search"},
+		{"invalid", "http://es:9200/%zz", "invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := endpointLabel(tt.url); got != tt.want {
+				t.Errorf("endpointLabel(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+// newLatencyCollector returns an enabled collector without background servers
+func newLatencyCollector() *Collector {
+	return &Collector{
+		config:            config.MetricsConfig{Enabled: true},
+		pipelineMetrics:   make(map[string]*PipelineMetrics),
+		endpointLatencies: make(map[endpointKey]*EndpointLatency),
+	}
+}
+
+func TestLatencyHistogramOmitsCredentials(t *testing.T) {
+	collector := newLatencyCollector()
+	collector.ObserveEndpointLatency("p", "prod", "https://elastic:changeme@es:9200?token=abc", 30*time.Millisecond)
+
+	var out bytes.Buffer
+	writeLatencyHistograms(&out, collector.GetEndpointLatencies(), nil)
+	for _, secret := range []string{"changeme", "elastic:", "abc"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("histogram output leaks %q:\n%s", secret, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), `url="https://es:9200"`) {
+		t.Errorf("histogram output lacks the redacted URL:\n%s", out.String())
+	}
+}
+
+func TestPruneEndpointLatencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		clusterNames []string
+		urls         []string
+		want         []string // Remaining "pipeline/cluster/url" histograms
+	}{
+		{"unchanged", []string{"a", "b"}, []string{"http://a:9200", "http://b:9200"}, []string{"p/a/http://a:9200", "p/b/http://b:9200", "q/a/http://a:9200"}},
+		{"url removed", []string{"a"}, []string{"http://a:9200"}, []string{"p/a/http://a:9200", "q/a/http://a:9200"}},
+		{"cluster renamed", []string{"c", "b"}, []string{"http://a:9200", "http://b:9200"}, []string{"p/b/http://b:9200", "q/a/http://a:9200"}},
+		{"credentials added", []string{"a", "b"}, []string{"http://u:pw@a:9200", "http://b:9200"}, []string{"p/a/http://a:9200", "p/b/http://b:9200", "q/a/http://a:9200"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := newLatencyCollector()
+			collector.ObserveEndpointLatency("p", "a", "http://a:9200", time.Millisecond)
+			collector.ObserveEndpointLatency("p", "b", "http://b:9200", time.Millisecond)
+			collector.ObserveEndpointLatency("q", "a", "http://a:9200", time.Millisecond)
+
+			collector.PruneEndpointLatencies("p", tt.clusterNames, tt.urls)
+
+			var got []string
+			for _, latency := range collector.GetEndpointLatencies() {
+				got = append(got, latency.Pipeline+"/"+latency.ClusterName+"/"+latency.URL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("remaining histograms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// PipelineInfo describes a configured pipeline for the /pipelines listing
+type PipelineInfo struct {
+	Name     string        `json:"name"`
+	Enabled  bool          `json:"enabled"`
+	Interval time.Duration `json:"-"`
+	Running  bool          `json:"running"`
+}
+
+// PipelineListFunc returns the currently configured pipelines
+type PipelineListFunc func() []PipelineInfo
+
+// pipelineListing is one entry of the /pipelines response
+type pipelineListing struct {
+	PipelineInfo
+	Interval string              `json:"interval"`
+	LastRun  *pipelineRunSummary `json:"last_run,omitempty"`
+}
+
+// pipelineRunSummary summarizes a pipeline's runs from its collected metrics
+type pipelineRunSummary struct {
+	Time           time.Time `json:"time"`
+	Duration       string    `json:"duration"`
+	TotalRuns      int64     `json:"total_runs"`
+	SuccessfulRuns int64     `json:"successful_runs"`
+	FailedRuns     int64     `json:"failed_runs"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// SetPipelineListProvider sets the lookup used by /pipelines
+func (c *Collector) SetPipelineListProvider(provider PipelineListFunc) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pipelineList = provider
+}
+
+// handlePipelinesRequest lists the configured pipelines with their interval, state and last run
+func (c *Collector) handlePipelinesRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c.mutex.RLock()
+	provider := c.pipelineList
+	c.mutex.RUnlock()
+
+	var pipelines []PipelineInfo
+	if provider != nil {
+		pipelines = provider()
+	}
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+
+	c.mutex.RLock()
+	listing := make([]pipelineListing, 0, len(pipelines))
+	for _, info := range pipelines {
+		entry := pipelineListing{
+			PipelineInfo: info,
+			Interval:     info.Interval.String(),
+		}
+		if m, exists := c.pipelineMetrics[info.Name]; exists && m.TotalRuns > 0 {
+			entry.LastRun = &pipelineRunSummary{
+				Time:           m.LastRun,
+				Duration:       m.LastDuration.String(),
+				TotalRuns:      m.TotalRuns,
+				SuccessfulRuns: m.SuccessfulRuns,
+				FailedRuns:     m.FailedRuns,
+				LastError:      m.LastError,
+			}
+		}
+		listing = append(listing, entry)
+	}
+	c.mutex.RUnlock()
+
+	if err := writeJSONResponse(w, listing); err != nil {
+		http.Error(w, "Failed to encode pipelines", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlePipelinesRequest(t *testing.T) {
+	collector := newTestCollector(t)
+	collector.SetPipelineListProvider(func() []PipelineInfo {
+		return []PipelineInfo{
+			{Name: "logs", Enabled: false, Interval: 5 * time.Minute},
+			{Name: "health", Enabled: true, Interval: 30 * time.Second, Running: true},
+		}
+	})
+	collector.RecordPipelineStart("health")
+	collector.RecordPipelineFailure("health", 2*time.Second, errors.New("timeout"))
+
+	recorder := httptest.NewRecorder()
+	collector.handlePipelinesRequest(recorder, httptest.NewRequest(http.MethodGet, "/pipelines", nil))
+
+	var listing []struct {
+		Name     string `json:"name"`
+		Enabled  bool   `json:"enabled"`
+		Interval string `json:"interval"`
+		Running  bool   `json:"running"`
+		LastRun  *struct {
+			Duration   string `json:"duration"`
+			TotalRuns  int64  `json:"total_runs"`
+			FailedRuns int64  `json:"failed_runs"`
+			LastError  string `json:"last_error"`
+		} `json:"last_run"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("response %s: %v", recorder.Body, err)
+	}
+	if len(listing) != 2 {
+		t.Fatalf("listed %d pipelines, want 2", len(listing))
+	}
+
+	// Sorted by name; only a pipeline that has run reports a last run
+	health, logs := listing[0], listing[1]
+	if health.Name != "health" || !health.Enabled || !health.Running || health.Interval != "30s" {
+		t.Errorf("health = %+v", health)
+	}
+	if health.LastRun == nil || health.LastRun.TotalRuns != 1 || health.LastRun.FailedRuns != 1 || health.LastRun.LastError != "timeout" || health.LastRun.Duration != "2s" {
+		t.Errorf("health last run = %+v", health.LastRun)
+	}
+	if logs.Name != "logs" || logs.Enabled || logs.Running || logs.Interval != "5m0s" || logs.LastRun != nil {
+		t.Errorf("logs = %+v", logs)
+	}
+}
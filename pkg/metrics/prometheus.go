@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// handlePrometheusRequest serves pipeline and system metrics in the Prometheus text exposition format
+func (c *Collector) handlePrometheusRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WritePrometheus(w)
+}
+
+// WritePrometheus writes pipeline and system metrics in the Prometheus text exposition format.
+// Pipeline series carry a pipeline label plus the pipeline's metric_labels
+func (c *Collector) WritePrometheus(w io.Writer) {
+	pipelines := c.GetAllPipelineMetrics()
+	system := c.GetSystemMetrics()
+
+	names := make([]string, 0, len(pipelines))
+	for name := range pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pipelineFamilies := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(m *PipelineMetrics) float64
+	}{
+		{"elasticetl_pipeline_enabled", "gauge", "Whether the pipeline is enabled and running.", func(m *PipelineMetrics) float64 { return boolValue(m.Enabled) }},
+		{"elasticetl_pipeline_runs_total", "counter", "Pipeline executions started.", func(m *PipelineMetrics) float64 { return float64(m.TotalRuns) }},
+		{"elasticetl_pipeline_runs_successful_total", "counter", "Pipeline executions that succeeded.", func(m *PipelineMetrics) float64 { return float64(m.SuccessfulRuns) }},
+		{"elasticetl_pipeline_runs_failed_total", "counter", "Pipeline executions that failed.", func(m *PipelineMetrics) float64 { return float64(m.FailedRuns) }},
+		{"elasticetl_pipeline_entries_processed_total", "counter", "Entries processed by the pipeline.", func(m *PipelineMetrics) float64 { return float64(m.EntriesProcessed) }},
+		{"elasticetl_pipeline_bytes_processed_total", "counter", "Bytes processed by the pipeline.", func(m *PipelineMetrics) float64 { return float64(m.BytesProcessed) }},
+		{"elasticetl_pipeline_entries_delivered_total", "counter", "Entries loaded to a stream other than debug.", func(m *PipelineMetrics) float64 { return float64(m.EntriesDelivered) }},
+		{"elasticetl_pipeline_bytes_delivered_total", "counter", "Bytes loaded to a stream other than debug.", func(m *PipelineMetrics) float64 { return float64(m.BytesDelivered) }},
+		{"elasticetl_pipeline_last_duration_seconds", "gauge", "Duration of the last pipeline execution.", func(m *PipelineMetrics) float64 { return m.LastDuration.Seconds() }},
+		{"elasticetl_pipeline_last_run_timestamp_seconds", "gauge", "Start time of the last pipeline execution.", lastRunSeconds},
+		{"elasticetl_pipeline_load_queue_depth", "gauge", "Batches waiting for the background loader.", func(m *PipelineMetrics) float64 { return float64(m.LoadQueueDepth) }},
+		{"elasticetl_pipeline_dropped_batches_total", "counter", "Batches dropped because the load queue was full.", func(m *PipelineMetrics) float64 { return float64(m.DroppedBatches) }},
+		{"elasticetl_pipeline_dropped_series_total", "counter", "Series dropped by max_series_per_run.", func(m *PipelineMetrics) float64 { return float64(m.DroppedSeries) }},
+		{"elasticetl_pipeline_transform_input_records_total", "counter", "Records passed to the transformer.", func(m *PipelineMetrics) float64 { return float64(m.TransformTotals.InputRecords) }},
+		{"elasticetl_pipeline_transform_output_records_total", "counter", "Records produced by the transformer.", func(m *PipelineMetrics) float64 { return float64(m.TransformTotals.OutputRecords) }},
+		{"elasticetl_pipeline_transform_rows_generated_total", "counter", "CSV rows generated by the transformer.", func(m *PipelineMetrics) float64 { return float64(m.TransformTotals.RowsGenerated) }},
+		{"elasticetl_pipeline_transform_fields_dropped_total", "counter", "Extracted fields dropped by the transformer.", func(m *PipelineMetrics) float64 { return float64(m.TransformTotals.FieldsDropped) }},
+		{"elasticetl_pipeline_transform_fields_converted_total", "counter", "Extracted fields whose value the transformer changed.", func(m *PipelineMetrics) float64 { return float64(m.TransformTotals.FieldsConverted) }},
+	}
+
+	for _, family := range pipelineFamilies {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+		for _, name := range names {
+			m := pipelines[name]
+			fmt.Fprintf(w, "%s%s %s\n", family.name, formatLabels(pipelineLabels(m)), formatValue(family.value(m)))
+		}
+	}
+
+	systemFamilies := []struct {
+		name  string
+		kind  string
+		help  string
+		value float64
+	}{
+		{"elasticetl_uptime_seconds", "gauge", "Time since ElasticETL started.", system.Uptime.Seconds()},
+		{"elasticetl_pipelines", "gauge", "Configured pipelines.", float64(system.TotalPipelines)},
+		{"elasticetl_active_pipelines", "gauge", "Enabled pipelines.", float64(system.ActivePipelines)},
+		{"elasticetl_goroutines", "gauge", "Goroutines in the process.", float64(system.TotalGoroutines)},
+		{"elasticetl_memory_used_bytes", "gauge", "Heap memory in use.", system.UsedMemoryMB * 1024 * 1024},
+		{"elasticetl_config_reloads_total", "counter", "Configuration reloads applied.", float64(system.ConfigReloadsTotal)},
+		{"elasticetl_config_reload_failures_total", "counter", "Configuration reloads that failed.", float64(system.ConfigReloadFailuresTotal)},
+		{"elasticetl_heartbeats_total", "counter", "Heartbeats emitted independently of pipeline runs.", float64(system.HeartbeatsTotal)},
+	}
+
+	writeLatencyHistograms(w, c.GetEndpointLatencies(), pipelines)
+
+	for _, family := range systemFamilies {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", family.name, family.help, family.name, family.kind, family.name, formatValue(family.value))
+	}
+
+	c.mutex.RLock()
+	runtimeMetrics := c.config.RuntimeMetrics
+	c.mutex.RUnlock()
+	if runtimeMetrics {
+		writeRuntimeMetrics(w)
+	}
+}
+
+// pipelineLabels returns the labels of a pipeline's series: its metric_labels plus pipeline
+func pipelineLabels(m *PipelineMetrics) map[string]string {
+	labels := make(map[string]string, len(m.Labels)+1)
+	for name, value := range m.Labels {
+		labels[name] = value
+	}
+	labels["pipeline"] = m.Name
+	return labels
+}
+
+// formatLabels renders labels sorted by name as {a="1",b="2"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escapeLabelValue(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelValueEscaper escapes label values for the text exposition format
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabelValue escapes backslashes, quotes and newlines in a label value
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// boolValue converts a bool to 1 or 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// lastRunSeconds returns the pipeline's last run as Unix seconds, or 0 if it has not run
+func lastRunSeconds(m *PipelineMetrics) float64 {
+	if m.LastRun.IsZero() {
+		return 0
+	}
+	return float64(m.LastRun.Unix())
+}
+
+// formatValue renders a sample value without exponent notation where possible
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
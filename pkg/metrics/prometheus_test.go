@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+// newTestCollector creates an enabled collector whose metrics server binds any free port
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+	collector := NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute})
+	t.Cleanup(func() { collector.Close() })
+	return collector
+}
+
+// exposition returns the collector's Prometheus text exposition output
+func exposition(c *Collector) string {
+	var output strings.Builder
+	c.WritePrometheus(&output)
+	return output.String()
+}
+
+func TestWritePrometheusPipelineLabels(t *testing.T) {
+	collector := newTestCollector(t)
+	collector.RecordPipelineStart("ingest")
+	collector.SetPipelineLabels("ingest", map[string]string{"team": "search", "owner": `a"b`})
+	collector.RecordPipelineStart("other")
+
+	output := exposition(collector)
+	for _, line := range []string{
+		`elasticetl_pipeline_runs_total{owner="a\"b",pipeline="ingest",team="search"} 1`,
+		`elasticetl_pipeline_runs_total{pipeline="other"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("exposition output lacks %s", line)
+		}
+	}
+
+	// Labels are replaced, not merged, when the pipeline's config changes
+	collector.SetPipelineLabels("ingest", map[string]string{"team": "platform"})
+	if line := `elasticetl_pipeline_runs_total{pipeline="ingest",team="platform"} 1`; !strings.Contains(exposition(collector), line+"\n") {
+		t.Errorf("exposition output lacks %s after relabelling", line)
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ShutdownReport summarizes the collected metrics of every pipeline
+func (c *Collector) ShutdownReport() string {
+	system := c.GetSystemMetrics()
+	return BuildShutdownReport(c.GetAllPipelineMetrics(), system.Uptime)
+}
+
+// BuildShutdownReport formats per-pipeline run counts and last errors, ordered by pipeline name
+func BuildShutdownReport(pipelines map[string]*PipelineMetrics, uptime time.Duration) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "ElasticETL shutdown report\n")
+	fmt.Fprintf(&b, "Uptime: %s\n", uptime.Round(time.Second))
+	fmt.Fprintf(&b, "Pipelines: %d\n", len(pipelines))
+
+	names := make([]string, 0, len(pipelines))
+	for name := range pipelines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		m := pipelines[name]
+		fmt.Fprintf(&b, "  %s: total=%d successful=%d failed=%d dropped_batches=%d dropped_series=%d entries=%d bytes=%d\n",
+			name, m.TotalRuns, m.SuccessfulRuns, m.FailedRuns, m.DroppedBatches, m.DroppedSeries, m.EntriesProcessed, m.BytesProcessed)
+		if m.LastError != "" {
+			fmt.Fprintf(&b, "    last error (%s): %s\n", m.LastErrorTime.Format(time.RFC3339), m.LastError)
+		}
+	}
+
+	return b.String()
+}
+
+// WriteShutdownReport writes the report to path, creating its directory if needed
+func WriteShutdownReport(path, report string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		return fmt.Errorf("failed to write shutdown report: %w", err)
+	}
+	return nil
+}
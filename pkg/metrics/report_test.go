@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildShutdownReport(t *testing.T) {
+	pipelines := map[string]*PipelineMetrics{
+		"ingest": {
+			TotalRuns:        5,
+			SuccessfulRuns:   4,
+			FailedRuns:       1,
+			EntriesProcessed: 120,
+			BytesProcessed:   4096,
+			LastError:        "HTTP 503: unavailable",
+			LastErrorTime:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+		"health": {TotalRuns: 2, SuccessfulRuns: 2, DroppedBatches: 1},
+	}
+
+	want := "ElasticETL shutdown report\n" +
+		"Uptime: 1h0m2s\n" +
+		"Pipelines: 2\n" +
+		"  health: total=2 successful=2 failed=0 dropped_batches=1 dropped_series=0 entries=0 bytes=0\n" +
+		"  ingest: total=5 successful=4 failed=1 dropped_batches=0 dropped_series=0 entries=120 bytes=4096\n" +
+		"    last error (2024-03-01T12:00:00Z): HTTP 503: unavailable\n"
+	if got := BuildShutdownReport(pipelines, time.Hour+1600*time.Millisecond); got != want {
+		t.Errorf("report =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteShutdownReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "shutdown.txt")
+	if err := WriteShutdownReport(path, "report\n"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "report\n" {
+		t.Errorf("file contents = %q", data)
+	}
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procUserHZ is the kernel clock tick rate used for CPU times in /proc/self/stat
+const procUserHZ = 100
+
+// writeRuntimeMetrics writes the standard go_* runtime and process_* metrics, named as the
+// Prometheus client library's Go and process collectors name them
+func writeRuntimeMetrics(w io.Writer) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	fmt.Fprintf(w, "# HELP go_info Information about the Go environment.\n# TYPE go_info gauge\ngo_info%s 1\n",
+		formatLabels(map[string]string{"version": runtime.Version()}))
+
+	writeGCDuration(w)
+
+	families := []runtimeFamily{
+		{"go_goroutines", "gauge", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_threads", "gauge", "Number of OS threads created.", float64(threadCount())},
+		{"go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.", float64(stats.Alloc)},
+		{"go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed.", float64(stats.TotalAlloc)},
+		{"go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.", float64(stats.Sys)},
+		{"go_memstats_mallocs_total", "counter", "Total number of mallocs.", float64(stats.Mallocs)},
+		{"go_memstats_frees_total", "counter", "Total number of frees.", float64(stats.Frees)},
+		{"go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.", float64(stats.HeapAlloc)},
+		{"go_memstats_heap_sys_bytes", "gauge", "Number of heap bytes obtained from system.", float64(stats.HeapSys)},
+		{"go_memstats_heap_idle_bytes", "gauge", "Number of heap bytes waiting to be used.", float64(stats.HeapIdle)},
+		{"go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.", float64(stats.HeapInuse)},
+		{"go_memstats_heap_released_bytes", "gauge", "Number of heap bytes released to OS.", float64(stats.HeapReleased)},
+		{"go_memstats_heap_objects", "gauge", "Number of allocated objects.", float64(stats.HeapObjects)},
+		{"go_memstats_stack_inuse_bytes", "gauge", "Number of bytes in use by the stack allocator.", float64(stats.StackInuse)},
+		{"go_memstats_next_gc_bytes", "gauge", "Number of heap bytes when next garbage collection will take place.", float64(stats.NextGC)},
+		{"go_memstats_last_gc_time_seconds", "gauge", "Number of seconds since 1970 of last garbage collection.", float64(stats.LastGC) / 1e9},
+		{"go_gc_cycles_total", "counter", "Number of completed GC cycles.", float64(stats.NumGC)},
+	}
+	writeRuntimeFamilies(w, families)
+
+	writeProcessMetrics(w)
+}
+
+// runtimeFamily is an unlabelled runtime or process metric with a single sample
+type runtimeFamily struct {
+	name  string
+	kind  string
+	help  string
+	value float64
+}
+
+// writeRuntimeFamilies writes each family's help, type and sample
+func writeRuntimeFamilies(w io.Writer, families []runtimeFamily) {
+	for _, family := range families {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", family.name, family.help, family.name, family.kind, family.name, formatValue(family.value))
+	}
+}
+
+// writeGCDuration writes GC pause durations as a summary with min, quartiles and max
+func writeGCDuration(w io.Writer) {
+	stats := debug.GCStats{PauseQuantiles: make([]time.Duration, 5)}
+	debug.ReadGCStats(&stats)
+
+	const name = "go_gc_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s A summary of the wall-time pause (stop-the-world) duration in garbage collection cycles.\n# TYPE %s summary\n", name, name)
+	for i, quantile := range []string{"0", "0.25", "0.5", "0.75", "1"} {
+		fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(map[string]string{"quantile": quantile}), formatValue(stats.PauseQuantiles[i].Seconds()))
+	}
+	fmt.Fprintf(w, "%s_sum %s\n%s_count %d\n", name, formatValue(stats.PauseTotal.Seconds()), name, stats.NumGC)
+}
+
+// threadCount returns the number of OS threads created by the runtime
+func threadCount() int {
+	n, _ := runtime.ThreadCreateProfile(nil)
+	return n
+}
+
+// writeProcessMetrics writes process_* metrics read from /proc. Nothing is written on
+// platforms without procfs
+func writeProcessMetrics(w io.Writer) {
+	stat, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return
+	}
+	// Fields after the parenthesised command name, which may itself contain spaces
+	end := bytes.LastIndexByte(stat, ')')
+	if end < 0 {
+		return
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 22 {
+		return
+	}
+	field := func(i int) float64 {
+		// i is the field number from proc(5); fields starts at field 3 (state)
+		value, _ := strconv.ParseFloat(fields[i-3], 64)
+		return value
+	}
+
+	families := []runtimeFamily{
+		{"process_cpu_seconds_total", "counter", "Total user and system CPU time spent in seconds.", (field(14) + field(15)) / procUserHZ},
+		{"process_virtual_memory_bytes", "gauge", "Virtual memory size in bytes.", field(23)},
+		{"process_resident_memory_bytes", "gauge", "Resident memory size in bytes.", field(24) * float64(os.Getpagesize())},
+	}
+	if bootTime, ok := systemBootTime(); ok {
+		families = append(families, runtimeFamily{"process_start_time_seconds", "gauge", "Start time of the process since unix epoch in seconds.", bootTime + field(22)/procUserHZ})
+	}
+	if fds, err := os.ReadDir("/proc/self/fd"); err == nil {
+		families = append(families, runtimeFamily{"process_open_fds", "gauge", "Number of open file descriptors.", float64(len(fds))})
+	}
+	if maxFDs, ok := maxOpenFiles(); ok {
+		families = append(families, runtimeFamily{"process_max_fds", "gauge", "Maximum number of open file descriptors.", maxFDs})
+	}
+
+	writeRuntimeFamilies(w, families)
+}
+
+// systemBootTime returns the boot time from /proc/stat in Unix seconds
+func systemBootTime() (float64, bool) {
+	data, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "btime "); ok {
+			bootTime, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return bootTime, err == nil
+		}
+	}
+	return 0, false
+}
+
+// maxOpenFiles returns the soft open files limit from /proc/self/limits
+func maxOpenFiles() (float64, bool) {
+	data, err := os.ReadFile("/proc/self/limits")
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok := strings.CutPrefix(line, "Max open files"); ok {
+			fields := strings.Fields(value)
+			if len(fields) == 0 {
+				return 0, false
+			}
+			limit, err := strconv.ParseFloat(fields[0], 64)
+			return limit, err == nil
+		}
+	}
+	return 0, false
+}
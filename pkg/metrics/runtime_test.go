@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+func TestRuntimeMetrics(t *testing.T) {
+	// Off by default
+	if output := exposition(newTestCollector(t)); strings.Contains(output, "go_goroutines") {
+		t.Error("runtime metrics exposed without runtime_metrics")
+	}
+
+	collector := NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute, RuntimeMetrics: true})
+	defer collector.Close()
+	output := exposition(collector)
+
+	for _, pattern := range []string{
+		`(?m)^# TYPE go_goroutines gauge$`,
+		`(?m)^go_goroutines [1-9][0-9]*$`,
+		`(?m)^go_info\{version="go[^"]+"\} 1$`,
+		`(?m)^go_memstats_alloc_bytes [0-9.e+]+$`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(output) {
+			t.Errorf("exposition output does not match %s", pattern)
+		}
+	}
+}
@@ -3,39 +3,63 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"elasticetl/pkg/config"
 	"elasticetl/pkg/extract"
 	"elasticetl/pkg/load"
+	"elasticetl/pkg/logging"
 	"elasticetl/pkg/metrics"
 	"elasticetl/pkg/transform"
 )
 
+// upMetricName is the synthetic per-endpoint metric emitted when Extract.EmitUpMetric is set
+const upMetricName = "elasticetl_up"
+
 // Pipeline represents a single ETL pipeline
 type Pipeline struct {
 	config      config.PipelineConfig
 	extractor   *extract.Extractor
 	transformer *transform.Transformer
 	loader      *load.Loader
+	logger      *logging.Logger
 	metrics     *metrics.Collector
 	ticker      *time.Ticker
 	stopChan    chan struct{}
+	firstRun    chan struct{} // Closed once the first execution after Start completes
+	loadQueue   chan *loadBatch
 	mutex       sync.RWMutex
 	running     bool
 }
 
+// loadBatch is a transformed batch waiting to be loaded by the background loader
+type loadBatch struct {
+	results          []*transform.TransformedResult
+	startTime        time.Time
+	entriesProcessed int64
+	bytesProcessed   int64
+}
+
 // NewPipeline creates a new pipeline
 func NewPipeline(cfg config.PipelineConfig, metricsCollector *metrics.Collector) (*Pipeline, error) {
+	logger := logging.ForPipeline(cfg.Name)
+
 	// Create extractor
-	extractor := extract.NewExtractor(cfg.Extract)
+	extractor, err := extract.NewExtractor(cfg.Extract, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create extractor: %w", err)
+	}
 
 	// Create transformer
-	transformer := transform.NewTransformer(cfg.Transform)
+	transformer, err := transform.NewTransformer(transformConfig(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transformer: %w", err)
+	}
 
 	// Create loader
-	loader, err := load.NewLoader(cfg.Load)
+	loader, err := load.NewLoader(cfg.Load, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create loader: %w", err)
 	}
@@ -45,13 +69,27 @@ func NewPipeline(cfg config.PipelineConfig, metricsCollector *metrics.Collector)
 		extractor:   extractor,
 		transformer: transformer,
 		loader:      loader,
+		logger:      logger,
 		metrics:     metricsCollector,
 		stopChan:    make(chan struct{}),
 	}
 
+	metricsCollector.SetPipelineLabels(cfg.Name, cfg.MetricLabels)
+	loader.SetDroppedSeriesHandler(func(dropped int) {
+		metricsCollector.RecordDroppedSeries(cfg.Name, dropped)
+	})
+
 	return pipeline, nil
 }
 
+// transformConfig returns the transform configuration with settings shared with extraction applied
+func transformConfig(cfg config.PipelineConfig) config.TransformConfig {
+	transformCfg := cfg.Transform
+	transformCfg.ArrayIndexFormat = cfg.Extract.ArrayIndexFormat
+	transformCfg.GenerateCSV = load.RequiresCSV(cfg.Load)
+	return transformCfg
+}
+
 // Start starts the pipeline
 func (p *Pipeline) Start(ctx context.Context) error {
 	p.mutex.Lock()
@@ -67,12 +105,14 @@ func (p *Pipeline) Start(ctx context.Context) error {
 
 	p.running = true
 	p.ticker = time.NewTicker(p.config.Interval)
+	p.startLoadWorker(ctx)
 
 	// Update metrics
 	p.metrics.UpdatePipelineStatus(p.config.Name, true)
 
 	// Start pipeline execution loop
-	go p.run(ctx)
+	p.firstRun = make(chan struct{})
+	go p.run(ctx, p.firstRun)
 
 	return nil
 }
@@ -106,6 +146,22 @@ func (p *Pipeline) IsRunning() bool {
 	return p.running
 }
 
+// waitFirstRun blocks until the first execution after Start has completed or ctx is done
+func (p *Pipeline) waitFirstRun(ctx context.Context) {
+	p.mutex.RLock()
+	firstRun := p.firstRun
+	p.mutex.RUnlock()
+
+	if firstRun == nil {
+		return
+	}
+
+	select {
+	case <-firstRun:
+	case <-ctx.Done():
+	}
+}
+
 // GetName returns the pipeline name
 func (p *Pipeline) GetName() string {
 	return p.config.Name
@@ -132,8 +188,12 @@ func (p *Pipeline) UpdateConfig(cfg config.PipelineConfig) error {
 	p.config = cfg
 
 	// Update components
-	p.extractor.UpdateConfig(cfg.Extract)
-	p.transformer.UpdateConfig(cfg.Transform)
+	if err := p.extractor.UpdateConfig(cfg.Extract); err != nil {
+		return fmt.Errorf("failed to update extractor config: %w", err)
+	}
+	if err := p.transformer.UpdateConfig(transformConfig(cfg)); err != nil {
+		return fmt.Errorf("failed to update transformer config: %w", err)
+	}
 	if err := p.loader.UpdateConfig(cfg.Load); err != nil {
 		return fmt.Errorf("failed to update loader config: %w", err)
 	}
@@ -142,25 +202,65 @@ func (p *Pipeline) UpdateConfig(cfg config.PipelineConfig) error {
 	if wasRunning && cfg.Enabled {
 		p.running = true
 		p.ticker = time.NewTicker(cfg.Interval)
-		go p.run(context.Background()) // Use background context for restart
+		p.startLoadWorker(context.Background())
+		go p.run(context.Background(), nil) // Use background context for restart
 	}
 
 	// Update metrics
+	p.metrics.SetPipelineLabels(cfg.Name, cfg.MetricLabels)
+	p.metrics.PruneEndpointLatencies(cfg.Name, cfg.Extract.ClusterNames, cfg.Extract.URLs)
 	p.metrics.UpdatePipelineStatus(cfg.Name, cfg.Enabled && p.running)
 
 	return nil
 }
 
-// run executes the pipeline loop
-func (p *Pipeline) run(ctx context.Context) {
+// run executes the pipeline loop, closing firstRun (if set) after the initial execution
+func (p *Pipeline) run(ctx context.Context, firstRun chan struct{}) {
 	defer func() {
 		p.mutex.Lock()
 		p.running = false
 		p.mutex.Unlock()
 	}()
 
-	// Execute immediately on start
+	p.mutex.RLock()
+	initialDelay := p.config.InitialDelay
+	p.mutex.RUnlock()
+
+	if initialDelay > 0 {
+		// A delayed pipeline runs later anyway, so it does not hold up the next startup batch
+		if firstRun != nil {
+			close(firstRun)
+			firstRun = nil
+		}
+
+		timer := time.NewTimer(initialDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-p.stopChan:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// Count the interval from the delayed first run rather than from Start
+		p.mutex.Lock()
+		if p.ticker != nil {
+			p.ticker.Reset(p.config.Interval)
+			select {
+			case <-p.ticker.C: // Drop a tick delivered during the delay
+			default:
+			}
+		}
+		p.mutex.Unlock()
+	}
+
+	// Execute immediately on start (or once the initial delay has passed)
 	p.execute(ctx)
+	if firstRun != nil {
+		close(firstRun)
+	}
 
 	for {
 		select {
@@ -179,9 +279,16 @@ func (p *Pipeline) execute(ctx context.Context) {
 	startTime := time.Now()
 	p.metrics.RecordPipelineStart(p.config.Name)
 
+	if p.config.StreamResults {
+		p.executeStreaming(ctx, startTime)
+		return
+	}
+
 	// Extract
 	extractResults, err := p.extractor.Extract(ctx)
+	p.recordEndpointLatencies()
 	if err != nil {
+		p.loadUpMetrics(ctx)
 		duration := time.Since(startTime)
 		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("extraction failed: %w", err))
 		return
@@ -189,32 +296,225 @@ func (p *Pipeline) execute(ctx context.Context) {
 
 	if len(extractResults) == 0 {
 		// No data extracted, but not an error
+		p.loadUpMetrics(ctx)
 		duration := time.Since(startTime)
 		p.metrics.RecordPipelineSuccess(p.config.Name, duration, 0, 0)
 		return
 	}
 
 	// Transform
-	transformResults, err := p.transformer.Transform(extractResults)
+	transformResults, stats, err := p.transformer.TransformWithStats(extractResults)
 	if err != nil {
 		duration := time.Since(startTime)
 		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("transformation failed: %w", err))
 		return
 	}
+	p.recordTransformStats(stats)
 
-	// Load
-	if err := p.loader.Load(ctx, transformResults); err != nil {
+	// Include the synthetic up metrics if enabled
+	batch := &loadBatch{
+		results:          append(transformResults, p.upMetricResults()...),
+		startTime:        startTime,
+		entriesProcessed: int64(len(transformResults)),
+		bytesProcessed:   p.calculateBytesProcessed(extractResults),
+	}
+
+	p.mutex.RLock()
+	queue := p.loadQueue
+	p.mutex.RUnlock()
+
+	if queue == nil {
+		p.load(ctx, batch)
+		return
+	}
+
+	// Hand the batch to the background loader, skipping this run if the queue is full
+	select {
+	case queue <- batch:
+		p.metrics.UpdateLoadQueueDepth(p.config.Name, len(queue))
+	default:
+		p.metrics.RecordDroppedBatch(p.config.Name)
 		duration := time.Since(startTime)
-		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("loading failed: %w", err))
+		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("load queue full (%d batches), batch dropped", cap(queue)))
+	}
+}
+
+// executeStreaming runs a single execution in streaming mode: each endpoint's result is
+// transformed and loaded before the next endpoint is queried. The run is recorded once with
+// the totals across all endpoints. The load queue is not used in this mode
+func (p *Pipeline) executeStreaming(ctx context.Context, startTime time.Time) {
+	var entriesProcessed, bytesProcessed int64
+	var transformStats transform.Stats
+	var errors []error
+
+	_, err := p.extractor.ExtractEach(ctx, func(result *extract.Result) {
+		transformResults, stats, err := p.transformer.TransformWithStats([]*extract.Result{result})
+		if err != nil {
+			errors = append(errors, fmt.Errorf("transformation failed for %s: %w", result.Source, err))
+			return
+		}
+		transformStats.Add(stats)
+
+		if err := p.loader.Load(ctx, transformResults); err != nil {
+			errors = append(errors, fmt.Errorf("loading failed for %s: %w", result.Source, err))
+			return
+		}
+
+		entriesProcessed += int64(len(transformResults))
+		bytesProcessed += p.calculateBytesProcessed([]*extract.Result{result})
+	})
+
+	p.recordEndpointLatencies()
+	p.recordTransformStats(transformStats)
+	p.loadUpMetrics(ctx)
+
+	if err != nil {
+		duration := time.Since(startTime)
+		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("extraction failed: %w", err))
 		return
 	}
 
-	// Calculate metrics
-	duration := time.Since(startTime)
-	entriesProcessed := int64(len(transformResults))
-	bytesProcessed := p.calculateBytesProcessed(extractResults)
+	if len(errors) > 0 {
+		duration := time.Since(startTime)
+		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("streaming run failed: %v", errors))
+		return
+	}
 
+	duration := time.Since(startTime)
 	p.metrics.RecordPipelineSuccess(p.config.Name, duration, entriesProcessed, bytesProcessed)
+	p.recordDelivered(entriesProcessed, bytesProcessed)
+}
+
+// recordTransformStats records a run's transform stats with the metrics collector
+func (p *Pipeline) recordTransformStats(stats transform.Stats) {
+	p.metrics.RecordTransformStats(p.config.Name, metrics.TransformStats{
+		InputRecords:    stats.InputRecords,
+		OutputRecords:   stats.OutputRecords,
+		RowsGenerated:   stats.RowsGenerated,
+		FieldsDropped:   stats.FieldsDropped,
+		FieldsConverted: stats.FieldsConverted,
+	})
+}
+
+// load loads a transformed batch and records the outcome of the run it belongs to
+func (p *Pipeline) load(ctx context.Context, batch *loadBatch) {
+	if err := p.loader.Load(ctx, batch.results); err != nil {
+		duration := time.Since(batch.startTime)
+		p.metrics.RecordPipelineFailure(p.config.Name, duration, fmt.Errorf("loading failed: %w", err))
+		return
+	}
+
+	duration := time.Since(batch.startTime)
+	p.metrics.RecordPipelineSuccess(p.config.Name, duration, batch.entriesProcessed, batch.bytesProcessed)
+	p.recordDelivered(batch.entriesProcessed, batch.bytesProcessed)
+}
+
+// recordDelivered records a successful run's entries and bytes as delivered, unless the
+// pipeline only loads to the debug stream
+func (p *Pipeline) recordDelivered(entriesProcessed, bytesProcessed int64) {
+	if p.loader.Delivers() {
+		p.metrics.RecordDelivered(p.config.Name, entriesProcessed, bytesProcessed)
+	}
+}
+
+// startLoadWorker creates the load queue and its background loader when LoadQueueSize is set.
+// Must be called with the mutex held
+func (p *Pipeline) startLoadWorker(ctx context.Context) {
+	if p.config.LoadQueueSize <= 0 {
+		p.loadQueue = nil
+		return
+	}
+
+	p.loadQueue = make(chan *loadBatch, p.config.LoadQueueSize)
+	go p.loadWorker(ctx, p.stopChan, p.loadQueue)
+}
+
+// loadWorker drains the load queue until the pipeline is stopped; batches still queued at
+// that point are discarded and counted as dropped
+func (p *Pipeline) loadWorker(ctx context.Context, stopChan <-chan struct{}, queue <-chan *loadBatch) {
+	defer p.discardQueued(queue)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopChan:
+			return
+		case batch := <-queue:
+			p.metrics.UpdateLoadQueueDepth(p.config.Name, len(queue))
+			p.load(ctx, batch)
+		}
+	}
+}
+
+// discardQueued empties queue, recording each batch left in it as dropped
+func (p *Pipeline) discardQueued(queue <-chan *loadBatch) {
+	for {
+		select {
+		case <-queue:
+			p.metrics.RecordDroppedBatch(p.config.Name)
+		default:
+			p.metrics.UpdateLoadQueueDepth(p.config.Name, 0)
+			return
+		}
+	}
+}
+
+// upMetricResults builds synthetic elasticetl_up results (1 success / 0 failure) reflecting the
+// last extraction outcome of each endpoint, or nil if the up metric is not enabled
+func (p *Pipeline) upMetricResults() []*transform.TransformedResult {
+	if !p.config.Extract.EmitUpMetric {
+		return nil
+	}
+
+	now := time.Now()
+	var results []*transform.TransformedResult
+	for _, status := range p.extractor.GetEndpointStatus() {
+		value := 0
+		if status.Up {
+			value = 1
+		}
+
+		data := map[string]interface{}{upMetricName: value}
+		metadata := map[string]interface{}{
+			"endpoint":     status.URL,
+			"cluster_name": status.ClusterName,
+		}
+		if status.Error != "" {
+			metadata["error"] = status.Error
+		}
+
+		results = append(results, &transform.TransformedResult{
+			Result: &extract.Result{
+				Timestamp: now,
+				Source:    status.URL,
+				Data:      data,
+				Metadata:  metadata,
+			},
+			TransformedData: data,
+		})
+	}
+
+	return results
+}
+
+// recordEndpointLatencies records the request latency of each endpoint in the last extraction
+func (p *Pipeline) recordEndpointLatencies() {
+	for _, status := range p.extractor.GetEndpointStatus() {
+		p.metrics.ObserveEndpointLatency(p.config.Name, status.ClusterName, status.URL, status.Latency)
+	}
+}
+
+// loadUpMetrics pushes only the synthetic up metrics, used when a run produced no data to load
+func (p *Pipeline) loadUpMetrics(ctx context.Context) {
+	upResults := p.upMetricResults()
+	if len(upResults) == 0 {
+		return
+	}
+
+	if err := p.loader.Load(ctx, upResults); err != nil {
+		p.logger.Printf("Failed to load up metrics: %v", err)
+	}
 }
 
 // calculateBytesProcessed estimates the number of bytes processed
@@ -243,9 +543,11 @@ func (p *Pipeline) Close() error {
 
 // Manager manages multiple pipelines
 type Manager struct {
-	pipelines map[string]*Pipeline
-	metrics   *metrics.Collector
-	mutex     sync.RWMutex
+	pipelines          map[string]*Pipeline
+	metrics            *metrics.Collector
+	mutex              sync.RWMutex
+	startupConcurrency int
+	startupWindow      time.Duration
 }
 
 // NewManager creates a new pipeline manager
@@ -256,6 +558,16 @@ func NewManager(metricsCollector *metrics.Collector) *Manager {
 	}
 }
 
+// SetStartupPolicy limits how many pipelines StartAllPipelines starts at once and the window
+// over which the remaining starts are spread. A concurrency of 0 starts all pipelines together
+func (m *Manager) SetStartupPolicy(concurrency int, window time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.startupConcurrency = concurrency
+	m.startupWindow = window
+}
+
 // AddPipeline adds a new pipeline
 func (m *Manager) AddPipeline(cfg config.PipelineConfig) error {
 	m.mutex.Lock()
@@ -289,6 +601,7 @@ func (m *Manager) RemovePipeline(name string) error {
 	}
 
 	delete(m.pipelines, name)
+	m.metrics.RemovePipelineMetrics(name)
 	return nil
 }
 
@@ -325,12 +638,57 @@ func (m *Manager) StartAllPipelines(ctx context.Context) error {
 	for _, pipeline := range m.pipelines {
 		pipelines = append(pipelines, pipeline)
 	}
+	concurrency := m.startupConcurrency
+	window := m.startupWindow
 	m.mutex.RUnlock()
 
+	// Start in name order so staggered starts are predictable
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].GetName() < pipelines[j].GetName()
+	})
+
+	if concurrency <= 0 || concurrency >= len(pipelines) {
+		concurrency = len(pipelines)
+	}
+	batches := 1
+	if concurrency > 0 {
+		batches = (len(pipelines) + concurrency - 1) / concurrency
+	}
+	batchDelay := window / time.Duration(batches)
+
 	var errors []error
-	for _, pipeline := range pipelines {
-		if err := pipeline.Start(ctx); err != nil {
-			errors = append(errors, err)
+	for start := 0; start < len(pipelines); start += concurrency {
+		batchStart := time.Now()
+		end := start + concurrency
+		if end > len(pipelines) {
+			end = len(pipelines)
+		}
+
+		batch := pipelines[start:end]
+		for _, pipeline := range batch {
+			if err := pipeline.Start(ctx); err != nil {
+				errors = append(errors, err)
+			}
+		}
+
+		if end == len(pipelines) {
+			break
+		}
+
+		// Let this batch finish its initial execution before starting the next, and keep
+		// batches at least batchDelay apart to spread the ramp over the startup window
+		for _, pipeline := range batch {
+			pipeline.waitFirstRun(ctx)
+		}
+		if remaining := batchDelay - time.Since(batchStart); remaining > 0 {
+			select {
+			case <-time.After(remaining):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			errors = append(errors, ctx.Err())
+			break
 		}
 	}
 
@@ -375,7 +733,7 @@ func (m *Manager) UpdatePipelines(configs []config.PipelineConfig) error {
 		newConfigs[cfg.Name] = cfg
 	}
 
-	// Update existing pipelines or remove if not in new config
+	// Update existing pipelines in place, keeping their metrics, or remove if not in new config
 	for name, pipeline := range m.pipelines {
 		if newCfg, exists := newConfigs[name]; exists {
 			if err := pipeline.UpdateConfig(newCfg); err != nil {
@@ -388,6 +746,7 @@ func (m *Manager) UpdatePipelines(configs []config.PipelineConfig) error {
 				return fmt.Errorf("failed to close pipeline %s: %w", name, err)
 			}
 			delete(m.pipelines, name)
+			m.metrics.RemovePipelineMetrics(name)
 		}
 	}
 
@@ -410,6 +769,40 @@ func (m *Manager) UpdatePipelines(configs []config.PipelineConfig) error {
 	return nil
 }
 
+// GetLastResponse returns the latest raw response captured from a pipeline's endpoint at index
+func (m *Manager) GetLastResponse(name string, index int) (*extract.RawResponse, bool) {
+	m.mutex.RLock()
+	pipeline, exists := m.pipelines[name]
+	m.mutex.RUnlock()
+
+	if !exists {
+		return nil, false
+	}
+
+	return pipeline.extractor.GetLastResponse(index)
+}
+
+// ListPipelines describes every configured pipeline for the metrics server's /pipelines listing
+func (m *Manager) ListPipelines() []metrics.PipelineInfo {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	pipelines := make([]metrics.PipelineInfo, 0, len(m.pipelines))
+	for name, pipeline := range m.pipelines {
+		pipeline.mutex.RLock()
+		info := metrics.PipelineInfo{
+			Name:     name,
+			Enabled:  pipeline.config.Enabled,
+			Interval: pipeline.config.Interval,
+			Running:  pipeline.running,
+		}
+		pipeline.mutex.RUnlock()
+		pipelines = append(pipelines, info)
+	}
+
+	return pipelines
+}
+
 // GetPipelineStatus returns the status of all pipelines
 func (m *Manager) GetPipelineStatus() map[string]bool {
 	m.mutex.RLock()
@@ -0,0 +1,434 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/metrics"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// remoteWriteSink is a remote write receiver recording every sample it is sent, keyed by
+// metric name and cluster label
+type remoteWriteSink struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	samples map[string][]float64
+}
+
+func newRemoteWriteSink(t *testing.T) *remoteWriteSink {
+	t.Helper()
+	sink := &remoteWriteSink{samples: make(map[string][]float64)}
+	sink.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		decoded, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("snappy decode: %v", err)
+			return
+		}
+		var req prompb.WriteRequest
+		if err := req.Unmarshal(decoded); err != nil {
+			t.Errorf("unmarshal write request: %v", err)
+			return
+		}
+
+		sink.mutex.Lock()
+		defer sink.mutex.Unlock()
+		for _, ts := range req.Timeseries {
+			var name, cluster string
+			for _, label := range ts.Labels {
+				switch label.Name {
+				case "__name__":
+					name = label.Value
+				case "cluster":
+					cluster = label.Value
+				}
+			}
+			for _, sample := range ts.Samples {
+				sink.samples[name+"/"+cluster] = append(sink.samples[name+"/"+cluster], sample.Value)
+			}
+		}
+	}))
+	t.Cleanup(sink.Close)
+	return sink
+}
+
+// received returns the sample values received for a metric and cluster
+func (s *remoteWriteSink) received(name, cluster string) []float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.samples[name+"/"+cluster]
+}
+
+// newTestPipeline creates a pipeline for cfg with a collector that serves no endpoints
+func newTestPipeline(t *testing.T, cfg config.PipelineConfig) (*Pipeline, *metrics.Collector) {
+	t.Helper()
+	collector := metrics.NewCollector(config.MetricsConfig{})
+	p, err := NewPipeline(cfg, collector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p, collector
+}
+
+func TestDiscardQueuedCountsDroppedBatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		queued int
+	}{
+		{"empty queue", 0},
+		{"queued batches", 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Port 0 lets the metrics server bind any free port
+			collector := metrics.NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute})
+			defer collector.Close()
+			collector.RecordPipelineStart("p")
+
+			p := &Pipeline{config: config.PipelineConfig{Name: "p"}, metrics: collector}
+			queue := make(chan *loadBatch, 4)
+			for i := 0; i < tt.queued; i++ {
+				queue <- &loadBatch{}
+			}
+
+			p.discardQueued(queue)
+
+			if len(queue) != 0 {
+				t.Errorf("%d batches left in the queue", len(queue))
+			}
+			if got := collector.GetPipelineMetrics("p").DroppedBatches; got != int64(tt.queued) {
+				t.Errorf("DroppedBatches = %d, want %d", got, tt.queued)
+			}
+		})
+	}
+}
+
+func TestUpMetricReportsFailedEndpoints(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	sink := newRemoteWriteSink(t)
+
+	p, _ := newTestPipeline(t, config.PipelineConfig{
+		Name: "up",
+		Extract: config.ExtractConfig{
+			ElasticsearchQuery: `{"size":0}`,
+			URLs:               []string{healthy.URL, failing.URL},
+			ClusterNames:       []string{"healthy", "failing"},
+			Timeout:            time.Second,
+			EmitUpMetric:       true,
+		},
+		Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "gem", Config: map[string]interface{}{"endpoint": sink.URL}}}},
+	})
+	p.execute(context.Background())
+
+	for cluster, want := range map[string]float64{"healthy": 1, "failing": 0} {
+		got := sink.received("elasticetl_up", cluster)
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("elasticetl_up{cluster=%q} = %v, want [%v]", cluster, got, want)
+		}
+	}
+}
+
+func TestStartAllPipelinesStartupConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	var inflight, peak, queries int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inflight++
+		queries++
+		if inflight > peak {
+			peak = inflight
+		}
+		mutex.Unlock()
+
+		time.Sleep(30 * time.Millisecond)
+
+		mutex.Lock()
+		inflight--
+		mutex.Unlock()
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer server.Close()
+	sink := newRemoteWriteSink(t)
+
+	manager := NewManager(metrics.NewCollector(config.MetricsConfig{}))
+	manager.SetStartupPolicy(2, 300*time.Millisecond)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		err := manager.AddPipeline(config.PipelineConfig{
+			Name:     name,
+			Enabled:  true,
+			Interval: time.Hour,
+			Extract: config.ExtractConfig{
+				ElasticsearchQuery: `{"size":0}`,
+				URLs:               []string{server.URL},
+				ClusterNames:       []string{name},
+				Timeout:            time.Second,
+			},
+			Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "gem", Config: map[string]interface{}{"endpoint": sink.URL}}}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer manager.StopAllPipelines()
+
+	// Five pipelines in batches of two make three batches, each at least 100ms apart
+	started := time.Now()
+	if err := manager.StartAllPipelines(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(started); elapsed < 200*time.Millisecond {
+		t.Errorf("all pipelines started after %v, want the starts spread over the window", elapsed)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		done := queries
+		mutex.Unlock()
+		if done == 5 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if queries != 5 {
+		t.Errorf("%d pipelines ran their first execution, want 5", queries)
+	}
+	if peak > 2 {
+		t.Errorf("%d pipelines queried at once, want at most 2", peak)
+	}
+}
+
+func TestUpdatePipelinesKeepsMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer server.Close()
+	sink := newRemoteWriteSink(t)
+
+	pipelineConfig := func(name string, interval time.Duration) config.PipelineConfig {
+		return config.PipelineConfig{
+			Name:     name,
+			Interval: interval,
+			Extract: config.ExtractConfig{
+				ElasticsearchQuery: `{"size":0}`,
+				URLs:               []string{server.URL},
+				ClusterNames:       []string{name},
+				Timeout:            time.Second,
+			},
+			Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "gem", Config: map[string]interface{}{"endpoint": sink.URL}}}},
+		}
+	}
+
+	// Port 0 lets the metrics server bind any free port
+	collector := metrics.NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute})
+	defer collector.Close()
+	manager := NewManager(collector)
+	defer manager.StopAllPipelines()
+	for _, name := range []string{"kept", "removed"} {
+		if err := manager.AddPipeline(pipelineConfig(name, time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+		manager.pipelines[name].execute(context.Background())
+		manager.pipelines[name].execute(context.Background())
+	}
+
+	// Changing the kept pipeline's settings updates it in place
+	if err := manager.UpdatePipelines([]config.PipelineConfig{pipelineConfig("kept", 2*time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	kept := collector.GetPipelineMetrics("kept")
+	if kept == nil || kept.TotalRuns != 2 || kept.SuccessfulRuns != 2 {
+		t.Errorf("kept pipeline metrics after reload = %+v, want 2 successful runs", kept)
+	}
+	if removed := collector.GetPipelineMetrics("removed"); removed != nil {
+		t.Errorf("removed pipeline still has metrics: %+v", removed)
+	}
+}
+
+func TestInitialDelay(t *testing.T) {
+	queried := make(chan time.Time, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queried <- time.Now()
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer server.Close()
+	sink := newRemoteWriteSink(t)
+
+	newDelayedPipeline := func(name string) *Pipeline {
+		p, _ := newTestPipeline(t, config.PipelineConfig{
+			Name:         name,
+			Enabled:      true,
+			Interval:     time.Hour,
+			InitialDelay: 150 * time.Millisecond,
+			Extract: config.ExtractConfig{
+				ElasticsearchQuery: `{"size":0}`,
+				URLs:               []string{server.URL},
+				ClusterNames:       []string{name},
+				Timeout:            time.Second,
+			},
+			Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "gem", Config: map[string]interface{}{"endpoint": sink.URL}}}},
+		})
+		return p
+	}
+
+	p := newDelayedPipeline("delayed")
+	started := time.Now()
+	if err := p.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case at := <-queried:
+		if delay := at.Sub(started); delay < 150*time.Millisecond {
+			t.Errorf("first execution after %v, want at least the 150ms initial delay", delay)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline never ran its first execution")
+	}
+	p.Stop()
+
+	// Cancelling the context during the delay skips the first execution
+	cancelled := newDelayedPipeline("cancelled")
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := cancelled.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-queried:
+		t.Error("pipeline executed after its context was cancelled during the initial delay")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestListPipelines(t *testing.T) {
+	manager := NewManager(metrics.NewCollector(config.MetricsConfig{}))
+	defer manager.Close()
+	for _, cfg := range []config.PipelineConfig{
+		{Name: "health", Enabled: true, Interval: 30 * time.Second},
+		{Name: "logs", Enabled: false, Interval: 5 * time.Minute},
+	} {
+		cfg.Extract = config.ExtractConfig{ElasticsearchQuery: `{"size":0}`, URLs: []string{"http://127.0.0.1:1"}, ClusterNames: []string{"c"}}
+		if err := manager.AddPipeline(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listed := manager.ListPipelines()
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Name < listed[j].Name })
+	want := []metrics.PipelineInfo{
+		{Name: "health", Enabled: true, Interval: 30 * time.Second},
+		{Name: "logs", Enabled: false, Interval: 5 * time.Minute},
+	}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("ListPipelines() = %+v, want %+v", listed, want)
+	}
+}
+
+func TestDebugOnlyPipelineDeliversNothing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer server.Close()
+	sink := newRemoteWriteSink(t)
+
+	// Port 0 lets the metrics server bind any free port
+	collector := metrics.NewCollector(config.MetricsConfig{Enabled: true, Port: 0, Path: "/metrics", Interval: time.Minute})
+	defer collector.Close()
+
+	debug := config.StreamConfig{Type: "debug", Config: map[string]interface{}{"path": filepath.Join(t.TempDir(), "debug")}}
+	gem := config.StreamConfig{Type: "gem", Config: map[string]interface{}{"endpoint": sink.URL}}
+	for name, streams := range map[string][]config.StreamConfig{"debug-only": {debug}, "shipped": {debug, gem}} {
+		p, err := NewPipeline(config.PipelineConfig{
+			Name: name,
+			Extract: config.ExtractConfig{
+				ElasticsearchQuery: `{"size":0}`,
+				URLs:               []string{server.URL},
+				ClusterNames:       []string{"c0"},
+				Timeout:            time.Second,
+			},
+			Load: config.LoadConfig{Streams: streams},
+		}, collector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		p.execute(context.Background())
+	}
+
+	debugOnly := collector.GetPipelineMetrics("debug-only")
+	if debugOnly.SuccessfulRuns != 1 || debugOnly.EntriesProcessed == 0 {
+		t.Fatalf("debug-only pipeline: %d successful runs, %d entries processed", debugOnly.SuccessfulRuns, debugOnly.EntriesProcessed)
+	}
+	if debugOnly.EntriesDelivered != 0 || debugOnly.BytesDelivered != 0 {
+		t.Errorf("debug-only pipeline delivered %d entries and %d bytes, want none", debugOnly.EntriesDelivered, debugOnly.BytesDelivered)
+	}
+
+	shipped := collector.GetPipelineMetrics("shipped")
+	if shipped.SuccessfulRuns != 1 || shipped.EntriesDelivered == 0 || shipped.EntriesDelivered != shipped.EntriesProcessed || shipped.BytesDelivered != shipped.BytesProcessed {
+		t.Errorf("shipped pipeline delivered %d entries and %d bytes, want %d and %d",
+			shipped.EntriesDelivered, shipped.BytesDelivered, shipped.EntriesProcessed, shipped.BytesProcessed)
+	}
+}
+
+func TestPipelineLogLinesCarryName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"hits":{"total":{"value":1}}}`)
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	defer log.SetOutput(os.Stderr)
+
+	p, _ := newTestPipeline(t, config.PipelineConfig{
+		Name: "tagged",
+		Extract: config.ExtractConfig{
+			ElasticsearchQuery: `{"size":0}`,
+			URLs:               []string{server.URL},
+			ClusterNames:       []string{"c0"},
+			Timeout:            time.Second,
+		},
+		Load: config.LoadConfig{Streams: []config.StreamConfig{{Type: "debug", Config: map[string]interface{}{"path": filepath.Join(t.TempDir(), "debug")}}}},
+	})
+	p.execute(context.Background())
+
+	// The loader logs through the pipeline's logger, so its lines identify the pipeline
+	output := strings.TrimSpace(out.String())
+	if output == "" {
+		t.Fatal("nothing logged for a run loading to the debug stream")
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.Contains(line, "pipeline=tagged ") {
+			t.Errorf("log line %q lacks the pipeline name", line)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package transform
+
+import (
+	"regexp"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+// counterStateTTL is how long a counter series may go unobserved before its last value is
+// forgotten. Series come and go with the clusters, indices and buckets a pipeline reports, and
+// a value this old makes a poor baseline for a delta or rate anyway
+const counterStateTTL = 24 * time.Hour
+
+// counterSample is the last raw value seen for a counter series
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+// applyCounterDelta replaces matching counter fields with their change since the previous run
+// (delta) or that change per second (rate). series identifies the result the fields belong to.
+// A field is left unset on its first observation, and a decrease is handled by the
+// counter_reset policy: zero (default) treats the previous value as zero like Prometheus does,
+// skip leaves the field unset for this run and none keeps the negative change
+func (t *Transformer) applyCounterDelta(data map[string]interface{}, convFunc config.ConversionFunctionConfig, series string, at time.Time) error {
+	var regex *regexp.Regexp
+	if !convFunc.Literal {
+		var err error
+		regex, err = regexp.Compile(convFunc.Field)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key, value := range data {
+		if convFunc.Literal && key != convFunc.Field || !convFunc.Literal && !regex.MatchString(key) {
+			continue
+		}
+		current, err := t.toFloat(value)
+		if err != nil {
+			continue // Only numeric fields are counters
+		}
+
+		counterKey := series + "\x00" + convFunc.Function + "\x00" + key
+		previous, seen := t.counters[counterKey]
+		t.counters[counterKey] = counterSample{value: current, at: at}
+		delete(data, key)
+		if !seen {
+			continue
+		}
+
+		delta := current - previous.value
+		if delta < 0 {
+			switch convFunc.CounterReset {
+			case config.CounterResetSkip:
+				continue
+			case config.CounterResetNone:
+			default:
+				delta = current
+			}
+		}
+
+		if convFunc.Function == "rate" {
+			elapsed := at.Sub(previous.at).Seconds()
+			if elapsed <= 0 {
+				continue
+			}
+			data[key] = delta / elapsed
+			continue
+		}
+		data[key] = delta
+	}
+
+	return nil
+}
+
+// pruneCounters forgets the counter series last observed more than counterStateTTL before now
+func (t *Transformer) pruneCounters(now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for key, sample := range t.counters {
+		if now.Sub(sample.at) > counterStateTTL {
+			delete(t.counters, key)
+		}
+	}
+}
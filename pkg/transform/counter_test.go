@@ -0,0 +1,58 @@
+package transform
+
+import (
+	"testing"
+	"time"
+
+	"elasticetl/pkg/config"
+)
+
+// counterRun feeds values through applyCounterDelta ten seconds apart and returns the field after
+// each run, or nil where it was left unset
+func counterRun(t *testing.T, convFunc config.ConversionFunctionConfig, values ...float64) []interface{} {
+	t.Helper()
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	start := time.Unix(1700000000, 0)
+
+	var got []interface{}
+	for i, value := range values {
+		data := map[string]interface{}{"requests": value}
+		if err := transformer.applyCounterDelta(data, convFunc, "es-1", start.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, data["requests"])
+	}
+	return got
+}
+
+func TestCounterRateReset(t *testing.T) {
+	// The counter restarts from zero between the third and fourth run
+	values := []float64{100, 200, 300, 50, 150}
+	rate := config.ConversionFunctionConfig{Function: "rate", Field: "requests", Literal: true}
+
+	// zero (default): the reset counts as a climb from zero, so the rate is 50/10s
+	assertSamples(t, "zero", counterRun(t, rate, values...), []interface{}{nil, 10.0, 10.0, 5.0, 10.0})
+
+	rate.CounterReset = config.CounterResetSkip
+	assertSamples(t, "skip", counterRun(t, rate, values...), []interface{}{nil, 10.0, 10.0, nil, 10.0})
+
+	rate.CounterReset = config.CounterResetNone
+	assertSamples(t, "none", counterRun(t, rate, values...), []interface{}{nil, 10.0, 10.0, -25.0, 10.0})
+}
+
+func TestCounterDeltaReset(t *testing.T) {
+	delta := config.ConversionFunctionConfig{Function: "delta", Field: "requests", Literal: true}
+	assertSamples(t, "delta", counterRun(t, delta, 10, 25, 5), []interface{}{nil, 15.0, 5.0})
+}
+
+func assertSamples(t *testing.T, name string, got, want []interface{}) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d runs, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: run %d = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
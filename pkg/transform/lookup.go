@@ -0,0 +1,149 @@
+package transform
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"elasticetl/pkg/config"
+)
+
+// lookupTable holds the rows of a lookup file keyed by the lookup column
+type lookupTable struct {
+	config config.LookupConfig
+	rows   map[string]map[string]interface{}
+}
+
+// loadLookupTables loads every configured lookup file
+func loadLookupTables(lookups []config.LookupConfig) ([]*lookupTable, error) {
+	var tables []*lookupTable
+	for _, lookupCfg := range lookups {
+		table, err := loadLookupTable(lookupCfg)
+		if err != nil {
+			return nil, fmt.Errorf("lookup %s: %w", lookupCfg.File, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// loadLookupTable loads a CSV or JSON lookup file based on its extension
+func loadLookupTable(cfg config.LookupConfig) (*lookupTable, error) {
+	data, err := os.ReadFile(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lookup file: %w", err)
+	}
+
+	var rows map[string]map[string]interface{}
+	switch strings.ToLower(filepath.Ext(cfg.File)) {
+	case ".json":
+		// JSON lookups map each key to an object of fields
+		if err := json.Unmarshal(data, &rows); err != nil {
+			return nil, fmt.Errorf("failed to parse lookup JSON: %w", err)
+		}
+	case ".csv":
+		rows, err = parseLookupCSV(string(data), cfg.KeyColumn)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported lookup file type %q (must be .csv or .json)", filepath.Ext(cfg.File))
+	}
+
+	return &lookupTable{config: cfg, rows: rows}, nil
+}
+
+// parseLookupCSV parses a CSV lookup file with a header row. The key column defaults to the
+// first column; the remaining columns become the merged fields
+func parseLookupCSV(data, keyColumn string) (map[string]map[string]interface{}, error) {
+	records, err := csv.NewReader(strings.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lookup CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("lookup CSV has no header row")
+	}
+
+	headers := records[0]
+	keyIndex := 0
+	if keyColumn != "" {
+		keyIndex = -1
+		for i, header := range headers {
+			if header == keyColumn {
+				keyIndex = i
+				break
+			}
+		}
+		if keyIndex == -1 {
+			return nil, fmt.Errorf("lookup CSV has no column %q", keyColumn)
+		}
+	}
+
+	rows := make(map[string]map[string]interface{})
+	for _, record := range records[1:] {
+		if keyIndex >= len(record) {
+			continue
+		}
+		fields := make(map[string]interface{})
+		for i, header := range headers {
+			if i != keyIndex && i < len(record) {
+				fields[header] = record[i]
+			}
+		}
+		rows[record[keyIndex]] = fields
+	}
+
+	return rows, nil
+}
+
+// apply merges the lookup fields for the record's key into data, using the configured
+// defaults when the key is absent from the record or the table. An exact key_field match merges
+// at the top level. Keys inside arrays, such as the per-bucket keys of an aggregation, match when
+// their path with array indices removed (by removeIndices) ends with key_field, and the fields
+// are merged next to each matched key so every bucket is enriched
+func (l *lookupTable) apply(data map[string]interface{}, removeIndices func(key string) string) {
+	if keyValue, exists := data[l.config.KeyField]; exists {
+		l.merge(data, "", keyValue)
+		return
+	}
+
+	// Collect matches first since merged fields are added to data
+	var matches []string
+	for key := range data {
+		path := removeIndices(key)
+		if path != key && (path == l.config.KeyField || strings.HasSuffix(path, "."+l.config.KeyField)) {
+			matches = append(matches, key)
+		}
+	}
+	if len(matches) == 0 {
+		l.merge(data, "", nil)
+		return
+	}
+
+	sort.Strings(matches)
+	for _, key := range matches {
+		parent := ""
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			parent = key[:i+1]
+		}
+		l.merge(data, parent, data[key])
+	}
+}
+
+// merge writes the fields looked up for keyValue, or the defaults, under parent
+func (l *lookupTable) merge(data map[string]interface{}, parent string, keyValue interface{}) {
+	fields := l.config.Default
+	if keyValue != nil {
+		if row, found := l.rows[fmt.Sprintf("%v", keyValue)]; found {
+			fields = row
+		}
+	}
+
+	for field, value := range fields {
+		data[parent+l.config.Prefix+field] = value
+	}
+}
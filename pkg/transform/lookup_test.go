@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"elasticetl/pkg/config"
+)
+
+// writeLookupFile writes content to a file named name in a temporary directory
+func writeLookupFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLookupApply(t *testing.T) {
+	csvFile := writeLookupFile(t, "nodes.csv", "node,rack,owner\nn1,r1,alice\nn2,r2,bob\n")
+	jsonFile := writeLookupFile(t, "nodes.json", `{"n1":{"rack":"r1"}}`)
+
+	tests := []struct {
+		name   string
+		lookup config.LookupConfig
+		format string
+		data   map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "top-level key",
+			lookup: config.LookupConfig{File: csvFile, KeyField: "node"},
+			data:   map[string]interface{}{"node": "n1"},
+			want:   map[string]interface{}{"node": "n1", "rack": "r1", "owner": "alice"},
+		},
+		{
+			name:   "prefix",
+			lookup: config.LookupConfig{File: jsonFile, KeyField: "node", Prefix: "node_"},
+			data:   map[string]interface{}{"node": "n1"},
+			want:   map[string]interface{}{"node": "n1", "node_rack": "r1"},
+		},
+		{
+			name:   "missing key uses defaults",
+			lookup: config.LookupConfig{File: csvFile, KeyField: "node", Default: map[string]interface{}{"rack": "unknown"}},
+			data:   map[string]interface{}{"node": "n9"},
+			want:   map[string]interface{}{"node": "n9", "rack": "unknown"},
+		},
+		{
+			name:   "aggregation buckets",
+			lookup: config.LookupConfig{File: csvFile, KeyField: "aggregations.by_node.buckets.key"},
+			data: map[string]interface{}{
+				"aggregations.by_node.buckets[0].key": "n1",
+				"aggregations.by_node.buckets[1].key": "n2",
+			},
+			want: map[string]interface{}{
+				"aggregations.by_node.buckets[0].key":   "n1",
+				"aggregations.by_node.buckets[0].rack":  "r1",
+				"aggregations.by_node.buckets[0].owner": "alice",
+				"aggregations.by_node.buckets[1].key":   "n2",
+				"aggregations.by_node.buckets[1].rack":  "r2",
+				"aggregations.by_node.buckets[1].owner": "bob",
+			},
+		},
+		{
+			name:   "bucket key suffix with dot indices",
+			lookup: config.LookupConfig{File: jsonFile, KeyField: "buckets.key"},
+			format: config.ArrayIndexFormatDot,
+			data:   map[string]interface{}{"aggregations.by_node.buckets.0.key": "n1"},
+			want: map[string]interface{}{
+				"aggregations.by_node.buckets.0.key":  "n1",
+				"aggregations.by_node.buckets.0.rack": "r1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer := newTestTransformer(t, config.TransformConfig{
+				Lookups:          []config.LookupConfig{tt.lookup},
+				ArrayIndexFormat: tt.format,
+			})
+			for _, lookup := range transformer.lookups {
+				lookup.apply(tt.data, transformer.removeArrayIndices)
+			}
+			if !reflect.DeepEqual(tt.data, tt.want) {
+				t.Errorf("data = %v, want %v", tt.data, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLookupTableMissingKeyColumn(t *testing.T) {
+	file := writeLookupFile(t, "nodes.csv", "node,rack\nn1,r1\n")
+	if _, err := loadLookupTable(config.LookupConfig{File: file, KeyField: "node", KeyColumn: "host"}); err == nil {
+		t.Fatal("expected an error for a missing key column")
+	}
+}
@@ -0,0 +1,97 @@
+package transform
+
+import (
+	"slices"
+	"sort"
+	"sync"
+)
+
+// schemaKeyExpiryRuns is how many runs a flattened key may go unseen before the schema cache
+// forgets it at its next sweep, which runs every schemaKeyExpiryRuns runs. This bounds the
+// cache when keys embed changing values such as timestamps or ids
+const schemaKeyExpiryRuns = 10
+
+// schemaCache remembers the CSV column of every flattened key seen across recent runs, so
+// stable mappings skip re-deriving columns and re-sorting headers. Keys not seen before are
+// analyzed and added, which invalidates the cached headers
+type schemaCache struct {
+	columns   map[string]*schemaColumn // Flattened key -> CSV column
+	headers   []string                 // Sorted columns of the last run
+	headerSet map[string]bool
+	run       uint64 // Runs analyzed so far
+	mutex     sync.Mutex
+}
+
+// schemaColumn is the cached column of a flattened key
+type schemaColumn struct {
+	column   string
+	lastSeen uint64 // Run the key last appeared in
+}
+
+// newSchemaCache creates an empty schema cache
+func newSchemaCache() *schemaCache {
+	return &schemaCache{
+		columns:   make(map[string]*schemaColumn),
+		headerSet: make(map[string]bool),
+	}
+}
+
+// newSchemaCacheIf returns a new schema cache if enabled, or nil
+func newSchemaCacheIf(enabled bool) *schemaCache {
+	if !enabled {
+		return nil
+	}
+	return newSchemaCache()
+}
+
+// uniqueKeys returns the sorted columns of results, deriving the column of unseen keys with
+// column. The cached headers are reused when the run has exactly the last run's columns
+func (c *schemaCache) uniqueKeys(results []*TransformedResult, column func(key string) string) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.run++
+	columnSet := make(map[string]bool, len(c.headerSet))
+	changed := false
+	for _, result := range results {
+		for key := range result.TransformedData {
+			cached, seen := c.columns[key]
+			if !seen {
+				cached = &schemaColumn{column: column(key)}
+				c.columns[key] = cached
+			}
+			cached.lastSeen = c.run
+			col := cached.column
+			if !columnSet[col] {
+				columnSet[col] = true
+				// A known key may still map to a column the last run lacked
+				if !c.headerSet[col] {
+					changed = true
+				}
+			}
+		}
+	}
+
+	// Columns the last run had but this one lacks also change the headers
+	if changed || len(columnSet) != len(c.headers) {
+		headers := make([]string, 0, len(columnSet))
+		for col := range columnSet {
+			headers = append(headers, col)
+		}
+		sort.Strings(headers)
+		c.headers = headers
+		c.headerSet = columnSet
+	}
+
+	// Sweep periodically rather than every run, as a sweep visits every cached key
+	if c.run%schemaKeyExpiryRuns == 0 {
+		for key, cached := range c.columns {
+			if c.run-cached.lastSeen >= schemaKeyExpiryRuns {
+				delete(c.columns, key)
+			}
+		}
+	}
+
+	// Callers own the returned headers
+	return slices.Clone(c.headers)
+}
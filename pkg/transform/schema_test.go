@@ -0,0 +1,74 @@
+package transform
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// schemaRun returns a result whose transformed data has keys
+func schemaRun(keys ...string) []*TransformedResult {
+	data := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		data[key] = 1.0
+	}
+	return []*TransformedResult{{TransformedData: data}}
+}
+
+func TestSchemaCacheUniqueKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		runs [][]string
+		want []string
+	}{
+		{"sorted columns", [][]string{{"b.0", "a"}}, []string{"a", "b"}},
+		{"new key", [][]string{{"a"}, {"a", "b"}}, []string{"a", "b"}},
+		{"key dropped", [][]string{{"a", "b"}, {"a"}}, []string{"a"}},
+		{"keys sharing a column", [][]string{{"b.0", "b.1"}}, []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newSchemaCache()
+			var got []string
+			for _, keys := range tt.runs {
+				got = cache.uniqueKeys(schemaRun(keys...), func(key string) string {
+					return strings.Split(key, ".")[0]
+				})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("headers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaCacheExpiresUnseenKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		unseenRuns  int
+		wantCached  bool
+		wantColumns int
+	}{
+		{"recently seen", schemaKeyExpiryRuns - 2, true, 2},
+		{"unseen past the next sweep", 2 * schemaKeyExpiryRuns, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newSchemaCache()
+			identity := func(key string) string { return key }
+			cache.uniqueKeys(schemaRun("stable", "ts_1700000000"), identity)
+			for i := 0; i < tt.unseenRuns; i++ {
+				cache.uniqueKeys(schemaRun("stable"), identity)
+			}
+
+			if _, cached := cache.columns["ts_1700000000"]; cached != tt.wantCached {
+				t.Errorf("unseen key cached = %v, want %v", cached, tt.wantCached)
+			}
+			if len(cache.columns) != tt.wantColumns {
+				t.Errorf("cache holds %d keys, want %d", len(cache.columns), tt.wantColumns)
+			}
+		})
+	}
+}
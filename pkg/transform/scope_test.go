@@ -0,0 +1,96 @@
+package transform
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/extract"
+)
+
+func TestConversionScopedToQuery(t *testing.T) {
+	// Both queries return the same document; only the conversions differ
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"bytes":2048,"ratio":0.5}`)
+	}))
+	defer server.Close()
+
+	extractor, err := extract.NewExtractor(config.ExtractConfig{
+		URLs:               []string{server.URL, server.URL},
+		ClusterNames:       []string{"shared", "sizes"},
+		ElasticsearchQuery: `{"query":{"match_all":{}}}`,
+		QueryOverrides:     map[string]string{"sizes": `{"query":{"term":{"kind":"size"}}}`},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		queries []string
+		want    map[string]interface{} // bytes per cluster
+	}{
+		{"unscoped", nil, map[string]interface{}{"shared": 2.0, "sizes": 2.0}},
+		{"override query", []string{"sizes"}, map[string]interface{}{"shared": 2048.0, "sizes": 2.0}},
+		{"shared query", []string{config.DefaultQueryName}, map[string]interface{}{"shared": 2.0, "sizes": 2048.0}},
+		{"unknown query", []string{"other"}, map[string]interface{}{"shared": 2048.0, "sizes": 2048.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transformer := newTestTransformer(t, config.TransformConfig{
+				ConversionFunctions: []config.ConversionFunctionConfig{
+					{Field: "bytes", Function: "convert_to_kb", FromUnit: "bytes", Literal: true, Queries: tt.queries},
+				},
+			})
+			transformed, err := transformer.Transform(results)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, result := range transformed {
+				cluster, _ := result.Metadata["cluster_name"].(string)
+				var bytes interface{}
+				for key, value := range result.TransformedData {
+					if strings.HasSuffix(key, "bytes") {
+						bytes = value
+					}
+				}
+				if bytes != tt.want[cluster] {
+					t.Errorf("cluster %s: bytes = %v (%T), want %v", cluster, bytes, bytes, tt.want[cluster])
+				}
+			}
+		})
+	}
+}
+
+func TestAppliesToQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		queries   []string
+		queryName string
+		want      bool
+	}{
+		{"no scope", nil, "a", true},
+		{"listed", []string{"a", "b"}, "b", true},
+		{"not listed", []string{"a"}, "b", false},
+		{"unnamed result is the shared query", []string{config.DefaultQueryName}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := appliesToQuery(config.ConversionFunctionConfig{Queries: tt.queries}, tt.queryName)
+			if got != tt.want {
+				t.Errorf("appliesToQuery(%v, %q) = %v, want %v", tt.queries, tt.queryName, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,20 @@
 package transform
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
+	"math/rand"
 	"reflect"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
 	"elasticetl/pkg/config"
 	"elasticetl/pkg/extract"
@@ -21,50 +28,141 @@ type TransformedResult struct {
 	CSVHeaders      []string               `json:"csv_headers,omitempty"` // CSV column headers
 }
 
+// Stats counts what a transform did, for diagnosing missing rows or fields
+type Stats struct {
+	InputRecords    int64 `json:"input_records"`
+	OutputRecords   int64 `json:"output_records"`
+	RowsGenerated   int64 `json:"rows_generated"`   // CSV rows produced, after sampling, summarizing and pivoting
+	FieldsDropped   int64 `json:"fields_dropped"`   // Extracted fields missing from the transformed records
+	FieldsConverted int64 `json:"fields_converted"` // Extracted fields whose value was changed
+}
+
+// Add adds other's counts to s
+func (s *Stats) Add(other Stats) {
+	s.InputRecords += other.InputRecords
+	s.OutputRecords += other.OutputRecords
+	s.RowsGenerated += other.RowsGenerated
+	s.FieldsDropped += other.FieldsDropped
+	s.FieldsConverted += other.FieldsConverted
+}
+
+// ConversionFunc converts a single field value for a registered custom conversion function
+type ConversionFunc func(value interface{}, cfg config.ConversionFunctionConfig) (interface{}, error)
+
+// customFunctions holds conversion functions registered in addition to the built-in ones
+var (
+	customFunctionsMutex sync.RWMutex
+	customFunctions      = make(map[string]ConversionFunc)
+)
+
+// RegisterConversionFunction registers a custom conversion function under name, making the
+// name valid in configuration. It must be called before the configuration is loaded
+func RegisterConversionFunction(name string, fn ConversionFunc) {
+	customFunctionsMutex.Lock()
+	customFunctions[name] = fn
+	customFunctionsMutex.Unlock()
+
+	config.RegisterConversionFunction(name)
+}
+
 // Transformer handles data transformation
 type Transformer struct {
 	config          config.TransformConfig
 	previousResults [][]*TransformedResult
+	lookups         []*lookupTable
+	counters        map[string]counterSample // Last raw values for delta and rate
+	schema          *schemaCache             // Set when cache_schema is enabled
 	mutex           sync.RWMutex
 }
 
-// NewTransformer creates a new transformer
-func NewTransformer(cfg config.TransformConfig) *Transformer {
+// NewTransformer creates a new transformer, loading any configured lookup tables
+func NewTransformer(cfg config.TransformConfig) (*Transformer, error) {
+	lookups, err := loadLookupTables(cfg.Lookups)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Transformer{
 		config:          cfg,
 		previousResults: make([][]*TransformedResult, 0, cfg.PreviousResultsSets),
-	}
+		lookups:         lookups,
+		counters:        make(map[string]counterSample),
+		schema:          newSchemaCacheIf(cfg.CacheSchema),
+	}, nil
 }
 
 // Transform performs data transformation
 func (t *Transformer) Transform(results []*extract.Result) ([]*TransformedResult, error) {
+	transformedResults, _, err := t.TransformWithStats(results)
+	return transformedResults, err
+}
+
+// TransformWithStats performs data transformation and reports what it did
+func (t *Transformer) TransformWithStats(results []*extract.Result) ([]*TransformedResult, Stats, error) {
 	var transformedResults []*TransformedResult
+	stats := Stats{InputRecords: int64(len(results))}
+	t.pruneCounters(time.Now())
 
 	for _, result := range results {
-		transformed, err := t.transformSingle(result)
+		transformed, err := t.transformSingle(result, &stats)
 		if err != nil {
-			return nil, fmt.Errorf("failed to transform result from %s: %w", result.Source, err)
+			return nil, Stats{}, fmt.Errorf("failed to transform result from %s: %w", result.Source, err)
 		}
 		transformedResults = append(transformedResults, transformed)
 	}
 
 	// Convert to CSV format if requested
-	if t.config.OutputFormat == "csv" {
+	if t.generatesCSV() {
 		if err := t.convertToCSV(transformedResults); err != nil {
-			return nil, fmt.Errorf("failed to convert to CSV: %w", err)
+			return nil, Stats{}, fmt.Errorf("failed to convert to CSV: %w", err)
+		}
+
+		// Drop duplicate rows across the batch
+		if len(t.config.DedupeBy) > 0 {
+			t.dedupeCSVRows(transformedResults)
+		}
+
+		// Reduce each series to a single summary row
+		if t.config.Summarize != nil {
+			if err := t.summarizeCSV(transformedResults); err != nil {
+				return nil, Stats{}, fmt.Errorf("failed to summarize CSV: %w", err)
+			}
+		}
+
+		// Reshape long rows into wide form if requested
+		if t.config.Pivot != nil {
+			if err := t.pivotCSV(transformedResults); err != nil {
+				return nil, Stats{}, fmt.Errorf("failed to pivot CSV: %w", err)
+			}
+		}
+
+		for _, result := range transformedResults {
+			stats.RowsGenerated += int64(len(result.CSVData))
 		}
 	}
 
+	// Drop duplicate records across the batch
+	if !t.generatesCSV() && len(t.config.DedupeBy) > 0 {
+		transformedResults = t.dedupeResults(transformedResults)
+	}
+
 	// Store results if not stateless
 	if !t.config.Stateless {
 		t.storePreviousResults(transformedResults)
 	}
 
-	return transformedResults, nil
+	stats.OutputRecords = int64(len(transformedResults))
+	return transformedResults, stats, nil
 }
 
-// transformSingle transforms a single result
-func (t *Transformer) transformSingle(result *extract.Result) (*TransformedResult, error) {
+// generatesCSV reports whether CSV data is produced, either because the output format is
+// csv or because a load stream consumes CSV data
+func (t *Transformer) generatesCSV() bool {
+	return t.config.OutputFormat == "csv" || t.config.GenerateCSV
+}
+
+// transformSingle transforms a single result, counting its dropped and converted fields in stats
+func (t *Transformer) transformSingle(result *extract.Result, stats *Stats) (*TransformedResult, error) {
 	transformedData := make(map[string]interface{})
 
 	// Copy original data
@@ -77,19 +175,114 @@ func (t *Transformer) transformSingle(result *extract.Result) (*TransformedResul
 		t.substituteZerosForNull(transformedData)
 	}
 
+	// Enrich from lookup tables
+	t.mutex.RLock()
+	lookups := t.lookups
+	t.mutex.RUnlock()
+	for _, lookup := range lookups {
+		lookup.apply(transformedData, t.removeArrayIndices)
+	}
+
+	// Inject defaults for configured fields missing from the record; explicit nulls are
+	// left to SubstituteZerosForNull
+	for field, defaultValue := range t.config.DefaultFields {
+		if _, exists := transformedData[field]; !exists {
+			transformedData[field] = defaultValue
+		}
+	}
+
 	// Apply conversion functions
+	clusterName, _ := result.Metadata["cluster_name"].(string)
+	queryName, _ := result.Metadata["query_name"].(string)
 	for _, convFunc := range t.config.ConversionFunctions {
+		if !appliesToCluster(convFunc, clusterName) || !appliesToSource(convFunc, result.Source) || !appliesToQuery(convFunc, queryName) {
+			continue
+		}
+		if convFunc.Function == "parse_json" {
+			if err := t.applyParseJSON(transformedData, convFunc); err != nil {
+				return nil, fmt.Errorf("parse_json failed for field %s: %w", convFunc.Field, err)
+			}
+			continue
+		}
+		if convFunc.Function == "ratio" {
+			if err := t.applyRatio(transformedData, convFunc); err != nil {
+				return nil, fmt.Errorf("ratio failed for field %s: %w", convFunc.Field, err)
+			}
+			continue
+		}
+		if convFunc.Function == "delta" || convFunc.Function == "rate" {
+			if err := t.applyCounterDelta(transformedData, convFunc, result.Source+"\x00"+clusterName, result.Timestamp); err != nil {
+				return nil, fmt.Errorf("%s failed for field %s: %w", convFunc.Function, convFunc.Field, err)
+			}
+			continue
+		}
 		if err := t.applyConversionFunction(transformedData, convFunc); err != nil {
 			return nil, fmt.Errorf("conversion function failed for field %s: %w", convFunc.Field, err)
 		}
 	}
 
+	for key, value := range result.Data {
+		transformedValue, exists := transformedData[key]
+		switch {
+		case !exists:
+			stats.FieldsDropped++
+		case !reflect.DeepEqual(value, transformedValue):
+			stats.FieldsConverted++
+		}
+	}
+
 	return &TransformedResult{
 		Result:          result,
 		TransformedData: transformedData,
 	}, nil
 }
 
+// appliesToCluster reports whether a conversion applies to results from clusterName; a
+// conversion without a clusters list applies to every cluster
+func appliesToCluster(convFunc config.ConversionFunctionConfig, clusterName string) bool {
+	if len(convFunc.Clusters) == 0 {
+		return true
+	}
+	for _, cluster := range convFunc.Clusters {
+		if cluster == clusterName {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesToSource reports whether a conversion applies to results from the source URL; a
+// conversion without a sources list applies to every source
+func appliesToSource(convFunc config.ConversionFunctionConfig, source string) bool {
+	if len(convFunc.Sources) == 0 {
+		return true
+	}
+	for _, s := range convFunc.Sources {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesToQuery reports whether a conversion applies to results of the named query; a
+// conversion without a queries list applies to every query. Results that carry no query name
+// came from the shared query
+func appliesToQuery(convFunc config.ConversionFunctionConfig, queryName string) bool {
+	if len(convFunc.Queries) == 0 {
+		return true
+	}
+	if queryName == "" {
+		queryName = config.DefaultQueryName
+	}
+	for _, q := range convFunc.Queries {
+		if q == queryName {
+			return true
+		}
+	}
+	return false
+}
+
 // substituteZerosForNull replaces null/nil values with zeros
 func (t *Transformer) substituteZerosForNull(data map[string]interface{}) {
 	for key, value := range data {
@@ -106,10 +299,8 @@ func (t *Transformer) substituteZerosForNull(data map[string]interface{}) {
 
 // applyConversionFunction applies a conversion function to fields matching regex pattern
 func (t *Transformer) applyConversionFunction(data map[string]interface{}, convFunc config.ConversionFunctionConfig) error {
-	// Compile regex pattern for field matching
-	regex, err := regexp.Compile(convFunc.Field)
-	if err != nil {
-		// If regex is invalid, try exact match as fallback
+	// Literal fields match a single key exactly
+	if convFunc.Literal {
 		value, exists := data[convFunc.Field]
 		if !exists {
 			return nil // Field doesn't exist, skip
@@ -117,6 +308,12 @@ func (t *Transformer) applyConversionFunction(data map[string]interface{}, convF
 		return t.applyConversionToValue(data, convFunc.Field, value, convFunc)
 	}
 
+	// Compile regex pattern for field matching
+	regex, err := regexp.Compile(convFunc.Field)
+	if err != nil {
+		return fmt.Errorf("invalid field pattern %q: %w", convFunc.Field, err)
+	}
+
 	// Apply conversion to all matching fields
 	matchedAny := false
 	for key, value := range data {
@@ -166,8 +363,119 @@ func (t *Transformer) applyConversionToValue(data map[string]interface{}, fieldK
 		}
 		data[fieldKey] = converted
 
+	case "to_bool_numeric":
+		data[fieldKey] = t.toBoolNumeric(value, convFunc.TruthyValues)
+
+	case "sanitize_string":
+		if str, ok := value.(string); ok {
+			data[fieldKey] = sanitizeString(str)
+		}
+
+	case "mask":
+		if str, ok := value.(string); ok {
+			data[fieldKey] = maskString(str, convFunc)
+		}
+
 	default:
-		return fmt.Errorf("unknown conversion function: %s", convFunc.Function)
+		customFunctionsMutex.RLock()
+		fn, exists := customFunctions[convFunc.Function]
+		customFunctionsMutex.RUnlock()
+		if !exists {
+			return fmt.Errorf("unknown conversion function: %s", convFunc.Function)
+		}
+		converted, err := fn(value, convFunc)
+		if err != nil {
+			return err
+		}
+		data[fieldKey] = converted
+	}
+
+	return nil
+}
+
+// applyRatio writes Numerator / Denominator into the Field key, applying the configured
+// zero-denominator policy. Missing source fields leave the output unset
+func (t *Transformer) applyRatio(data map[string]interface{}, convFunc config.ConversionFunctionConfig) error {
+	numeratorValue, exists := data[convFunc.Numerator]
+	if !exists {
+		return nil
+	}
+	denominatorValue, exists := data[convFunc.Denominator]
+	if !exists {
+		return nil
+	}
+
+	numerator, err := t.toFloat(numeratorValue)
+	if err != nil {
+		return fmt.Errorf("numerator %s: %w", convFunc.Numerator, err)
+	}
+	denominator, err := t.toFloat(denominatorValue)
+	if err != nil {
+		return fmt.Errorf("denominator %s: %w", convFunc.Denominator, err)
+	}
+
+	// NaN leaves the field unset too: streams would otherwise parse "NaN" and push it as a
+	// sample, which remote write backends reject and Prometheus reads as a staleness marker
+	if denominator == 0 {
+		if convFunc.ZeroDenominator == config.ZeroDenominatorZero {
+			data[convFunc.Field] = 0.0
+		}
+		return nil
+	}
+
+	data[convFunc.Field] = numerator / denominator
+	return nil
+}
+
+// applyParseJSON replaces string fields matching Field with their flattened JSON content,
+// keyed under Prefix (or the source field name). Malformed JSON follows the OnError policy
+func (t *Transformer) applyParseJSON(data map[string]interface{}, convFunc config.ConversionFunctionConfig) error {
+	// Collect matching keys first since parsed fields are added to data
+	var keys []string
+	if convFunc.Literal {
+		if _, exists := data[convFunc.Field]; exists {
+			keys = append(keys, convFunc.Field)
+		}
+	} else {
+		regex, err := regexp.Compile(convFunc.Field)
+		if err != nil {
+			return fmt.Errorf("invalid field pattern %q: %w", convFunc.Field, err)
+		}
+		for key := range data {
+			if regex.MatchString(key) {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		raw, ok := data[key].(string)
+		if !ok {
+			continue // Only string fields hold serialized JSON
+		}
+
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+			switch convFunc.OnError {
+			case config.ParseErrorKeep:
+				continue
+			case config.ParseErrorDrop:
+				delete(data, key)
+				continue
+			default:
+				return fmt.Errorf("field %s holds invalid JSON: %w", key, err)
+			}
+		}
+
+		prefix := convFunc.Prefix
+		if prefix == "" {
+			prefix = key
+		}
+
+		delete(data, key)
+		for flatKey, value := range extract.FlattenJSON(parsed, prefix, t.config.ArrayIndexFormat) {
+			data[flatKey] = value
+		}
 	}
 
 	return nil
@@ -259,6 +567,13 @@ func (t *Transformer) toInt(value interface{}) (int64, error) {
 		return int64(v), nil
 	case int64:
 		return v, nil
+	case json.Number:
+		// Integers decode exactly; fall back to truncating decimals like float64
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		return int64(f), err
 	case float64:
 		return int64(v), nil
 	case string:
@@ -274,6 +589,8 @@ func (t *Transformer) toFloat(value interface{}) (float64, error) {
 		return float64(v), nil
 	case int64:
 		return float64(v), nil
+	case json.Number:
+		return v.Float64()
 	case float64:
 		return v, nil
 	case string:
@@ -291,6 +608,9 @@ func (t *Transformer) toBool(value interface{}) (bool, error) {
 		return strconv.ParseBool(v)
 	case int, int64:
 		return v != 0, nil
+	case json.Number:
+		f, err := v.Float64()
+		return f != 0, err
 	case float64:
 		return v != 0, nil
 	default:
@@ -298,6 +618,82 @@ func (t *Transformer) toBool(value interface{}) (bool, error) {
 	}
 }
 
+// defaultTruthyValues are the strings to_bool_numeric treats as true beyond those toBool parses
+var defaultTruthyValues = []string{"yes", "y", "on", "enabled"}
+
+// toBoolNumeric maps boolean-ish values to 1 or 0. With a configured truthy set only values
+// in it (compared case-insensitively) are 1; otherwise toBool decides, with yes/on/enabled
+// also accepted. Unrecognized values are 0
+func (t *Transformer) toBoolNumeric(value interface{}, truthyValues []string) int64 {
+	if len(truthyValues) > 0 {
+		text := strings.TrimSpace(fmt.Sprint(value))
+		for _, truthy := range truthyValues {
+			if strings.EqualFold(text, truthy) {
+				return 1
+			}
+		}
+		return 0
+	}
+
+	if str, ok := value.(string); ok {
+		value = strings.ToLower(strings.TrimSpace(str))
+		for _, truthy := range defaultTruthyValues {
+			if value == truthy {
+				return 1
+			}
+		}
+	}
+
+	if b, err := t.toBool(value); err == nil && b {
+		return 1
+	}
+	return 0
+}
+
+// ansiEscapePattern matches ANSI CSI escape sequences such as terminal color codes
+var ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]`)
+
+// sanitizeString removes ANSI escape sequences and control characters and replaces invalid
+// UTF-8 sequences with U+FFFD, leaving text safe for CSV and Prometheus output
+func sanitizeString(value string) string {
+	value = strings.ToValidUTF8(value, "\uFFFD")
+	value = ansiEscapePattern.ReplaceAllString(value, "")
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// redactedValue replaces string values masked in redact mode
+const redactedValue = "[REDACTED]"
+
+// defaultMaskLength is the number of characters kept by truncate masking
+const defaultMaskLength = 4
+
+// maskString hides a sensitive value according to the conversion's mask mode. Hashing is
+// deterministic for a given salt so masked values still group and join across runs
+func maskString(value string, convFunc config.ConversionFunctionConfig) string {
+	switch convFunc.MaskMode {
+	case config.MaskRedact:
+		return redactedValue
+	case config.MaskTruncate:
+		length := convFunc.MaskLength
+		if length == 0 {
+			length = defaultMaskLength
+		}
+		runes := []rune(value)
+		if len(runes) <= length {
+			return value
+		}
+		return string(runes[:length])
+	default:
+		sum := sha256.Sum256([]byte(convFunc.MaskSalt + value))
+		return hex.EncodeToString(sum[:])
+	}
+}
+
 // storePreviousResults stores results for non-stateless transformations
 func (t *Transformer) storePreviousResults(results []*TransformedResult) {
 	t.mutex.Lock()
@@ -340,6 +736,310 @@ func (t *Transformer) convertToCSV(results []*TransformedResult) error {
 	// Convert each result to CSV rows
 	for _, result := range results {
 		rows := t.generateCSVRows(result.TransformedData, uniqueKeys)
+		if t.config.SampleRows != nil {
+			rows = t.sampleRows(rows)
+		}
+		result.CSVData = rows
+	}
+
+	return nil
+}
+
+// sampleRows returns a deterministic sample of rows, sized by SampleRows.Count or
+// SampleRows.Fraction and chosen with SampleRows.Seed. Row order is preserved
+func (t *Transformer) sampleRows(rows [][]string) [][]string {
+	sample := t.config.SampleRows
+
+	size := sample.Count
+	if size <= 0 {
+		size = int(math.Ceil(float64(len(rows)) * sample.Fraction))
+	}
+	if size <= 0 || size >= len(rows) {
+		return rows
+	}
+
+	indices := rand.New(rand.NewSource(sample.Seed)).Perm(len(rows))[:size]
+	sort.Ints(indices)
+
+	sampled := make([][]string, 0, size)
+	for _, index := range indices {
+		sampled = append(sampled, rows[index])
+	}
+	return sampled
+}
+
+// dedupeKeepLast reports whether deduplication keeps the last record per key
+func (t *Transformer) dedupeKeepLast() bool {
+	return t.config.DedupeKeep == config.DedupeKeepLast
+}
+
+// dedupeCSVRows keeps one CSV row per combination of the DedupeBy columns across all
+// results. Rows lacking any of the columns are kept
+func (t *Transformer) dedupeCSVRows(results []*TransformedResult) {
+	rowKey := func(result *TransformedResult, row []string) (string, bool) {
+		parts := make([]string, 0, len(t.config.DedupeBy))
+		for _, column := range t.config.DedupeBy {
+			index := -1
+			for i, header := range result.CSVHeaders {
+				if header == column {
+					index = i
+					break
+				}
+			}
+			if index == -1 || index >= len(row) {
+				return "", false
+			}
+			parts = append(parts, row[index])
+		}
+		return strings.Join(parts, "\x00"), true
+	}
+
+	// Position of the row to keep for each key
+	type position struct{ result, row int }
+	keep := make(map[string]position)
+	for i, result := range results {
+		for j, row := range result.CSVData {
+			key, ok := rowKey(result, row)
+			if !ok {
+				continue
+			}
+			if _, seen := keep[key]; !seen || t.dedupeKeepLast() {
+				keep[key] = position{i, j}
+			}
+		}
+	}
+
+	for i, result := range results {
+		var rows [][]string
+		for j, row := range result.CSVData {
+			if key, ok := rowKey(result, row); ok && keep[key] != (position{i, j}) {
+				continue
+			}
+			rows = append(rows, row)
+		}
+		result.CSVData = rows
+	}
+}
+
+// dedupeResults keeps one result per combination of the DedupeBy fields. Results lacking
+// any of the fields are kept
+func (t *Transformer) dedupeResults(results []*TransformedResult) []*TransformedResult {
+	resultKey := func(result *TransformedResult) (string, bool) {
+		parts := make([]string, 0, len(t.config.DedupeBy))
+		for _, field := range t.config.DedupeBy {
+			value, exists := result.TransformedData[field]
+			if !exists {
+				return "", false
+			}
+			parts = append(parts, fmt.Sprintf("%v", value))
+		}
+		return strings.Join(parts, "\x00"), true
+	}
+
+	keep := make(map[string]int)
+	for i, result := range results {
+		if key, ok := resultKey(result); ok {
+			if _, seen := keep[key]; !seen || t.dedupeKeepLast() {
+				keep[key] = i
+			}
+		}
+	}
+
+	var deduped []*TransformedResult
+	for i, result := range results {
+		if key, ok := resultKey(result); ok && keep[key] != i {
+			continue
+		}
+		deduped = append(deduped, result)
+	}
+	return deduped
+}
+
+// summarizeCSV reduces the CSV rows sharing the same key columns to one row per result,
+// replacing the value column with the configured statistic. The group's last row supplies the
+// other columns; rows with a non-numeric value are ignored and groups without any are dropped.
+func (t *Transformer) summarizeCSV(results []*TransformedResult) error {
+	summarize := t.config.Summarize
+
+	for _, result := range results {
+		if len(result.CSVData) == 0 {
+			continue
+		}
+
+		columns := make(map[string]int, len(result.CSVHeaders))
+		for i, header := range result.CSVHeaders {
+			columns[header] = i
+		}
+		valueIndex, ok := columns[summarize.ValueColumn]
+		if !ok {
+			return fmt.Errorf("value column %s not found in CSV headers", summarize.ValueColumn)
+		}
+		keyIndices := make([]int, 0, len(summarize.KeyColumns))
+		for _, column := range summarize.KeyColumns {
+			index, ok := columns[column]
+			if !ok {
+				return fmt.Errorf("key column %s not found in CSV headers", column)
+			}
+			keyIndices = append(keyIndices, index)
+		}
+
+		type group struct {
+			row   []string
+			stat  float64
+			sum   float64
+			count int
+		}
+		groups := make(map[string]*group)
+		var order []string
+
+		for _, row := range result.CSVData {
+			if valueIndex >= len(row) {
+				continue
+			}
+			value, err := strconv.ParseFloat(row[valueIndex], 64)
+			if err != nil {
+				continue
+			}
+
+			parts := make([]string, len(keyIndices))
+			for i, idx := range keyIndices {
+				if idx < len(row) {
+					parts[i] = row[idx]
+				}
+			}
+			key := strings.Join(parts, "\x00")
+
+			g, exists := groups[key]
+			if !exists {
+				g = &group{stat: value}
+				groups[key] = g
+				order = append(order, key)
+			}
+			g.row = row
+			g.sum += value
+			g.count++
+
+			switch summarize.Statistic {
+			case config.SummarizeMin:
+				g.stat = math.Min(g.stat, value)
+			case config.SummarizeMax:
+				g.stat = math.Max(g.stat, value)
+			case config.SummarizeLast:
+				g.stat = value
+			}
+		}
+
+		rows := make([][]string, 0, len(order))
+		for _, key := range order {
+			g := groups[key]
+			stat := g.stat
+			switch summarize.Statistic {
+			case config.SummarizeAvg:
+				stat = g.sum / float64(g.count)
+			case config.SummarizeSum:
+				stat = g.sum
+			}
+
+			row := make([]string, len(g.row))
+			copy(row, g.row)
+			row[valueIndex] = strconv.FormatFloat(stat, 'f', -1, 64)
+			rows = append(rows, row)
+		}
+		result.CSVData = rows
+	}
+
+	return nil
+}
+
+// pivotCSV reshapes long-format CSV rows (one name and value per row) into wide form
+// with one column per distinct name. Rows sharing the remaining columns are merged and
+// missing name/row combinations are left as empty cells.
+func (t *Transformer) pivotCSV(results []*TransformedResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	headers := results[0].CSVHeaders
+	nameIndex, valueIndex := -1, -1
+	for i, header := range headers {
+		switch header {
+		case t.config.Pivot.NameColumn:
+			nameIndex = i
+		case t.config.Pivot.ValueColumn:
+			valueIndex = i
+		}
+	}
+	if nameIndex == -1 {
+		return fmt.Errorf("name column %s not found in CSV headers", t.config.Pivot.NameColumn)
+	}
+	if valueIndex == -1 {
+		return fmt.Errorf("value column %s not found in CSV headers", t.config.Pivot.ValueColumn)
+	}
+
+	// Remaining columns identify a row in the wide layout
+	var idIndices []int
+	var wideHeaders []string
+	for i, header := range headers {
+		if i != nameIndex && i != valueIndex {
+			idIndices = append(idIndices, i)
+			wideHeaders = append(wideHeaders, header)
+		}
+	}
+
+	// Collect distinct names across all results so every result shares the same headers
+	nameSet := make(map[string]bool)
+	for _, result := range results {
+		for _, row := range result.CSVData {
+			if nameIndex < len(row) && row[nameIndex] != "" {
+				nameSet[row[nameIndex]] = true
+			}
+		}
+	}
+	var names []string
+	for name := range nameSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nameColumns := make(map[string]int)
+	for i, name := range names {
+		nameColumns[name] = len(wideHeaders) + i
+	}
+	wideHeaders = append(wideHeaders, names...)
+
+	for _, result := range results {
+		wideRows := make(map[string][]string)
+		var order []string
+
+		for _, row := range result.CSVData {
+			if nameIndex >= len(row) || valueIndex >= len(row) || row[nameIndex] == "" {
+				continue
+			}
+
+			idValues := make([]string, len(idIndices))
+			for i, idx := range idIndices {
+				if idx < len(row) {
+					idValues[i] = row[idx]
+				}
+			}
+			key := strings.Join(idValues, "\x00")
+
+			wideRow, exists := wideRows[key]
+			if !exists {
+				wideRow = make([]string, len(wideHeaders))
+				copy(wideRow, idValues)
+				wideRows[key] = wideRow
+				order = append(order, key)
+			}
+			wideRow[nameColumns[row[nameIndex]]] = row[valueIndex]
+		}
+
+		rows := make([][]string, 0, len(order))
+		for _, key := range order {
+			rows = append(rows, wideRows[key])
+		}
+
+		result.CSVHeaders = wideHeaders
 		result.CSVData = rows
 	}
 
@@ -348,6 +1048,13 @@ func (t *Transformer) convertToCSV(results []*TransformedResult) error {
 
 // analyzeUniqueKeys analyzes flattened JSON keys by depth levels to determine unique column names
 func (t *Transformer) analyzeUniqueKeys(results []*TransformedResult) []string {
+	t.mutex.RLock()
+	schema := t.schema
+	t.mutex.RUnlock()
+	if schema != nil {
+		return schema.uniqueKeys(results, t.removeArrayIndices)
+	}
+
 	// Collect all flattened keys from all results
 	allKeys := make(map[string]bool)
 	for _, result := range results {
@@ -406,11 +1113,48 @@ func (t *Transformer) calculateKeyDepth(key string) int {
 
 // removeArrayIndices removes array indices from a flattened key to create unique column name
 func (t *Transformer) removeArrayIndices(key string) string {
+	if t.dotIndices() {
+		// Drop numeric segments like .0, .1, etc.; numeric object keys are quoted
+		var segments []string
+		for _, segment := range extract.SplitFlattenedKey(key) {
+			if !isArrayIndexSegment(segment) {
+				segments = append(segments, segment)
+			}
+		}
+		return strings.Join(segments, ".")
+	}
+
 	// Remove array indices like [0], [1], etc.
 	re := regexp.MustCompile(`\[\d+\]`)
 	return re.ReplaceAllString(key, "")
 }
 
+// dotIndices reports whether flattened keys use the dotted key.0 array index format
+func (t *Transformer) dotIndices() bool {
+	return t.config.ArrayIndexFormat == config.ArrayIndexFormatDot
+}
+
+// indexedPath appends an array index to a flattened path in the configured format
+func (t *Transformer) indexedPath(path string, index int) string {
+	if t.dotIndices() {
+		return fmt.Sprintf("%s.%d", path, index)
+	}
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+// isArrayIndexSegment reports whether a dotted key segment is an array index
+func isArrayIndexSegment(segment string) bool {
+	if segment == "" {
+		return false
+	}
+	for _, r := range segment {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 // generateCSVRows generates CSV rows from flattened data based on unique keys
 func (t *Transformer) generateCSVRows(data map[string]interface{}, uniqueKeys []string) [][]string {
 	// Find all array paths and their combinations
@@ -421,8 +1165,13 @@ func (t *Transformer) generateCSVRows(data map[string]interface{}, uniqueKeys []
 		row := make([]string, len(uniqueKeys))
 		for colIdx, uniqueKey := range uniqueKeys {
 			// Find matching key in data
-			if value := t.findValueForUniqueKey(data, uniqueKey); value != nil {
-				row[colIdx] = t.formatValue(value)
+			value, found := t.findValueForUniqueKey(data, uniqueKey)
+			if !found {
+				row[colIdx] = t.config.MissingValue
+				continue
+			}
+			if value != nil {
+				row[colIdx] = t.formatCell(uniqueKey, value)
 			}
 		}
 		return [][]string{row}
@@ -436,8 +1185,12 @@ func (t *Transformer) generateCSVRows(data map[string]interface{}, uniqueKeys []
 	for _, combination := range combinations {
 		row := make([]string, len(uniqueKeys))
 		for colIdx, uniqueKey := range uniqueKeys {
-			value := t.findValueForCombination(data, uniqueKey, combination)
-			row[colIdx] = t.formatValue(value)
+			value, found := t.findValueForCombination(data, uniqueKey, combination)
+			if !found {
+				row[colIdx] = t.config.MissingValue
+				continue
+			}
+			row[colIdx] = t.formatCell(uniqueKey, value)
 		}
 		rows = append(rows, row)
 	}
@@ -479,6 +1232,18 @@ func (t *Transformer) findArrayPaths(data map[string]interface{}) map[string][]i
 
 // extractArrayPathAndIndex extracts the array path and index from a flattened key
 func (t *Transformer) extractArrayPathAndIndex(key string) (string, int) {
+	if t.dotIndices() {
+		// Use the deepest numeric segment as the array index
+		segments := extract.SplitFlattenedKey(key)
+		for i := len(segments) - 1; i >= 0; i-- {
+			if isArrayIndexSegment(segments[i]) {
+				index, _ := strconv.Atoi(segments[i])
+				return strings.Join(segments[:i], "."), index
+			}
+		}
+		return "", -1
+	}
+
 	// Find array indices in the key
 	re := regexp.MustCompile(`\[(\d+)\]`)
 	matches := re.FindAllStringSubmatch(key, -1)
@@ -547,44 +1312,45 @@ func (t *Transformer) generateCombinationsRecursive(paths []string, arrayPaths m
 	return allCombinations
 }
 
-// findValueForUniqueKey finds the value for a unique key in the flattened data
-func (t *Transformer) findValueForUniqueKey(data map[string]interface{}, uniqueKey string) interface{} {
+// findValueForUniqueKey finds the value for a unique key in the flattened data, reporting
+// whether the record has the key at all
+func (t *Transformer) findValueForUniqueKey(data map[string]interface{}, uniqueKey string) (interface{}, bool) {
 	// Try exact match first
 	if value, exists := data[uniqueKey]; exists {
-		return value
+		return value, true
 	}
 
 	// Look for keys that match the unique key pattern (with array indices)
 	for key, value := range data {
 		if t.removeArrayIndices(key) == uniqueKey {
-			return value
+			return value, true
 		}
 	}
 
-	return nil
+	return nil, false
 }
 
 // findValueForCombination finds the value for a unique key with specific array index combination
-func (t *Transformer) findValueForCombination(data map[string]interface{}, uniqueKey string, combination map[string]int) interface{} {
+func (t *Transformer) findValueForCombination(data map[string]interface{}, uniqueKey string, combination map[string]int) (interface{}, bool) {
 	// Try exact match first (for non-array keys)
 	if value, exists := data[uniqueKey]; exists {
-		return value
+		return value, true
 	}
 
 	// Build the specific key with array indices from combination
 	specificKey := t.buildSpecificKey(uniqueKey, combination)
 	if value, exists := data[specificKey]; exists {
-		return value
+		return value, true
 	}
 
 	// Look for any matching key with the right pattern
 	for key, value := range data {
 		if t.matchesKeyPattern(key, uniqueKey, combination) {
-			return value
+			return value, true
 		}
 	}
 
-	return nil
+	return nil, false
 }
 
 // buildSpecificKey builds a specific key with array indices from combination
@@ -604,7 +1370,7 @@ func (t *Transformer) buildSpecificKey(uniqueKey string, combination map[string]
 	for _, path := range paths {
 		index := combination[path]
 		if strings.HasPrefix(result, path) {
-			result = strings.Replace(result, path, fmt.Sprintf("%s[%d]", path, index), 1)
+			result = strings.Replace(result, path, t.indexedPath(path, index), 1)
 		}
 	}
 
@@ -623,7 +1389,11 @@ func (t *Transformer) matchesKeyPattern(key, uniqueKey string, combination map[s
 	for path, expectedIndex := range combination {
 		if strings.Contains(key, path) {
 			// Extract the actual index from the key for this path
-			pattern := regexp.MustCompile(regexp.QuoteMeta(path) + `\[(\d+)\]`)
+			indexPattern := `\[(\d+)\]`
+			if t.dotIndices() {
+				indexPattern = `\.(\d+)(?:\.|$)`
+			}
+			pattern := regexp.MustCompile(regexp.QuoteMeta(path) + indexPattern)
 			matches := pattern.FindStringSubmatch(key)
 			if len(matches) > 1 {
 				if actualIndex, err := strconv.Atoi(matches[1]); err == nil {
@@ -714,6 +1484,42 @@ func (t *Transformer) extractColumnValues(data map[string]interface{}, column st
 	return values
 }
 
+// formatCell converts a value to string for a CSV column, applying the column's configured
+// number format to numeric values
+func (t *Transformer) formatCell(column string, value interface{}) string {
+	format, ok := t.config.ColumnFormats[column]
+	if !ok {
+		return t.formatValue(value)
+	}
+
+	var number float64
+	switch v := value.(type) {
+	case int, int32, int64, float32, float64, json.Number:
+		f, err := t.toFloat(v)
+		if err != nil {
+			return t.formatValue(value)
+		}
+		number = f
+	default:
+		return t.formatValue(value)
+	}
+
+	switch format {
+	case config.ColumnFormatInteger:
+		// Exact integers (including json.Number beyond float64 precision) keep every digit
+		if n, ok := value.(json.Number); ok {
+			if !strings.ContainsAny(n.String(), ".eE") {
+				return n.String()
+			}
+		}
+		return strconv.FormatFloat(math.Round(number), 'f', 0, 64)
+	case config.ColumnFormatShortest:
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	default:
+		return fmt.Sprintf(format, number)
+	}
+}
+
 // formatValue converts a value to string for CSV
 func (t *Transformer) formatValue(value interface{}) string {
 	if value == nil {
@@ -725,12 +1531,21 @@ func (t *Transformer) formatValue(value interface{}) string {
 		return v
 	case int, int64, int32:
 		return fmt.Sprintf("%d", v)
+	case json.Number:
+		// Integers keep every digit; decimals are formatted like float64
+		if i, err := v.Int64(); err == nil {
+			return fmt.Sprintf("%d", i)
+		}
+		if f, err := v.Float64(); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		return v.String()
 	case float64:
-		// Use fixed-point notation to preserve precision and avoid exponential form
-		return fmt.Sprintf("%.15f", v)
+		// Shortest fixed-point form that round-trips, avoiding exponential form
+		return strconv.FormatFloat(v, 'f', -1, 64)
 	case float32:
-		// Use fixed-point notation to preserve precision and avoid exponential form
-		return fmt.Sprintf("%.7f", v)
+		// Shortest fixed-point form that round-trips, avoiding exponential form
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
 	case bool:
 		if v {
 			return "true"
@@ -742,14 +1557,26 @@ func (t *Transformer) formatValue(value interface{}) string {
 }
 
 // UpdateConfig updates the transformer configuration
-func (t *Transformer) UpdateConfig(cfg config.TransformConfig) {
+func (t *Transformer) UpdateConfig(cfg config.TransformConfig) error {
+	// Reload lookup tables so edited files are picked up on config change
+	lookups, err := loadLookupTables(cfg.Lookups)
+	if err != nil {
+		return err
+	}
+
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
 	t.config = cfg
+	t.lookups = lookups
+
+	// The array index format decides columns, so cached columns start over
+	t.schema = newSchemaCacheIf(cfg.CacheSchema)
 
 	// Adjust previous results storage if needed
 	if len(t.previousResults) > cfg.PreviousResultsSets {
 		t.previousResults = t.previousResults[len(t.previousResults)-cfg.PreviousResultsSets:]
 	}
+
+	return nil
 }
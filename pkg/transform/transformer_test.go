@@ -0,0 +1,742 @@
+package transform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"elasticetl/pkg/config"
+	"elasticetl/pkg/extract"
+)
+
+// newTestTransformer creates a transformer for cfg, failing the test on error
+func newTestTransformer(t *testing.T, cfg config.TransformConfig) *Transformer {
+	t.Helper()
+	transformer, err := NewTransformer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return transformer
+}
+
+func TestApplyRatio(t *testing.T) {
+	tests := []struct {
+		name            string
+		numerator       interface{}
+		denominator     interface{}
+		zeroDenominator string
+		want            interface{} // nil means the field is left unset
+	}{
+		{"divides", 3.0, 4.0, "", 0.75},
+		{"numeric strings", "1", "2", "", 0.5},
+		{"zero denominator skipped by default", 1.0, 0.0, "", nil},
+		{"zero denominator skip", 1.0, 0.0, config.ZeroDenominatorSkip, nil},
+		{"zero denominator zero", 1.0, 0.0, config.ZeroDenominatorZero, 0.0},
+		{"zero denominator nan leaves the field unset", 1.0, 0.0, config.ZeroDenominatorNaN, nil},
+	}
+
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"hits": tt.numerator, "total": tt.denominator}
+			err := transformer.applyRatio(data, config.ConversionFunctionConfig{
+				Field:           "hit_ratio",
+				Function:        "ratio",
+				Numerator:       "hits",
+				Denominator:     "total",
+				ZeroDenominator: tt.zeroDenominator,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, exists := data["hit_ratio"]
+			if tt.want == nil {
+				if exists {
+					t.Fatalf("hit_ratio = %v, want unset", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Fatalf("hit_ratio = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRatioMissingOperand(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	data := map[string]interface{}{"hits": 1.0}
+	if err := transformer.applyRatio(data, config.ConversionFunctionConfig{Field: "r", Numerator: "hits", Denominator: "total"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := data["r"]; exists {
+		t.Fatal("ratio written without a denominator")
+	}
+}
+
+func TestDotFormatArrayIndices(t *testing.T) {
+	tests := []struct {
+		key       string
+		wantKey   string
+		wantPath  string
+		wantIndex int
+	}{
+		{"hosts.1.name", "hosts.name", "hosts", 1},
+		{`hosts.2.latency."95.0"`, `hosts.latency."95.0"`, "hosts", 2},
+		{`status."200".count`, `status."200".count`, "", -1},
+		{`ips."10.0.0.1".5`, `ips."10.0.0.1"`, `ips."10.0.0.1"`, 5},
+	}
+
+	transformer := newTestTransformer(t, config.TransformConfig{ArrayIndexFormat: config.ArrayIndexFormatDot})
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := transformer.removeArrayIndices(tt.key); got != tt.wantKey {
+				t.Errorf("removeArrayIndices(%s) = %s, want %s", tt.key, got, tt.wantKey)
+			}
+			path, index := transformer.extractArrayPathAndIndex(tt.key)
+			if path != tt.wantPath || index != tt.wantIndex {
+				t.Errorf("extractArrayPathAndIndex(%s) = %s, %d; want %s, %d", tt.key, path, index, tt.wantPath, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestPivotCSV(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Pivot: &config.PivotConfig{NameColumn: "metric", ValueColumn: "value"},
+	})
+	results := []*TransformedResult{
+		{
+			CSVHeaders: []string{"host", "metric", "value"},
+			CSVData: [][]string{
+				{"a", "cpu", "10"},
+				{"a", "mem", "20"},
+				{"b", "cpu", "30"},
+			},
+		},
+		{
+			CSVHeaders: []string{"host", "metric", "value"},
+			CSVData:    [][]string{{"c", "disk", "40"}},
+		},
+	}
+
+	if err := transformer.pivotCSV(results); err != nil {
+		t.Fatal(err)
+	}
+
+	// Names from every result become columns; combinations a host lacks stay empty
+	wantHeaders := []string{"host", "cpu", "disk", "mem"}
+	wantRows := [][][]string{
+		{{"a", "10", "", "20"}, {"b", "30", "", ""}},
+		{{"c", "", "40", ""}},
+	}
+	for i, result := range results {
+		if !reflect.DeepEqual(result.CSVHeaders, wantHeaders) {
+			t.Errorf("result %d headers = %v, want %v", i, result.CSVHeaders, wantHeaders)
+		}
+		if !reflect.DeepEqual(result.CSVData, wantRows[i]) {
+			t.Errorf("result %d rows = %v, want %v", i, result.CSVData, wantRows[i])
+		}
+	}
+}
+
+func TestPivotCSVMissingColumn(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Pivot: &config.PivotConfig{NameColumn: "metric", ValueColumn: "count"},
+	})
+	results := []*TransformedResult{{CSVHeaders: []string{"metric", "value"}, CSVData: [][]string{{"cpu", "1"}}}}
+	if err := transformer.pivotCSV(results); err == nil {
+		t.Fatal("expected an error for a missing value column")
+	}
+}
+
+func TestApplyConversionFunctionLiteral(t *testing.T) {
+	tests := []struct {
+		name      string
+		field     string
+		literal   bool
+		converted []string // Keys converted to KB
+	}{
+		{"regex", `^disk\..*bytes$`, false, []string{"disk.read_bytes", "disk.write_bytes"}},
+		{"literal", "disk.read_bytes", true, []string{"disk.read_bytes"}},
+		{"literal with regex characters", `disk.*`, true, nil},
+	}
+
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]interface{}{"disk.read_bytes": 1024.0, "disk.write_bytes": 2048.0, "net_bytes": 4096.0}
+			convFunc := config.ConversionFunctionConfig{Field: tt.field, Literal: tt.literal, Function: "convert_to_kb", FromUnit: "bytes"}
+			if err := transformer.applyConversionFunction(data, convFunc); err != nil {
+				t.Fatal(err)
+			}
+
+			var converted []string
+			for _, key := range []string{"disk.read_bytes", "disk.write_bytes", "net_bytes"} {
+				if data[key].(float64) < 1000 {
+					converted = append(converted, key)
+				}
+			}
+			if !reflect.DeepEqual(converted, tt.converted) {
+				t.Errorf("converted %v, want %v", converted, tt.converted)
+			}
+		})
+	}
+}
+
+func TestDefaultFields(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Stateless:     true,
+		DefaultFields: map[string]interface{}{"disk.used": 0.0, "rack": "unknown", "heap": 1.0},
+	})
+	results, err := transformer.Transform([]*extract.Result{{
+		Data: map[string]interface{}{"rack": "r1", "heap": nil},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The missing field gets its default; present values and explicit nulls are left alone
+	want := map[string]interface{}{"disk.used": 0.0, "rack": "r1", "heap": nil}
+	if got := results[0].TransformedData; !reflect.DeepEqual(got, want) {
+		t.Errorf("transformed data = %v, want %v", got, want)
+	}
+}
+
+func TestApplyParseJSON(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+
+	data := map[string]interface{}{"payload": `{"a":1,"b":{"c":"x"}}`, "count": 5.0}
+	if err := transformer.applyParseJSON(data, config.ConversionFunctionConfig{Field: "payload", Literal: true, Function: "parse_json"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"payload.a": 1.0, "payload.b.c": "x", "count": 5.0}
+	if !reflect.DeepEqual(data, want) {
+		t.Errorf("data = %v, want %v", data, want)
+	}
+
+	data = map[string]interface{}{"payload": `{"a":1}`}
+	if err := transformer.applyParseJSON(data, config.ConversionFunctionConfig{Field: "payload", Literal: true, Function: "parse_json", Prefix: "p"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"p.a": 1.0}; !reflect.DeepEqual(data, want) {
+		t.Errorf("with prefix: data = %v, want %v", data, want)
+	}
+
+	// Values that are not strings are not JSON documents and are left alone
+	data = map[string]interface{}{"payload": 5.0}
+	if err := transformer.applyParseJSON(data, config.ConversionFunctionConfig{Field: "payload", Literal: true, Function: "parse_json"}); err != nil {
+		t.Fatal(err)
+	}
+	if data["payload"] != 5.0 {
+		t.Errorf("non-string payload changed to %v", data["payload"])
+	}
+}
+
+func TestApplyParseJSONOnError(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	parse := func(onError string) (map[string]interface{}, error) {
+		data := map[string]interface{}{"payload": `{"a":`}
+		err := transformer.applyParseJSON(data, config.ConversionFunctionConfig{Field: "payload", Literal: true, Function: "parse_json", OnError: onError})
+		return data, err
+	}
+
+	if _, err := parse(""); err == nil {
+		t.Error("malformed JSON did not fail by default")
+	}
+	if _, err := parse(config.ParseErrorFail); err == nil {
+		t.Error("malformed JSON did not fail with on_error fail")
+	}
+
+	data, err := parse(config.ParseErrorKeep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data["payload"] != `{"a":` {
+		t.Errorf("on_error keep: payload = %v, want the original string", data["payload"])
+	}
+
+	data, err = parse(config.ParseErrorDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 0 {
+		t.Errorf("on_error drop: data = %v, want the field removed", data)
+	}
+}
+
+func TestDedupeCSVRows(t *testing.T) {
+	newResults := func() []*TransformedResult {
+		return []*TransformedResult{
+			{
+				CSVHeaders: []string{"node", "value"},
+				CSVData:    [][]string{{"n1", "1"}, {"n2", "2"}, {"n1", "3"}},
+			},
+			{
+				CSVHeaders: []string{"node", "value"},
+				CSVData:    [][]string{{"n2", "4"}, {"n3", "5"}},
+			},
+		}
+	}
+
+	first := newTestTransformer(t, config.TransformConfig{DedupeBy: []string{"node"}})
+	results := newResults()
+	first.dedupeCSVRows(results)
+	if want := [][]string{{"n1", "1"}, {"n2", "2"}}; !reflect.DeepEqual(results[0].CSVData, want) {
+		t.Errorf("keep first: result 0 rows = %v, want %v", results[0].CSVData, want)
+	}
+	if want := [][]string{{"n3", "5"}}; !reflect.DeepEqual(results[1].CSVData, want) {
+		t.Errorf("keep first: result 1 rows = %v, want %v", results[1].CSVData, want)
+	}
+
+	last := newTestTransformer(t, config.TransformConfig{DedupeBy: []string{"node"}, DedupeKeep: config.DedupeKeepLast})
+	results = newResults()
+	last.dedupeCSVRows(results)
+	if want := [][]string{{"n1", "3"}}; !reflect.DeepEqual(results[0].CSVData, want) {
+		t.Errorf("keep last: result 0 rows = %v, want %v", results[0].CSVData, want)
+	}
+	if want := [][]string{{"n2", "4"}, {"n3", "5"}}; !reflect.DeepEqual(results[1].CSVData, want) {
+		t.Errorf("keep last: result 1 rows = %v, want %v", results[1].CSVData, want)
+	}
+}
+
+func TestDedupeResults(t *testing.T) {
+	data := []map[string]interface{}{
+		{"node": "n1", "value": 1.0},
+		{"node": "n1", "value": 2.0},
+		{"value": 3.0},
+	}
+	results := make([]*extract.Result, len(data))
+	for i := range data {
+		results[i] = &extract.Result{Data: data[i]}
+	}
+
+	for keep, want := range map[string][]float64{"": {1, 3}, config.DedupeKeepLast: {2, 3}} {
+		transformer := newTestTransformer(t, config.TransformConfig{Stateless: true, DedupeBy: []string{"node"}, DedupeKeep: keep})
+		transformed, err := transformer.Transform(results)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Records lacking the key field are never duplicates
+		var values []float64
+		for _, result := range transformed {
+			values = append(values, result.TransformedData["value"].(float64))
+		}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("dedupe_keep %q: kept values %v, want %v", keep, values, want)
+		}
+	}
+}
+
+func TestSampleRows(t *testing.T) {
+	rows := make([][]string, 10)
+	for i := range rows {
+		rows[i] = []string{strconv.Itoa(i)}
+	}
+	sample := func(cfg config.SampleRowsConfig) [][]string {
+		return newTestTransformer(t, config.TransformConfig{SampleRows: &cfg}).sampleRows(rows)
+	}
+
+	first := sample(config.SampleRowsConfig{Count: 4, Seed: 7})
+	if len(first) != 4 {
+		t.Fatalf("count 4 kept %d rows", len(first))
+	}
+	if again := sample(config.SampleRowsConfig{Count: 4, Seed: 7}); !reflect.DeepEqual(again, first) {
+		t.Errorf("same seed sampled %v then %v", first, again)
+	}
+	for i := 1; i < len(first); i++ {
+		previous, _ := strconv.Atoi(first[i-1][0])
+		current, _ := strconv.Atoi(first[i][0])
+		if previous >= current {
+			t.Errorf("sample %v does not preserve row order", first)
+			break
+		}
+	}
+
+	if got := sample(config.SampleRowsConfig{Fraction: 0.25, Seed: 7}); len(got) != 3 {
+		t.Errorf("fraction 0.25 of 10 rows kept %d, want 3", len(got))
+	}
+	if got := sample(config.SampleRowsConfig{Count: 20}); !reflect.DeepEqual(got, rows) {
+		t.Errorf("count above the row count changed the rows: %v", got)
+	}
+}
+
+func TestSampleRowsKeepsHeaders(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Stateless:    true,
+		OutputFormat: "csv",
+		SampleRows:   &config.SampleRowsConfig{Count: 1, Seed: 1},
+	})
+	results, err := transformer.Transform([]*extract.Result{{
+		Data: map[string]interface{}{"nodes[0].name": "a", "nodes[1].name": "b", "nodes[2].name": "c"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[0].CSVData) != 1 {
+		t.Errorf("got %d rows, want 1", len(results[0].CSVData))
+	}
+	if len(results[0].CSVHeaders) == 0 {
+		t.Error("sampling dropped the CSV headers")
+	}
+}
+
+func TestConversionScopedToCluster(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Stateless: true,
+		ConversionFunctions: []config.ConversionFunctionConfig{
+			{Field: "bytes", Literal: true, Function: "convert_to_kb", FromUnit: "bytes", Clusters: []string{"legacy"}},
+		},
+	})
+	results, err := transformer.Transform([]*extract.Result{
+		{Data: map[string]interface{}{"bytes": 2048.0}, Metadata: map[string]interface{}{"cluster_name": "legacy"}},
+		{Data: map[string]interface{}{"bytes": 2048.0}, Metadata: map[string]interface{}{"cluster_name": "current"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := results[0].TransformedData["bytes"]; got != 2.0 {
+		t.Errorf("legacy cluster bytes = %v, want converted to 2 KB", got)
+	}
+	if got := results[1].TransformedData["bytes"]; got != 2048.0 {
+		t.Errorf("current cluster bytes = %v, want left at 2048", got)
+	}
+}
+
+func TestJSONNumberKeepsCounterExact(t *testing.T) {
+	// 2^53 + 1 cannot be represented as a float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"docs":{"count":9007199254740993}}`)
+	}))
+	defer server.Close()
+
+	extractor, err := extract.NewExtractor(config.ExtractConfig{
+		URLs:               []string{server.URL},
+		ClusterNames:       []string{"es"},
+		ElasticsearchQuery: `{"query":{"match_all":{}}}`,
+		UseJSONNumber:      true,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := extractor.Extract(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transformer := newTestTransformer(t, config.TransformConfig{Stateless: true, OutputFormat: "csv"})
+	transformed, err := transformer.Transform(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := transformed[0].CSVData; len(got) != 1 || len(got[0]) != 1 || got[0][0] != "9007199254740993" {
+		t.Errorf("CSV rows = %v, want the exact counter", got)
+	}
+
+	count, err := transformer.toInt(transformed[0].TransformedData["docs.count"])
+	if err != nil || count != 9007199254740993 {
+		t.Errorf("toInt = %d, %v; want 9007199254740993", count, err)
+	}
+}
+
+func TestColumnFormats(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Stateless:    true,
+		OutputFormat: "csv",
+		ColumnFormats: map[string]string{
+			"latency_ms": "%.3f",
+			"doc_count":  config.ColumnFormatInteger,
+			"host":       config.ColumnFormatInteger,
+		},
+	})
+	results := []*extract.Result{{
+		Source:   "http://es:9200",
+		Metadata: map[string]interface{}{},
+		Data: map[string]interface{}{
+			"latency_ms": 12.34567,
+			"doc_count":  41.6,
+			"ratio":      0.125,
+			"host":       "web-1",
+		},
+	}}
+
+	transformed, err := transformer.Transform(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transformed[0].CSVData) != 1 {
+		t.Fatalf("CSV rows = %v, want one", transformed[0].CSVData)
+	}
+	row := make(map[string]string)
+	for i, header := range transformed[0].CSVHeaders {
+		row[header] = transformed[0].CSVData[0][i]
+	}
+
+	// Unlisted columns use the shortest round-trip form; formats leave strings alone
+	want := map[string]string{"latency_ms": "12.346", "doc_count": "42", "ratio": "0.125", "host": "web-1"}
+	for column, value := range want {
+		if row[column] != value {
+			t.Errorf("%s = %q, want %q", column, row[column], value)
+		}
+	}
+}
+
+func TestConvertType(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	convert := func(value interface{}, convFunc config.ConversionFunctionConfig) (interface{}, error) {
+		data := map[string]interface{}{"field": value}
+		err := transformer.applyConversionToValue(data, "field", value, convFunc)
+		return data["field"], err
+	}
+	toType := func(toType string) config.ConversionFunctionConfig {
+		return config.ConversionFunctionConfig{Function: "convert_type", ToType: toType}
+	}
+
+	if got, err := convert(json.Number("9007199254740993"), toType("int")); err != nil || got != int64(9007199254740993) {
+		t.Errorf("json.Number to int = %v (%T), %v", got, got, err)
+	}
+	if got, err := convert(json.Number("0.25"), toType("float")); err != nil || got != 0.25 {
+		t.Errorf("json.Number to float = %v (%T), %v", got, got, err)
+	}
+	if got, err := convert("17", toType("int")); err != nil || got != int64(17) {
+		t.Errorf("string to int = %v (%T), %v", got, got, err)
+	}
+	if got, err := convert(42.5, toType("string")); err != nil || got != "42.5" {
+		t.Errorf("float to string = %v (%T), %v", got, got, err)
+	}
+	if got, err := convert("true", toType("bool")); err != nil || got != true {
+		t.Errorf("string to bool = %v (%T), %v", got, got, err)
+	}
+	if _, err := convert("x", toType("date")); err == nil {
+		t.Error("unsupported type did not fail")
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+	convert := func(value interface{}, function, fromUnit string) (interface{}, error) {
+		data := map[string]interface{}{"field": value}
+		err := transformer.applyConversionToValue(data, "field", value, config.ConversionFunctionConfig{Function: function, FromUnit: fromUnit})
+		return data["field"], err
+	}
+
+	if got, err := convert(2048.0, "convert_to_kb", "bytes"); err != nil || got != 2.0 {
+		t.Errorf("bytes to KB = %v, %v", got, err)
+	}
+	if got, err := convert(json.Number("2"), "convert_to_mb", "gb"); err != nil || got != 2048.0 {
+		t.Errorf("json.Number GB to MB = %v, %v", got, err)
+	}
+	if got, err := convert("512", "convert_to_gb", "mb"); err != nil || got != 0.5 {
+		t.Errorf("string MB to GB = %v, %v", got, err)
+	}
+	if _, err := convert(1.0, "convert_to_kb", "tb"); err == nil {
+		t.Error("unsupported unit did not fail")
+	}
+	if _, err := convert("big", "convert_to_kb", "b"); err == nil {
+		t.Error("non-numeric size did not fail")
+	}
+}
+
+func TestToBoolNumeric(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{})
+
+	for _, value := range []interface{}{"true", " Yes ", "on", "ENABLED", "1", true, 3.0, json.Number("1")} {
+		if got := transformer.toBoolNumeric(value, nil); got != 1 {
+			t.Errorf("toBoolNumeric(%#v) = %d, want 1", value, got)
+		}
+	}
+	for _, value := range []interface{}{"false", "no", "off", "disabled", "0", false, 0.0, "maybe", nil} {
+		if got := transformer.toBoolNumeric(value, nil); got != 0 {
+			t.Errorf("toBoolNumeric(%#v) = %d, want 0", value, got)
+		}
+	}
+
+	// A configured truthy set replaces the default forms
+	truthy := []string{"green", "yellow"}
+	if got := transformer.toBoolNumeric("GREEN", truthy); got != 1 {
+		t.Errorf("toBoolNumeric(GREEN) with a truthy set = %d, want 1", got)
+	}
+	if got := transformer.toBoolNumeric("true", truthy); got != 0 {
+		t.Errorf("toBoolNumeric(true) outside the truthy set = %d, want 0", got)
+	}
+}
+
+func TestSanitizeString(t *testing.T) {
+	if got := sanitizeString("\x1b[31mred\x1b[0m\tline\r\n"); got != "redline" {
+		t.Errorf("sanitizeString(escapes and controls) = %q, want %q", got, "redline")
+	}
+	if got := sanitizeString("ok\xffbyte"); got != "ok\uFFFDbyte" {
+		t.Errorf("sanitizeString(invalid UTF-8) = %q, want %q", got, "ok\uFFFDbyte")
+	}
+	if got := sanitizeString("naïve café"); got != "naïve café" {
+		t.Errorf("sanitizeString(valid text) = %q, want it unchanged", got)
+	}
+}
+
+func TestMaskString(t *testing.T) {
+	// sha256 (default) is salted and deterministic, so masked values still group and join
+	sum := sha256.Sum256([]byte("salt" + "alice"))
+	hashed := config.ConversionFunctionConfig{Function: "mask", MaskSalt: "salt"}
+	if got := maskString("alice", hashed); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("sha256 mask = %s, want the salted hash", got)
+	}
+	if maskString("alice", hashed) != maskString("alice", hashed) {
+		t.Error("sha256 mask is not deterministic")
+	}
+
+	if got := maskString("alice", config.ConversionFunctionConfig{MaskMode: config.MaskRedact}); got != "[REDACTED]" {
+		t.Errorf("redact mask = %q, want [REDACTED]", got)
+	}
+
+	truncate := config.ConversionFunctionConfig{MaskMode: config.MaskTruncate, MaskLength: 2}
+	if got := maskString("alice", truncate); got != "al" {
+		t.Errorf("truncate mask = %q, want al", got)
+	}
+	truncate.MaskLength = 0 // Defaults to four characters, counted in runes
+	if got := maskString("josé-luis", truncate); got != "josé" {
+		t.Errorf("truncate mask = %q, want josé", got)
+	}
+	if got := maskString("bob", truncate); got != "bob" {
+		t.Errorf("truncate mask of a short value = %q, want it unchanged", got)
+	}
+}
+
+func TestSummarizeCSV(t *testing.T) {
+	buckets := func() []*TransformedResult {
+		return []*TransformedResult{{
+			CSVHeaders: []string{"host", "time", "cpu"},
+			CSVData: [][]string{
+				{"a", "1000", "10"},
+				{"b", "1000", "50"},
+				{"a", "2000", "30"},
+				{"a", "3000", "20"},
+				{"b", "2000", "n/a"},
+				{"b", "3000", "40"},
+			},
+		}}
+	}
+
+	// Each group keeps its last row's other columns; non-numeric values are ignored
+	want := map[string][][]string{
+		config.SummarizeMin:   {{"a", "3000", "10"}, {"b", "3000", "40"}},
+		config.SummarizeMax:   {{"a", "3000", "30"}, {"b", "3000", "50"}},
+		config.SummarizeAvg:   {{"a", "3000", "20"}, {"b", "3000", "45"}},
+		config.SummarizeSum:   {{"a", "3000", "60"}, {"b", "3000", "90"}},
+		config.SummarizeFirst: {{"a", "3000", "10"}, {"b", "3000", "50"}},
+		config.SummarizeLast:  {{"a", "3000", "20"}, {"b", "3000", "40"}},
+	}
+	for statistic, rows := range want {
+		transformer := newTestTransformer(t, config.TransformConfig{
+			Summarize: &config.SummarizeConfig{KeyColumns: []string{"host"}, ValueColumn: "cpu", Statistic: statistic},
+		})
+		results := buckets()
+		if err := transformer.summarizeCSV(results); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(results[0].CSVData, rows) {
+			t.Errorf("%s: rows = %v, want %v", statistic, results[0].CSVData, rows)
+		}
+	}
+}
+
+func TestSummarizeCSVMissingColumn(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Summarize: &config.SummarizeConfig{KeyColumns: []string{"node"}, ValueColumn: "cpu", Statistic: config.SummarizeAvg},
+	})
+	results := []*TransformedResult{{CSVHeaders: []string{"host", "cpu"}, CSVData: [][]string{{"a", "1"}}}}
+	if err := transformer.summarizeCSV(results); err == nil {
+		t.Fatal("expected an error for a missing key column")
+	}
+}
+
+func TestTransformWithStats(t *testing.T) {
+	transformer := newTestTransformer(t, config.TransformConfig{
+		Stateless:    true,
+		OutputFormat: "csv",
+		ConversionFunctions: []config.ConversionFunctionConfig{
+			{Field: "payload", Literal: true, Function: "parse_json", OnError: config.ParseErrorDrop},
+			{Field: "size", Literal: true, Function: "convert_to_kb", FromUnit: "bytes"},
+		},
+	})
+
+	results := []*extract.Result{
+		{Data: map[string]interface{}{
+			"hosts[0].name": "a",
+			"hosts[1].name": "b",
+			"hosts[2].name": "c",
+			"payload":       "{not json",
+			"size":          2048.0,
+		}},
+		{Data: map[string]interface{}{"size": 1024.0}},
+	}
+	_, stats, err := transformer.TransformWithStats(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first record explodes into a row per host; the invalid payload is dropped and
+	// both sizes are converted
+	want := Stats{InputRecords: 2, OutputRecords: 2, RowsGenerated: 4, FieldsDropped: 1, FieldsConverted: 2}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+
+	var total Stats
+	total.Add(stats)
+	total.Add(stats)
+	if total.RowsGenerated != 8 || total.FieldsDropped != 2 {
+		t.Errorf("Add twice = %+v, want doubled counts", total)
+	}
+}
+
+func TestMissingValue(t *testing.T) {
+	results := func() []*extract.Result {
+		return []*extract.Result{
+			{Data: map[string]interface{}{"host": "a", "note": ""}},
+			{Data: map[string]interface{}{"host": "b"}},
+		}
+	}
+	cells := func(transformed []*TransformedResult) []string {
+		t.Helper()
+		column := -1
+		for i, header := range transformed[0].CSVHeaders {
+			if header == "note" {
+				column = i
+			}
+		}
+		if column < 0 {
+			t.Fatalf("headers %v lack the note column", transformed[0].CSVHeaders)
+		}
+		return []string{transformed[0].CSVData[0][column], transformed[1].CSVData[0][column]}
+	}
+
+	// A record lacking the column gets the token; a present empty string stays empty
+	marked := newTestTransformer(t, config.TransformConfig{Stateless: true, OutputFormat: "csv", MissingValue: `\N`})
+	transformed, err := marked.Transform(results())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cells(transformed), []string{"", `\N`}; !reflect.DeepEqual(got, want) {
+		t.Errorf("note cells with missing_value = %q, want %q", got, want)
+	}
+
+	// Without missing_value both cases are empty, as before
+	unmarked := newTestTransformer(t, config.TransformConfig{Stateless: true, OutputFormat: "csv"})
+	transformed, err = unmarked.Transform(results())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cells(transformed), []string{"", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("note cells without missing_value = %q, want %q", got, want)
+	}
+}
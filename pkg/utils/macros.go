@@ -8,17 +8,60 @@ import (
 	"time"
 )
 
+// Units time macros render Unix timestamps in
+const (
+	TimeUnitSeconds      = "s"
+	TimeUnitMilliseconds = "ms"
+	TimeUnitNanoseconds  = "ns"
+)
+
 // MacroSubstituter handles macro substitution in queries
 type MacroSubstituter struct {
 	startTime string
 	endTime   string
+	timeUnit  string         // Unit of substituted timestamps: s, ms (default) or ns
+	location  *time.Location // Time expressions are evaluated in this location
 }
 
-// NewMacroSubstituter creates a new macro substituter
-func NewMacroSubstituter(startTime, endTime string) *MacroSubstituter {
+// NewMacroSubstituter creates a new macro substituter rendering times in timeUnit and
+// evaluating time expressions in timezone, an IANA name such as America/New_York; an empty
+// timezone means UTC
+func NewMacroSubstituter(startTime, endTime, timeUnit, timezone string) (*MacroSubstituter, error) {
+	if timeUnit == "" {
+		timeUnit = TimeUnitMilliseconds
+	}
+
+	location := time.UTC
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		location = loc
+	}
+
 	return &MacroSubstituter{
 		startTime: startTime,
 		endTime:   endTime,
+		timeUnit:  timeUnit,
+		location:  location,
+	}, nil
+}
+
+// ValidTimeUnit reports whether unit is empty or a known time macro unit
+func ValidTimeUnit(unit string) bool {
+	return unit == "" || unit == TimeUnitSeconds || unit == TimeUnitMilliseconds || unit == TimeUnitNanoseconds
+}
+
+// unixTime returns t as a Unix timestamp in the configured unit
+func (m *MacroSubstituter) unixTime(t time.Time) int64 {
+	switch m.timeUnit {
+	case TimeUnitSeconds:
+		return t.Unix()
+	case TimeUnitNanoseconds:
+		return t.UnixNano()
+	default:
+		return t.UnixMilli()
 	}
 }
 
@@ -56,56 +99,99 @@ func (m *MacroSubstituter) SubstituteQuery(query, clusterName string) (string, e
 	return result, nil
 }
 
-// parseTimeExpression parses time expressions like "NOW", "NOW-5min", "NOW+10sec"
+// timeExpressionPattern matches "NOW" followed by one or more "± X<unit>" terms once upper-cased,
+// e.g. "NOW-1DAY-3HOUR+30MIN"
+var timeExpressionPattern = regexp.MustCompile(`^NOW((?:\s*[+-]\s*\d+\s*[A-Z]+)+)$`)
+
+// timeExpressionTerm matches a single "± X<unit>" term of a time expression
+var timeExpressionTerm = regexp.MustCompile(`([+-])\s*(\d+)\s*([A-Z]+)`)
+
+// timeExpressionUnits maps the upper-cased units and aliases accepted in time expressions
+var timeExpressionUnits = map[string]time.Duration{
+	"SEC": time.Second, "SECS": time.Second, "SECOND": time.Second, "SECONDS": time.Second,
+	"MIN": time.Minute, "MINS": time.Minute, "MINUTE": time.Minute, "MINUTES": time.Minute,
+	"HOUR": time.Hour, "HOURS": time.Hour, "HR": time.Hour, "HRS": time.Hour,
+	"DAY": 24 * time.Hour, "DAYS": 24 * time.Hour,
+	"WEEK": 7 * 24 * time.Hour, "WEEKS": 7 * 24 * time.Hour, "WK": 7 * 24 * time.Hour,
+}
+
+// parseRelativeOffset sums the signed terms of an upper-cased "NOW ± X<unit> ..." expression.
+// ok is false if expr is not such an expression; unit is set to the first unsupported unit
+func parseRelativeOffset(expr string) (offset time.Duration, unit string, ok bool) {
+	matches := timeExpressionPattern.FindStringSubmatch(expr)
+	if matches == nil {
+		return 0, "", false
+	}
+
+	for _, term := range timeExpressionTerm.FindAllStringSubmatch(matches[1], -1) {
+		unitDuration, supported := timeExpressionUnits[term[3]]
+		if !supported {
+			return 0, term[3], true
+		}
+		value, err := strconv.ParseInt(term[2], 10, 64)
+		if err != nil {
+			return 0, "", false
+		}
+		if term[1] == "-" {
+			offset -= time.Duration(value) * unitDuration
+		} else {
+			offset += time.Duration(value) * unitDuration
+		}
+	}
+	return offset, "", true
+}
+
+// absoluteTimeLayouts are the absolute time formats accepted in time expressions; date-only
+// times are midnight in the substituter's location
+var absoluteTimeLayouts = []string{time.RFC3339Nano, "2006-01-02"}
+
+// parseAbsoluteTime parses an RFC3339 or date-only time, reading times without an offset in
+// location. Bare integers are left to the unix timestamp handling, so a value like "2024" is
+// never read as a year
+func parseAbsoluteTime(expr string, location *time.Location) (time.Time, bool) {
+	for _, layout := range absoluteTimeLayouts {
+		if t, err := time.ParseInLocation(layout, expr, location); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseTimeExpression parses time expressions like "NOW", "NOW-5min", "NOW+10sec", "NOW-2hour",
+// "NOW-1day-3hour", unix timestamps, and absolute times like "2024-01-01T00:00:00Z" or "2024-01-01"
 func (m *MacroSubstituter) parseTimeExpression(expr string) (int64, error) {
 	expr = strings.TrimSpace(expr)
 
 	// Handle simple "NOW" case
 	if strings.ToUpper(expr) == "NOW" {
-		return time.Now().UnixMilli(), nil
+		return m.unixTime(m.now()), nil
 	}
 
-	// Handle "NOW ± Xmin" or "NOW ± Xsec" patterns
-	nowPattern := regexp.MustCompile(`^NOW\s*([+-])\s*(\d+)\s*(MIN|SEC)$`)
-	matches := nowPattern.FindStringSubmatch(strings.ToUpper(expr))
-
-	if len(matches) != 4 {
-		// Try to parse as direct unix timestamp
+	// Handle "NOW ± X<unit> ..." patterns
+	offset, unit, ok := parseRelativeOffset(strings.ToUpper(expr))
+	if !ok {
+		// Try to parse as direct unix timestamp, already in the configured unit
 		if timestamp, err := strconv.ParseInt(expr, 10, 64); err == nil {
 			return timestamp, nil
 		}
+		if t, ok := parseAbsoluteTime(expr, m.location); ok {
+			return m.unixTime(t), nil
+		}
 		return 0, fmt.Errorf("invalid time expression: %s", expr)
 	}
-
-	operator := matches[1]
-	valueStr := matches[2]
-	unit := matches[3]
-
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return 0, fmt.Errorf("invalid numeric value in time expression: %s", valueStr)
-	}
-
-	now := time.Now()
-	var duration time.Duration
-
-	switch strings.ToUpper(unit) {
-	case "MIN":
-		duration = time.Duration(value) * time.Minute
-	case "SEC":
-		duration = time.Duration(value) * time.Second
-	default:
+	if unit != "" {
 		return 0, fmt.Errorf("unsupported time unit: %s", unit)
 	}
 
-	var result time.Time
-	if operator == "+" {
-		result = now.Add(duration)
-	} else {
-		result = now.Add(-duration)
-	}
+	return m.unixTime(m.now().Add(offset)), nil
+}
 
-	return result.UnixMilli(), nil
+// now returns the current time in the substituter's location
+func (m *MacroSubstituter) now() time.Time {
+	if m.location == nil {
+		return time.Now().UTC()
+	}
+	return time.Now().In(m.location)
 }
 
 // ValidateTimeExpression validates a time expression without evaluating it
@@ -121,9 +207,11 @@ func ValidateTimeExpression(expr string) error {
 		return nil
 	}
 
-	// Handle "NOW ± Xmin" or "NOW ± Xsec" patterns
-	nowPattern := regexp.MustCompile(`^NOW\s*([+-])\s*(\d+)\s*(MIN|SEC)$`)
-	if nowPattern.MatchString(strings.ToUpper(expr)) {
+	// Handle "NOW ± X<unit> ..." patterns
+	if _, unit, ok := parseRelativeOffset(strings.ToUpper(expr)); ok {
+		if unit != "" {
+			return fmt.Errorf("invalid time expression: %s (unsupported unit %s; use sec, min, hour, day or week)", expr, strings.ToLower(unit))
+		}
 		return nil
 	}
 
@@ -132,5 +220,10 @@ func ValidateTimeExpression(expr string) error {
 		return nil
 	}
 
-	return fmt.Errorf("invalid time expression: %s (expected formats: NOW, NOW±Xmin, NOW±Xsec, or unix timestamp)", expr)
+	// Try to parse as an absolute time
+	if _, ok := parseAbsoluteTime(expr, time.UTC); ok {
+		return nil
+	}
+
+	return fmt.Errorf("invalid time expression: %s (expected formats: NOW, NOW±X with unit sec, min, hour, day or week and optionally more ±X terms such as NOW-1day-3hour, unix timestamp, RFC3339 time or YYYY-MM-DD date)", expr)
 }
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// assertRelative checks that m evaluates expr to now plus offset, in seconds
+func assertRelative(t *testing.T, m *MacroSubstituter, expr string, offset time.Duration) {
+	t.Helper()
+	before := time.Now().Add(offset).Unix()
+	got, err := m.parseTimeExpression(expr)
+	after := time.Now().Add(offset).Unix()
+	if err != nil {
+		t.Fatalf("parseTimeExpression(%s): %v", expr, err)
+	}
+	if got < before || got > after {
+		t.Errorf("parseTimeExpression(%s) = %d, want between %d and %d", expr, got, before, after)
+	}
+}
+
+func TestParseTimeExpressionUnits(t *testing.T) {
+	m, err := NewMacroSubstituter("", "", TimeUnitSeconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRelative(t, m, "NOW", 0)
+	assertRelative(t, m, "now-5min", -5*time.Minute)
+	assertRelative(t, m, "NOW+10sec", 10*time.Second)
+	assertRelative(t, m, "NOW-24hour", -24*time.Hour)
+	assertRelative(t, m, "NOW-2hrs", -2*time.Hour)
+	assertRelative(t, m, "Now-7Days", -7*24*time.Hour)
+	assertRelative(t, m, "NOW - 1 week", -7*24*time.Hour)
+	assertRelative(t, m, "NOW-2wk", -14*24*time.Hour)
+
+	for _, expr := range []string{"NOW-24hour", "NOW-2HR", "NOW-7day", "NOW-1week"} {
+		if err := ValidateTimeExpression(expr); err != nil {
+			t.Errorf("ValidateTimeExpression(%s): %v", expr, err)
+		}
+	}
+
+	// Unknown units name the unit in the error
+	if _, err := m.parseTimeExpression("NOW-1h"); err == nil || !strings.Contains(err.Error(), "unsupported time unit: H") {
+		t.Errorf("parseTimeExpression(NOW-1h) error = %v, want the unsupported unit", err)
+	}
+	if err := ValidateTimeExpression("NOW-1fortnight"); err == nil || !strings.Contains(err.Error(), "unsupported unit fortnight") {
+		t.Errorf("ValidateTimeExpression(NOW-1fortnight) error = %v, want the unsupported unit", err)
+	}
+}
+
+func TestSubstituteQueryTimeUnit(t *testing.T) {
+	substitute := func(unit string) int64 {
+		t.Helper()
+		m, err := NewMacroSubstituter("NOW", "", unit, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, err := m.SubstituteQuery("__STARTTIME__", "c0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := strconv.ParseInt(query, 10, 64)
+		if err != nil {
+			t.Fatalf("SubstituteQuery rendered %q, want an integer", query)
+		}
+		return got
+	}
+
+	before := time.Now()
+	millis := substitute("")
+	seconds := substitute(TimeUnitSeconds)
+	nanos := substitute(TimeUnitNanoseconds)
+	after := time.Now()
+
+	// An empty unit defaults to milliseconds
+	if millis < before.UnixMilli() || millis > after.UnixMilli() {
+		t.Errorf("default unit rendered %d, want milliseconds between %d and %d", millis, before.UnixMilli(), after.UnixMilli())
+	}
+	if seconds < before.Unix() || seconds > after.Unix() {
+		t.Errorf("unit s rendered %d, want seconds between %d and %d", seconds, before.Unix(), after.Unix())
+	}
+	if nanos < before.UnixNano() || nanos > after.UnixNano() {
+		t.Errorf("unit ns rendered %d, want nanoseconds between %d and %d", nanos, before.UnixNano(), after.UnixNano())
+	}
+
+	// Unix timestamps are taken as already being in the configured unit
+	m, err := NewMacroSubstituter("1704067200", "", TimeUnitSeconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query, err := m.SubstituteQuery("__STARTTIME__", "c0"); err != nil || query != "1704067200" {
+		t.Errorf("SubstituteQuery = %q, %v, want the timestamp unchanged", query, err)
+	}
+
+	for _, unit := range []string{"", TimeUnitSeconds, TimeUnitMilliseconds, TimeUnitNanoseconds} {
+		if !ValidTimeUnit(unit) {
+			t.Errorf("ValidTimeUnit(%q) = false", unit)
+		}
+	}
+	if ValidTimeUnit("us") {
+		t.Error("ValidTimeUnit(us) = true")
+	}
+}
+
+func TestParseTimeExpressionAbsolute(t *testing.T) {
+	millis, err := NewMacroSubstituter("", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seconds, err := NewMacroSubstituter("", "", TimeUnitSeconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nanos, err := NewMacroSubstituter("", "", TimeUnitNanoseconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := millis.parseTimeExpression("2024-01-01T00:00:00Z"); err != nil || got != 1704067200000 {
+		t.Errorf("RFC3339 in ms = %d, %v, want 1704067200000", got, err)
+	}
+	if got, err := seconds.parseTimeExpression("2024-01-01T00:00:00Z"); err != nil || got != 1704067200 {
+		t.Errorf("RFC3339 in s = %d, %v, want 1704067200", got, err)
+	}
+	if got, err := nanos.parseTimeExpression("2024-01-01T00:00:00Z"); err != nil || got != 1704067200000000000 {
+		t.Errorf("RFC3339 in ns = %d, %v, want 1704067200000000000", got, err)
+	}
+	if got, err := seconds.parseTimeExpression("2024-01-01"); err != nil || got != 1704067200 {
+		t.Errorf("date-only = %d, %v, want 1704067200", got, err)
+	}
+
+	// A bare integer is an epoch timestamp, never a year
+	if got, err := seconds.parseTimeExpression("2024"); err != nil || got != 2024 {
+		t.Errorf("bare integer = %d, %v, want 2024", got, err)
+	}
+
+	for _, expr := range []string{"2024-13-01", "2024-01-01T25:00:00Z", "01/02/2024", "yesterday"} {
+		if _, err := seconds.parseTimeExpression(expr); err == nil {
+			t.Errorf("parseTimeExpression(%s) succeeded, want an error", expr)
+		}
+		if err := ValidateTimeExpression(expr); err == nil {
+			t.Errorf("ValidateTimeExpression(%s) succeeded, want an error", expr)
+		}
+	}
+	for _, expr := range []string{"2024-01-01T00:00:00Z", "2024-01-01T00:00:00+05:30", "2024-01-01", "1704067200"} {
+		if err := ValidateTimeExpression(expr); err != nil {
+			t.Errorf("ValidateTimeExpression(%s): %v", expr, err)
+		}
+	}
+}
+
+func TestParseTimeExpressionTimezone(t *testing.T) {
+	// Pin the process zone far from UTC so that falling back to it would show
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	utc, err := NewMacroSubstituter("", "", TimeUnitSeconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := utc.parseTimeExpression("2024-01-01"); err != nil || got != 1704067200 {
+		t.Errorf("date without timezone = %d, %v, want UTC midnight 1704067200", got, err)
+	}
+	if zone := utc.now().Location(); zone != time.UTC {
+		t.Errorf("NOW without timezone evaluated in %v, want UTC", zone)
+	}
+
+	newYork, err := NewMacroSubstituter("", "", TimeUnitSeconds, "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newYork.parseTimeExpression("2024-01-01"); err != nil || got != 1704085200 {
+		t.Errorf("date in America/New_York = %d, %v, want 1704085200", got, err)
+	}
+	if zone := newYork.now().Location().String(); zone != "America/New_York" {
+		t.Errorf("NOW evaluated in %s, want America/New_York", zone)
+	}
+
+	// An explicit offset wins over the configured timezone
+	if got, err := newYork.parseTimeExpression("2024-01-01T00:00:00Z"); err != nil || got != 1704067200 {
+		t.Errorf("RFC3339 with offset = %d, %v, want 1704067200", got, err)
+	}
+
+	if _, err := NewMacroSubstituter("", "", "", "Mars/Olympus_Mons"); err == nil || !strings.Contains(err.Error(), `invalid timezone "Mars/Olympus_Mons"`) {
+		t.Errorf("NewMacroSubstituter with unknown timezone error = %v", err)
+	}
+}
+
+func TestParseTimeExpressionCompound(t *testing.T) {
+	m, err := NewMacroSubstituter("", "", TimeUnitSeconds, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertRelative(t, m, "NOW-1day-3hour", -27*time.Hour)
+	assertRelative(t, m, "NOW-1DAY-3HOUR+30MIN", -26*time.Hour-30*time.Minute)
+	assertRelative(t, m, "NOW - 1 week + 30 mins", -7*24*time.Hour+30*time.Minute)
+	assertRelative(t, m, "now+1hour-60min", 0)
+
+	for _, expr := range []string{"NOW-1day-3hour", "NOW - 1 week + 30 mins", "NOW+1hr+1hr"} {
+		if err := ValidateTimeExpression(expr); err != nil {
+			t.Errorf("ValidateTimeExpression(%s): %v", expr, err)
+		}
+	}
+
+	// Stray characters anywhere in the chain reject the whole expression
+	for _, expr := range []string{"NOW-1day3hour", "NOW-1day,-3hour", "NOW-1day-", "NOW--1day", "NOW-1day x"} {
+		if _, err := m.parseTimeExpression(expr); err == nil {
+			t.Errorf("parseTimeExpression(%s) succeeded, want an error", expr)
+		}
+		if err := ValidateTimeExpression(expr); err == nil {
+			t.Errorf("ValidateTimeExpression(%s) succeeded, want an error", expr)
+		}
+	}
+	if _, err := m.parseTimeExpression("NOW-1day-3fortnight"); err == nil || !strings.Contains(err.Error(), "unsupported time unit: FORTNIGHT") {
+		t.Errorf("parseTimeExpression with an unknown unit in the chain error = %v", err)
+	}
+}
+
+func TestSubstituteQueryMissingTimes(t *testing.T) {
+	m, err := NewMacroSubstituter("", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if query, err := m.SubstituteQuery(`{"cluster":"__CLUSTER__"}`, "c0"); err != nil || query != `{"cluster":"c0"}` {
+		t.Errorf("SubstituteQuery without time macros = %q, %v", query, err)
+	}
+	if _, err := m.SubstituteQuery(`{"gte":__STARTTIME__}`, "c0"); err == nil || !strings.Contains(err.Error(), "start_time not configured") {
+		t.Errorf("SubstituteQuery without start_time error = %v", err)
+	}
+	if _, err := m.SubstituteQuery(`{"lte":__ENDTIME__}`, "c0"); err == nil || !strings.Contains(err.Error(), "end_time not configured") {
+		t.Errorf("SubstituteQuery without end_time error = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRetryAfter caps server-requested retry delays when no limit is configured
+const DefaultMaxRetryAfter = 60 * time.Second
+
+// ParseRetryAfter parses a Retry-After header given either as delay seconds or an HTTP date
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// RetryDelay returns how long to wait before retrying after resp. A Retry-After header on a
+// 429 or 503 response is honored up to maxDelay (DefaultMaxRetryAfter when 0); otherwise
+// fallback is used
+func RetryDelay(resp *http.Response, fallback, maxDelay time.Duration) time.Duration {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return fallback
+	}
+
+	delay, ok := ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return fallback
+	}
+
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxRetryAfter
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
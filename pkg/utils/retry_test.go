@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if got, ok := ParseRetryAfter("5", now); !ok || got != 5*time.Second {
+		t.Errorf("seconds: got %v, %v", got, ok)
+	}
+	if got, ok := ParseRetryAfter("Mon, 01 Jan 2024 00:00:30 GMT", now); !ok || got != 30*time.Second {
+		t.Errorf("HTTP date: got %v, %v", got, ok)
+	}
+	// A date already passed means retry now
+	if got, ok := ParseRetryAfter("Sun, 31 Dec 2023 23:00:00 GMT", now); !ok || got != 0 {
+		t.Errorf("past date: got %v, %v", got, ok)
+	}
+	for _, value := range []string{"", "-1", "soon"} {
+		if got, ok := ParseRetryAfter(value, now); ok {
+			t.Errorf("ParseRetryAfter(%q) = %v, want not ok", value, got)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	response := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+
+	if got := RetryDelay(response(http.StatusTooManyRequests, "3"), time.Second, 0); got != 3*time.Second {
+		t.Errorf("429 with Retry-After: delay %v, want 3s", got)
+	}
+	if got := RetryDelay(response(http.StatusServiceUnavailable, "3"), time.Second, 0); got != 3*time.Second {
+		t.Errorf("503 with Retry-After: delay %v, want 3s", got)
+	}
+	if got := RetryDelay(response(http.StatusTooManyRequests, "600"), time.Second, 10*time.Second); got != 10*time.Second {
+		t.Errorf("capped: delay %v, want 10s", got)
+	}
+	if got := RetryDelay(response(http.StatusTooManyRequests, "600"), time.Second, 0); got != DefaultMaxRetryAfter {
+		t.Errorf("default cap: delay %v, want %v", got, DefaultMaxRetryAfter)
+	}
+
+	// Otherwise the caller's backoff applies
+	if got := RetryDelay(response(http.StatusInternalServerError, "3"), time.Second, 0); got != time.Second {
+		t.Errorf("500: delay %v, want the fallback", got)
+	}
+	if got := RetryDelay(response(http.StatusTooManyRequests, ""), time.Second, 0); got != time.Second {
+		t.Errorf("429 without Retry-After: delay %v, want the fallback", got)
+	}
+	if got := RetryDelay(nil, time.Second, 0); got != time.Second {
+		t.Errorf("no response: delay %v, want the fallback", got)
+	}
+}
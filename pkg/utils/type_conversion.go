@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -50,6 +51,11 @@ func SafeInt(value interface{}) (int, bool) {
 		return int(v), true
 	case int32:
 		return int(v), true
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i), true
+		}
+		return 0, false
 	case float64:
 		// Check if it's actually an integer value
 		if v == float64(int64(v)) {
@@ -88,6 +94,11 @@ func SafeFloat64(value interface{}) (float64, bool) {
 		return float64(v), true
 	case int32:
 		return float64(v), true
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return f, true
+		}
+		return 0, false
 	case string:
 		if f, err := strconv.ParseFloat(v, 64); err == nil {
 			return f, true